@@ -1,13 +1,20 @@
 package main
 
 import (
+    "fmt"
     "log"
+    "os"
+    "path/filepath"
+    "time"
 
     "megabaseGo/internal/config"
     "megabaseGo/internal/models"
     dbpkg "megabaseGo/internal/database"
     dbseed "megabaseGo/internal/database/seeders"
+    "megabaseGo/internal/routes"
+    "megabaseGo/internal/utils"
 
+    "github.com/gin-gonic/gin"
     "github.com/spf13/cobra"
 )
 
@@ -37,6 +44,10 @@ func main() {
             if err := db.AutoMigrate(models.AllModels...); err != nil {
                 log.Fatalf("Error en AutoMigrate: %v", err)
             }
+            // Columnas generadas e índices de búsqueda que AutoMigrate no sabe crear
+            if err := dbpkg.MigrateCitizenSearch(db); err != nil {
+                log.Fatalf("Error preparando la búsqueda de ciudadanos: %v", err)
+            }
             log.Println("✔ Migraciones completadas")
 
             // 4) Si se pasa --seed, ejecuta todos los seeders
@@ -52,6 +63,189 @@ func main() {
     migrateCmd.Flags().BoolVarP(&withSeed, "seed", "s", false, "Ejecutar seeders tras migrar")
     rootCmd.AddCommand(migrateCmd)
 
+    // Gestión de claves de firma JWT (JWT_KEYS_DIR)
+    // Rotación: 1) keys rotate --alg RS256 genera una clave nueva, la publica en el JWKS y
+    // programa su activación para cuando haya expirado el JWKS cacheado por otros servicios;
+    // las anteriores siguen publicadas para verificar tokens vigentes. 2) Pasada la vigencia
+    // del refresh token, keys retire <kid> elimina la clave antigua.
+    var keysDir, keysAlg string
+    // Se suma el intervalo de recarga del servidor: la clave nueva tarda hasta eso en aparecer en el JWKS
+    activateAfter := utils.JWKSMaxAge + time.Minute
+
+    keysCmd := &cobra.Command{
+        Use:   "keys",
+        Short: "Gestiona las claves de firma JWT",
+        PersistentPreRun: func(cmd *cobra.Command, args []string) {
+            config.LoadConfig()
+            if keysDir == "" {
+                keysDir = os.Getenv("JWT_KEYS_DIR")
+            }
+            if keysDir == "" {
+                log.Fatal("Indique el directorio de claves con --dir o JWT_KEYS_DIR")
+            }
+            os.Setenv("JWT_KEYS_DIR", keysDir)
+        },
+    }
+    keysCmd.PersistentFlags().StringVar(&keysDir, "dir", "", "Directorio de claves (por defecto JWT_KEYS_DIR)")
+
+    keysListCmd := &cobra.Command{
+        Use:   "list",
+        Short: "Lista las claves y marca la activa",
+        Run: func(cmd *cobra.Command, args []string) {
+            keys, err := utils.LoadKeySet()
+            if err != nil {
+                log.Fatalf("Error cargando claves: %v", err)
+            }
+            for _, key := range keys.Keys() {
+                marker := " "
+                if key == keys.Active() {
+                    marker = "*"
+                }
+                kid := key.ID
+                if kid == "" {
+                    kid = "(legacy HS256)"
+                }
+                usage := "verify"
+                if key.CanSign() {
+                    usage = "sign+verify"
+                }
+                fmt.Printf("%s %-40s %-6s %s\n", marker, kid, key.Algorithm, usage)
+            }
+            if kid, at, err := utils.PendingKey(keysDir); err == nil && kid != "" {
+                fmt.Printf("\nActivación programada: %s a partir de %s\n", kid, at.Local().Format(time.RFC3339))
+            }
+        },
+    }
+
+    keysGenerateCmd := &cobra.Command{
+        Use:   "generate",
+        Short: "Genera una clave nueva sin activarla (queda publicada para verificación)",
+        Run: func(cmd *cobra.Command, args []string) {
+            // Se fija la clave actual antes de escribir la nueva: sin active_kid firmaría la más reciente
+            pinned, err := utils.PinActiveKey(keysDir)
+            if err != nil {
+                log.Fatalf("Error fijando la clave activa: %v", err)
+            }
+            kid, err := utils.GenerateKeyFile(keysDir, keysAlg)
+            if err != nil {
+                log.Fatalf("Error generando clave: %v", err)
+            }
+            if pinned == "" {
+                log.Printf("⚠ %s no tenía una clave para firmar: %s es la única y firma de inmediato", keysDir, kid)
+                return
+            }
+            log.Printf("✔ Clave %s generada; sigue firmando %s", kid, pinned)
+        },
+    }
+    keysGenerateCmd.Flags().StringVar(&keysAlg, "alg", utils.AlgRS256, "Algoritmo: RS256, ES256 o EdDSA")
+
+    keysRotateCmd := &cobra.Command{
+        Use:   "rotate",
+        Short: "Genera una clave nueva, la publica y programa su activación para firmar",
+        Run: func(cmd *cobra.Command, args []string) {
+            if activateAfter < 0 {
+                log.Fatal("--activate-after no puede ser negativo")
+            }
+            // Sin una clave asimétrica activa no hay JWKS previo que esperar: se activa de inmediato
+            current, err := utils.LoadKeySet()
+            immediate := activateAfter == 0 || err != nil || current.Active().ID == ""
+            // Se fija la clave actual antes de escribir la nueva para que ni ella ni una programada
+            // antes tomen la firma antes de tiempo
+            if !immediate {
+                if _, err := os.Stat(filepath.Join(keysDir, current.Active().ID+".pem")); err == nil {
+                    if err := utils.SetActiveKey(keysDir, current.Active().ID); err != nil {
+                        log.Fatalf("Error fijando la clave activa: %v", err)
+                    }
+                }
+            }
+            kid, err := utils.GenerateKeyFile(keysDir, keysAlg)
+            if err != nil {
+                log.Fatalf("Error generando clave: %v", err)
+            }
+            if immediate {
+                if err := utils.SetActiveKey(keysDir, kid); err != nil {
+                    log.Fatalf("Error activando clave: %v", err)
+                }
+                log.Printf("✔ Clave %s generada y activada", kid)
+                return
+            }
+            at := time.Now().Add(activateAfter)
+            if err := utils.ScheduleActiveKey(keysDir, kid, at); err != nil {
+                log.Fatalf("Error programando la activación: %v", err)
+            }
+            log.Printf("✔ Clave %s generada y publicada; firmará a partir de %s", kid, at.Format(time.RFC3339))
+        },
+    }
+    keysRotateCmd.Flags().StringVar(&keysAlg, "alg", utils.AlgRS256, "Algoritmo: RS256, ES256 o EdDSA")
+    keysRotateCmd.Flags().DurationVar(&activateAfter, "activate-after", activateAfter, "Espera antes de firmar con la clave nueva (0 activa de inmediato)")
+
+    keysActivateCmd := &cobra.Command{
+        Use:   "activate <kid>",
+        Short: "Activa una clave existente para firmar (descarta la activación programada)",
+        Args:  cobra.ExactArgs(1),
+        Run: func(cmd *cobra.Command, args []string) {
+            if err := utils.SetActiveKey(keysDir, args[0]); err != nil {
+                log.Fatalf("Error activando clave: %v", err)
+            }
+            log.Printf("✔ Clave %s activada", args[0])
+        },
+    }
+
+    keysRetireCmd := &cobra.Command{
+        Use:   "retire <kid>",
+        Short: "Elimina una clave que ya no se usa para firmar",
+        Args:  cobra.ExactArgs(1),
+        Run: func(cmd *cobra.Command, args []string) {
+            keys, err := utils.LoadKeySet()
+            if err != nil {
+                log.Fatalf("Error cargando claves: %v", err)
+            }
+            if keys.Active().ID == args[0] {
+                log.Fatal("No se puede retirar la clave activa; active otra primero")
+            }
+            if pending, _, err := utils.PendingKey(keysDir); err == nil && pending == args[0] {
+                log.Fatal("No se puede retirar una clave con activación programada; active otra primero")
+            }
+            removed := false
+            for _, name := range []string{args[0] + ".pem", args[0] + ".pub.pem"} {
+                if err := os.Remove(filepath.Join(keysDir, name)); err == nil {
+                    removed = true
+                }
+            }
+            if !removed {
+                log.Fatalf("La clave %s no existe en %s", args[0], keysDir)
+            }
+            log.Printf("✔ Clave %s retirada", args[0])
+        },
+    }
+
+    keysCmd.AddCommand(keysListCmd, keysGenerateCmd, keysRotateCmd, keysActivateCmd, keysRetireCmd)
+    rootCmd.AddCommand(keysCmd)
+
+    // Matriz de autorización: arma las rutas (lo que valida la tabla igual que al arrancar
+    // el servidor) e imprime el requisito de cada una
+    routesCmd := &cobra.Command{
+        Use:   "routes",
+        Short: "Imprime la matriz de políticas de autorización por ruta",
+        Run: func(cmd *cobra.Command, args []string) {
+            config.LoadConfig()
+            gin.SetMode(gin.ReleaseMode)
+
+            if _, err := routes.Setup(); err != nil {
+                log.Fatalf("Política de autorización inválida:\n%v", err)
+            }
+            policies, err := routes.NewPolicyTable()
+            if err != nil {
+                log.Fatalf("Política de autorización inválida:\n%v", err)
+            }
+
+            for _, p := range policies.Policies() {
+                fmt.Printf("%-7s %-55s %s\n", p.Method, p.Path, p.Requirement())
+            }
+        },
+    }
+    rootCmd.AddCommand(routesCmd)
+
     if err := rootCmd.Execute(); err != nil {
         log.Fatal(err)
     }
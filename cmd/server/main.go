@@ -40,7 +40,10 @@ func main() {
 	}
 
 	// 4. Inicializar rutas
-	router := routes.Setup()
+	router, err := routes.Setup()
+	if err != nil {
+		log.Fatalf("❌ Error configurando rutas: %v", err)
+	}
 	log.Println("🛣️  Rutas configuradas")
 
 	// 5. Configurar servidor HTTP
@@ -1,27 +1,42 @@
 package dto
 
+import "time"
+
 // LoginRequest estructura para login
 type LoginRequest struct {
 	UserName string `json:"user_name" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
-// RegisterRequest estructura para registro
+// RegisterRequest estructura para registro. El rol no lo elige el cliente: se asigna REGISTRATION_DEFAULT_ROLE
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	UserName string `json:"user_name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	RoleID   uint   `json:"role_id" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ClientInfo datos del cliente que origina la petición, se guardan en la sesión
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
 }
 
 // AuthResponse estructura para respuestas de autenticación
 type AuthResponse struct {
-	User         UserResponse `json:"user"`
-	AccessToken  string       `json:"access_token"`
-	RefreshToken string       `json:"refresh_token"`
-	TokenType    string       `json:"token_type"`
-	ExpiresIn    int64        `json:"expires_in"`
+	User             UserResponse `json:"user"`
+	AccessToken      string       `json:"access_token"`
+	RefreshToken     string       `json:"refresh_token"`
+	TokenType        string       `json:"token_type"`
+	ExpiresIn        int64        `json:"expires_in"`
+	RefreshExpiresIn int64        `json:"refresh_expires_in"`
+	// MFARequired indica que falta el segundo factor: no hay tokens, solo MFAToken
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+	// MFAEnrollmentRequired indica que el rol exige MFA y el usuario debe configurarlo
+	MFAEnrollmentRequired bool `json:"mfa_enrollment_required,omitempty"`
+	// EmailVerificationRequired indica que la cuenta se creó sin sesión: falta verificar el email
+	EmailVerificationRequired bool `json:"email_verification_required,omitempty"`
 }
 
 // RefreshTokenRequest estructura para refresh token
@@ -32,5 +47,60 @@ type RefreshTokenRequest struct {
 // ChangePasswordRequest estructura para cambio de contraseña
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password" binding:"required"`
-	NewPassword     string `json:"new_password" binding:"required,min=6"`
-}
\ No newline at end of file
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ForgotPasswordRequest estructura para solicitar la recuperación de contraseña
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest estructura para restablecer la contraseña con el token recibido por correo
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResendVerificationRequest estructura para reenviar el correo de verificación
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// Tipos de grant aceptados por POST /auth/token
+const (
+	GrantTypePassword     = "password"
+	GrantTypeRefreshToken = "refresh_token"
+	GrantTypeMFA          = "mfa"
+)
+
+// TokenRequest estructura para clientes no navegador (móvil, CLI): los tokens viajan en el body
+type TokenRequest struct {
+	GrantType    string `json:"grant_type" binding:"required,oneof=password refresh_token mfa"`
+	UserName     string `json:"user_name" binding:"required_if=GrantType password"`
+	Password     string `json:"password" binding:"required_if=GrantType password"`
+	RefreshToken string `json:"refresh_token" binding:"required_if=GrantType refresh_token"`
+	MFAToken     string `json:"mfa_token" binding:"required_if=GrantType mfa"`
+	Code         string `json:"code" binding:"required_if=GrantType mfa"`
+}
+
+// LogoutRequest body opcional de logout para clientes que no usan cookies
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ImpersonationInfo indica que la sesión actual suplanta al usuario y quién lo hace
+type ImpersonationInfo struct {
+	Active    bool      `json:"active"`
+	ActorID   uint      `json:"actor_id"`
+	ActorName string    `json:"actor_user_name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ImpersonationResponse token emitido al suplantar a un usuario; no incluye refresh token
+type ImpersonationResponse struct {
+	AccessToken   string             `json:"access_token"`
+	TokenType     string             `json:"token_type"`
+	ExpiresIn     int64              `json:"expires_in"`
+	User          UserResponse       `json:"user"`
+	Impersonation *ImpersonationInfo `json:"impersonation"`
+}
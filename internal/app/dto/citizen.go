@@ -9,7 +9,8 @@ import (
 // Piensa en esto como un formulario que debe llenarse para registrar un nuevo contribuyente
 type CreateCitizenRequest struct {
 	// --- IDENTIFICACIÓN PRINCIPAL (Obligatorio) ---
-	NumeroIdentificacion string `json:"numero_identificacion" binding:"required,min=10,max=25"`
+	// Cédula y RUC con dígito verificador, pasaporte o identificación del exterior según el tipo
+	NumeroIdentificacion string `json:"numero_identificacion" binding:"required,max=25,identification=TipoIdentificacion"`
 	TipoIdentificacion   string `json:"tipo_identificacion" binding:"required,oneof=04 05 06 07"`
 	
 	// --- DATOS DE CONTACTO (Obligatorio) ---
@@ -47,13 +48,17 @@ type CreateCitizenRequest struct {
 
 	// --- METADATOS ADICIONALES ---
 	MotivoCancelacionSuspension string `json:"motivo_cancelacion_suspension,omitempty" binding:"max=250"`
+
+	// --- PROPIEDAD ---
+	// Compañía dueña; si se omite se usa la del usuario. Solo quien ve todos los registros puede elegir otra.
+	CompanyID *uint `json:"company_id,omitempty"`
 }
 
 // UpdateCitizenRequest estructura para actualizar un citizen
 // Esta versión permite actualizaciones parciales - no todos los campos son obligatorios
 type UpdateCitizenRequest struct {
 	// --- IDENTIFICACIÓN PRINCIPAL ---
-	NumeroIdentificacion *string `json:"numero_identificacion,omitempty" binding:"omitempty,min=10,max=25"`
+	NumeroIdentificacion *string `json:"numero_identificacion,omitempty" binding:"omitempty,max=25,identification=TipoIdentificacion"`
 	TipoIdentificacion   *string `json:"tipo_identificacion,omitempty" binding:"omitempty,oneof=04 05 06 07"`
 	
 	// --- DATOS DE CONTACTO ---
@@ -91,6 +96,9 @@ type UpdateCitizenRequest struct {
 
 	// --- METADATOS ADICIONALES ---
 	MotivoCancelacionSuspension *string `json:"motivo_cancelacion_suspension,omitempty" binding:"omitempty,max=250"`
+
+	// --- PROPIEDAD ---
+	CompanyID *uint `json:"company_id,omitempty"` // 0 deja el registro sin compañía
 }
 
 // CitizenResponse estructura para respuestas
@@ -142,6 +150,11 @@ type CitizenResponse struct {
 	MotivoCancelacionSuspension string      `json:"motivo_cancelacion_suspension,omitempty"`
 	CreatedAt                   interface{} `json:"created_at"`
 	UpdatedAt                   interface{} `json:"updated_at"`
+
+	// --- PROPIEDAD ---
+	CreatedBy *uint `json:"created_by"`
+	UpdatedBy *uint `json:"updated_by"`
+	CompanyID *uint `json:"company_id"`
 }
 
 // CitizenSearchFilters estructura para filtros de búsqueda
@@ -154,8 +167,19 @@ type CitizenSearchFilters struct {
 	Provincia           *string `form:"provincia"`
 	Ciudad              *string `form:"ciudad"`
 	ObligadoContabilidad *string `form:"obligado_contabilidad" binding:"omitempty,oneof=SI NO"`
-	
-	// Paginación
-	Page     int `form:"page,default=1" binding:"min=1"`
-	PageSize int `form:"page_size,default=10" binding:"min=1,max=100"`
+	// La paginación (page, page_size, sort) la interpreta el paquete pagination
+}
+
+// CitizenSearchQuery parámetros de GET /citizens/search; admite además los filtros del listado
+type CitizenSearchQuery struct {
+	Q string `form:"q" binding:"required,min=2,max=200"`
+	CitizenSearchFilters
+}
+
+// CitizenSearchResult resultado de la búsqueda: el ciudadano, su relevancia y los fragmentos
+// donde coincidió, con el texto encontrado entre <mark> y </mark> (el resto va escapado como HTML)
+type CitizenSearchResult struct {
+	CitizenResponse
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,46 @@
+package dto
+
+import "time"
+
+// CreateInvitationRequest estructura para invitar a un usuario
+type CreateInvitationRequest struct {
+	Email     string `json:"email" binding:"required,email"`
+	RoleID    uint   `json:"role_id" binding:"required"`
+	CompanyID *uint  `json:"company_id"`
+	// ExpiresInHours vigencia del enlace; 0 usa INVITATION_TTL_HOURS
+	ExpiresInHours int `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+}
+
+// AcceptInvitationRequest datos del invitado al aceptar; el email y el rol vienen de la invitación
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	UserName string `json:"user_name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// InvitationResponse estructura para respuestas de invitaciones
+type InvitationResponse struct {
+	ID             uint       `json:"id"`
+	Email          string     `json:"email"`
+	RoleID         uint       `json:"role_id"`
+	RoleName       string     `json:"role_name,omitempty"`
+	CompanyID      *uint      `json:"company_id"`
+	InvitedBy      uint       `json:"invited_by"`
+	InviterName    string     `json:"invited_by_user_name,omitempty"`
+	Status         string     `json:"status"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	SentCount      int        `json:"sent_count"`
+	LastSentAt     *time.Time `json:"last_sent_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedUserID *uint      `json:"accepted_user_id"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// InvitationPreviewResponse lo que ve el invitado antes de aceptar
+type InvitationPreviewResponse struct {
+	Email     string    `json:"email"`
+	RoleName  string    `json:"role_name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
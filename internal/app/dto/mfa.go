@@ -0,0 +1,31 @@
+package dto
+
+// TOTPEnrollmentResponse datos para configurar la app autenticadora
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	// QRCodePNG imagen PNG del código QR como data URI (data:image/png;base64,...)
+	QRCodePNG string `json:"qr_code_png"`
+}
+
+// TOTPCodeRequest estructura para confirmar el enrolamiento o regenerar códigos
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTOTPRequest estructura para desactivar TOTP
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse códigos de recuperación en claro; solo se muestran una vez
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAVerifyRequest segundo paso del login: token de desafío y código TOTP o de recuperación
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// OIDCProviderResponse proveedor de SSO disponible en la pantalla de login
+type OIDCProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
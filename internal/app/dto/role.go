@@ -6,6 +6,8 @@ type CreateRoleRequest struct {
 	DisplayName string `json:"display_name" binding:"required"`
 	Description string `json:"description"`
 	IsActive    *bool  `json:"is_active"`
+	RequireMFA  *bool  `json:"require_mfa"`
+	ParentID    *uint  `json:"parent_id"`
 }
 
 // UpdateRoleRequest estructura para actualizar un rol
@@ -14,15 +16,35 @@ type UpdateRoleRequest struct {
 	DisplayName string `json:"display_name"`
 	Description string `json:"description"`
 	IsActive    *bool  `json:"is_active"`
+	RequireMFA  *bool  `json:"require_mfa"`
+	ParentID    *uint  `json:"parent_id"` // 0 quita el rol padre
 }
 
 // RoleResponse estructura para respuestas
 type RoleResponse struct {
-	ID          uint        `json:"id"`
-	Name        string      `json:"name"`
-	DisplayName string      `json:"display_name"`
-	Description string      `json:"description"`
-	IsActive    bool        `json:"is_active"`
-	CreatedAt   interface{} `json:"created_at"`
-	UpdatedAt   interface{} `json:"updated_at"`
-}
\ No newline at end of file
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name"`
+	Description string   `json:"description"`
+	IsActive    bool     `json:"is_active"`
+	RequireMFA  bool     `json:"require_mfa"`
+	IsSystem    bool     `json:"is_system"`
+	ParentID    *uint    `json:"parent_id"`
+	Permissions []string `json:"permissions"`
+	// InheritedPermissions permisos que el rol recibe de sus ancestros y no tiene asignados
+	InheritedPermissions []string    `json:"inherited_permissions"`
+	CreatedAt            interface{} `json:"created_at"`
+	UpdatedAt            interface{} `json:"updated_at"`
+}
+
+// PermissionResponse estructura para respuestas de permisos
+type PermissionResponse struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// RolePermissionsRequest lista de permisos (por nombre) a asignar, agregar o quitar de un rol
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
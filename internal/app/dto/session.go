@@ -0,0 +1,27 @@
+package dto
+
+import "time"
+
+// SessionResponse sesión activa (dispositivo con refresh token vigente)
+type SessionResponse struct {
+	ID          uint      `json:"id"`
+	IPAddress   string    `json:"ip_address"`
+	UserAgent   string    `json:"user_agent"`
+	AuthMethods []string  `json:"auth_methods"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// Current indica la sesión que hace la petición
+	Current bool `json:"current"`
+}
+
+// LoginEventResponse intento de inicio de sesión del historial
+type LoginEventResponse struct {
+	ID            uint      `json:"id"`
+	Success       bool      `json:"success"`
+	Method        string    `json:"method"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent"`
+	CreatedAt     time.Time `json:"created_at"`
+}
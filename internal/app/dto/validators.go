@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"reflect"
+
+	"megabaseGo/internal/identification"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators registra en el validador de Gin las reglas propias usadas en los DTOs
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil
+	}
+	return v.RegisterValidation("identification", validateIdentification)
+}
+
+// validateIdentification regla `identification=CampoTipo`: valida el número según el tipo de
+// identificación del campo hermano indicado. Si el tipo no viene (actualización parcial) la
+// combinación con el valor guardado la valida el servicio.
+func validateIdentification(fl validator.FieldLevel) bool {
+	tipoField, kind, _, ok := fl.GetStructFieldOKAdvanced2(fl.Parent(), fl.Param())
+	if !ok || kind != reflect.String || tipoField.String() == "" {
+		return true
+	}
+	_, err := identification.Validate(tipoField.String(), fl.Field().String())
+	return err == nil
+}
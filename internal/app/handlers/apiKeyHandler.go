@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/app/middleware"
+	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler() *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: services.NewAPIKeyService(),
+	}
+}
+
+// CreateAPIKey crea una API key para el usuario actual; la clave solo se muestra en esta respuesta
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	apiKey, err := h.apiKeyService.CreateAPIKey(userID, &req)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusCreated, "API key creada. Guárdela ahora, no se volverá a mostrar", gin.H{"api_key": apiKey})
+}
+
+// GetMyAPIKeys lista las API keys del usuario actual
+func (h *APIKeyHandler) GetMyAPIKeys(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	apiKeys, err := h.apiKeyService.GetUserAPIKeys(userID)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{
+		"api_keys": apiKeys,
+		"count":    len(apiKeys),
+		"scopes":   services.APIKeyScopes,
+	})
+}
+
+// RevokeMyAPIKey revoca una API key del usuario actual
+func (h *APIKeyHandler) RevokeMyAPIKey(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de API key inválido"))
+		return
+	}
+
+	if err := h.apiKeyService.RevokeUserAPIKey(userID, uint(keyID)); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "API key revocada correctamente", nil)
+}
+
+// GetAPIKeys lista las API keys de todos los usuarios (admin)
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	includeRevoked := c.Query("include_revoked") == "true"
+	var userID *uint
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		if id, err := strconv.ParseUint(userIDStr, 10, 32); err == nil {
+			userIDUint := uint(id)
+			userID = &userIDUint
+		}
+	}
+
+	apiKeys, err := h.apiKeyService.GetAllAPIKeys(userID, includeRevoked)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{
+		"api_keys": apiKeys,
+		"count":    len(apiKeys),
+	})
+}
+
+// RevokeAPIKey revoca cualquier API key (admin)
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de API key inválido"))
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(uint(keyID)); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "API key revocada correctamente", nil)
+}
@@ -2,23 +2,28 @@ package handlers
 
 import (
 	"net/http"
-	"time"
+	"strings"
 
 	"megabaseGo/internal/app/dto"
 	"megabaseGo/internal/app/middleware"
 	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/config"
 	"megabaseGo/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
+	authService          *services.AuthService
+	accountService       *services.AccountService
+	impersonationService *services.ImpersonationService
 }
 
 func NewAuthHandler() *AuthHandler {
 	return &AuthHandler{
-		authService: services.NewAuthService(),
+		authService:          services.NewAuthService(),
+		accountService:       services.NewAccountService(),
+		impersonationService: services.NewImpersonationService(),
 	}
 }
 
@@ -29,16 +34,44 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	authResponse, err := h.authService.Login(&req)
+	authResponse, err := h.authService.Login(&req, clientInfo(c))
 	if err != nil {
 		utils.HandleGinError(c, err)
 		return
 	}
 
-	accessTokenMaxAge := int(authResponse.ExpiresIn)
-	c.SetCookie("access_token", authResponse.AccessToken, accessTokenMaxAge, "/", "localhost", false, true)
-	refreshTokenMaxAge := int((time.Hour * 24 * 7).Seconds())
-	c.SetCookie("refresh_token", authResponse.RefreshToken, refreshTokenMaxAge, "/", "localhost", false, true)
+	// Con TOTP activo no se entregan cookies hasta completar el segundo factor
+	if authResponse.MFARequired {
+		utils.SendSuccess(c, http.StatusOK, "Se requiere verificación MFA", gin.H{
+			"mfa_required": true,
+			"mfa_token":    authResponse.MFAToken,
+		})
+		return
+	}
+
+	setAuthCookies(c, authResponse)
+
+	utils.SendSuccess(c, http.StatusOK, "Login exitoso", gin.H{
+		"user":                    authResponse.User,
+		"mfa_enrollment_required": authResponse.MFAEnrollmentRequired,
+	})
+}
+
+// VerifyMFA completa el login en dos pasos con el código TOTP o de recuperación
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req dto.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	authResponse, err := h.authService.VerifyMFA(&req, clientInfo(c))
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	setAuthCookies(c, authResponse)
 
 	utils.SendSuccess(c, http.StatusOK, "Login exitoso", gin.H{"user": authResponse.User})
 }
@@ -50,23 +83,29 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	authResponse, err := h.authService.Register(&req)
+	authResponse, err := h.authService.Register(&req, clientInfo(c))
 	if err != nil {
 		utils.HandleGinError(c, err)
 		return
 	}
 
-	accessTokenMaxAge := int(authResponse.ExpiresIn)
-	c.SetCookie("access_token", authResponse.AccessToken, accessTokenMaxAge, "/", "localhost", false, true)
-	refreshTokenMaxAge := int((time.Hour * 24 * 7).Seconds())
-	c.SetCookie("refresh_token", authResponse.RefreshToken, refreshTokenMaxAge, "/", "localhost", false, true)
+	// Sin email verificado no se entregan cookies: la sesión empieza en el login posterior
+	if authResponse.EmailVerificationRequired {
+		utils.SendSuccess(c, http.StatusCreated, "Registro exitoso; verifique su email para iniciar sesión", gin.H{
+			"user":                        authResponse.User,
+			"email_verification_required": true,
+		})
+		return
+	}
+
+	setAuthCookies(c, authResponse)
 
 	utils.SendSuccess(c, http.StatusCreated, "Registro exitoso", gin.H{"user": authResponse.User})
 }
 
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	refreshToken, err := c.Cookie("refresh_token")
-	if err != nil {
+	refreshToken := utils.GetCookie(c, config.Get().Cookie.RefreshName)
+	if refreshToken == "" {
 		utils.HandleGinError(c, utils.NewUnauthorizedError("Refresh token no encontrado"))
 		return
 	}
@@ -77,20 +116,128 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	accessTokenMaxAge := int(authResponse.ExpiresIn)
-	c.SetCookie("access_token", authResponse.AccessToken, accessTokenMaxAge, "/", "localhost", false, true)
-	refreshTokenMaxAge := int((time.Hour * 24 * 7).Seconds())
-	c.SetCookie("refresh_token", authResponse.RefreshToken, refreshTokenMaxAge, "/", "localhost", false, true)
+	setAuthCookies(c, authResponse)
 
 	utils.SendSuccess(c, http.StatusOK, "Token refrescado exitosamente", gin.H{"user": authResponse.User})
 }
 
+// Token emite tokens en el body para clientes no navegador (sin cookies).
+// grant_type: password, refresh_token o mfa (segundo paso cuando la respuesta trae mfa_required).
+func (h *AuthHandler) Token(c *gin.Context) {
+	var req dto.TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	var authResponse *dto.AuthResponse
+	var err error
+	switch req.GrantType {
+	case dto.GrantTypePassword:
+		authResponse, err = h.authService.Login(&dto.LoginRequest{UserName: req.UserName, Password: req.Password}, clientInfo(c))
+	case dto.GrantTypeRefreshToken:
+		authResponse, err = h.authService.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: req.RefreshToken})
+	case dto.GrantTypeMFA:
+		authResponse, err = h.authService.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: req.MFAToken, Code: req.Code}, clientInfo(c))
+	}
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	utils.SendData(c, http.StatusOK, authResponse)
+}
+
 func (h *AuthHandler) Logout(c *gin.Context) {
-	c.SetCookie("access_token", "", -1, "/", "localhost", false, true)
-	c.SetCookie("refresh_token", "", -1, "/", "localhost", false, true)
+	cookieCfg := config.Get().Cookie
+
+	// Navegadores envían los tokens en cookies; otros clientes en el header y el body
+	refreshToken := utils.GetCookie(c, cookieCfg.RefreshName)
+	if refreshToken == "" {
+		var req dto.LogoutRequest
+		if err := c.ShouldBindJSON(&req); err == nil {
+			refreshToken = req.RefreshToken
+		}
+	}
+	accessToken := utils.GetCookie(c, cookieCfg.AccessName)
+	if accessToken == "" {
+		accessToken = bearerToken(c)
+	}
+
+	// Revocar la sesión en el servidor; aunque falle, se limpian las cookies
+	h.authService.Logout(refreshToken, accessToken)
+
+	utils.ClearCookie(c, cookieCfg.AccessName, true)
+	utils.ClearCookie(c, cookieCfg.RefreshName, true)
+	utils.ClearCookie(c, cookieCfg.CSRFName, false)
 	utils.SendSuccess(c, http.StatusOK, "Logout exitoso", nil)
 }
 
+// ForgotPassword envía el enlace de recuperación; responde igual exista o no el email
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(req.Email); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Si el email está registrado recibirá un enlace para restablecer la contraseña", nil)
+}
+
+// ResetPassword establece una nueva contraseña con el token recibido por correo
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	if err := h.accountService.ResetPassword(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Contraseña restablecida exitosamente", nil)
+}
+
+// VerifyEmail confirma el email con el token del enlace enviado por correo
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		utils.SendError(c, http.StatusBadRequest, "Token requerido")
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(token); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Email verificado exitosamente", nil)
+}
+
+// ResendVerification reenvía el correo de verificación
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req dto.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	if err := h.accountService.ResendVerification(req.Email); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Si el email está registrado y sin verificar recibirá un nuevo enlace", nil)
+}
+
 func (h *AuthHandler) GetProfile(c *gin.Context) {
 	userID, exists := middleware.GetCurrentUserID(c)
 	if !exists {
@@ -104,7 +251,21 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	utils.SendSuccess(c, http.StatusOK, "Perfil obtenido exitosamente", gin.H{"user": user})
+	permissions := []string{}
+	if claims, ok := middleware.GetCurrentUserClaims(c); ok {
+		permissions = services.PermissionsForClaims(claims)
+	}
+
+	var impersonation *dto.ImpersonationInfo
+	if claims, ok := middleware.GetCurrentUserClaims(c); ok {
+		impersonation = services.ImpersonationInfoFor(claims)
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Perfil obtenido exitosamente", gin.H{
+		"user":          user,
+		"permissions":   permissions,
+		"impersonation": impersonation,
+	})
 }
 
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
@@ -145,5 +306,65 @@ func (h *AuthHandler) CheckAuth(c *gin.Context) {
 			"role_id":   claims.RoleID,
 			"role_name": claims.RoleName,
 		},
+		"permissions":   services.PermissionsForClaims(claims),
+		"impersonation": services.ImpersonationInfoFor(claims),
 	})
-}
\ No newline at end of file
+}
+
+// StopImpersonation revoca el token de suplantación actual y borra la cookie de acceso;
+// el cliente recupera su propia sesión con /auth/refresh
+func (h *AuthHandler) StopImpersonation(c *gin.Context) {
+	claims, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "No autenticado")
+		return
+	}
+
+	if err := h.impersonationService.Stop(claims, clientInfo(c)); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.ClearCookie(c, config.Get().Cookie.AccessName, true)
+	utils.SendSuccess(c, http.StatusOK, "Suplantación terminada", nil)
+}
+
+// setAuthCookies guarda access y refresh token en cookies httpOnly y emite un token CSRF nuevo
+func setAuthCookies(c *gin.Context, authResponse *dto.AuthResponse) {
+	cookieCfg := config.Get().Cookie
+
+	accessTokenMaxAge := int(authResponse.ExpiresIn)
+	utils.SetCookie(c, cookieCfg.AccessName, authResponse.AccessToken, accessTokenMaxAge, true)
+	refreshTokenMaxAge := int(authResponse.RefreshExpiresIn)
+	utils.SetCookie(c, cookieCfg.RefreshName, authResponse.RefreshToken, refreshTokenMaxAge, true)
+
+	// El CSRF dura lo mismo que la sesión de refresh
+	middleware.IssueCSRFToken(c, refreshTokenMaxAge)
+}
+
+// CSRFToken emite un token CSRF para clientes que ya tienen sesión por cookies (ej. tras recargar la SPA).
+// Se guarda como cookie de sesión del navegador.
+func (h *AuthHandler) CSRFToken(c *gin.Context) {
+	token := middleware.IssueCSRFToken(c, 0)
+	utils.SendData(c, http.StatusOK, gin.H{
+		"csrf_token": token,
+		"header":     config.Get().Cookie.CSRFHeader,
+	})
+}
+
+// bearerToken devuelve el token de "Authorization: Bearer <token>"
+func bearerToken(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "Bearer ") {
+		return strings.TrimSpace(authHeader[7:])
+	}
+	return ""
+}
+
+// clientInfo extrae IP y user agent de la petición para registrarlos en la sesión
+func clientInfo(c *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/app/middleware"
 	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/filtering"
+	"megabaseGo/internal/pagination"
+	"megabaseGo/internal/utils"
 	"net/http"
 	"strconv"
 	"strings"
@@ -30,7 +35,15 @@ func (h *CitizenHandler) handleError(c *gin.Context, err error, defaultMessage s
 
 	errStr := err.Error()
 
-	if strings.Contains(errStr, "not found") {
+	// Errores tipados del servicio (ej. identificación inválida) traen su propio código HTTP
+	var apiErr *utils.APIError
+	if errors.As(err, &apiErr) {
+		statusCode = apiErr.StatusCode
+		errorMessage = http.StatusText(apiErr.StatusCode)
+		if statusCode == http.StatusBadRequest {
+			errorMessage = "Invalid request data"
+		}
+	} else if strings.Contains(errStr, "not found") {
 		statusCode = http.StatusNotFound
 		errorMessage = "Resource not found"
 	} else if strings.Contains(errStr, "already exists") ||
@@ -39,6 +52,9 @@ func (h *CitizenHandler) handleError(c *gin.Context, err error, defaultMessage s
 		strings.Contains(errStr, "requires") {
 		statusCode = http.StatusConflict // <-- El código correcto para conflictos de datos.
 		errorMessage = "Data validation error or conflict"
+	} else if strings.Contains(errStr, "outside your scope") {
+		statusCode = http.StatusForbidden
+		errorMessage = "Insufficient permissions"
 	}
 
 	c.JSON(statusCode, gin.H{
@@ -47,8 +63,36 @@ func (h *CitizenHandler) handleError(c *gin.Context, err error, defaultMessage s
 	})
 }
 
-// GetAllCitizens maneja GET /citizens con filtros opcionales
+// scope obtiene el alcance de datos del usuario autenticado; si no se puede resolver responde el error
+func (h *CitizenHandler) scope(c *gin.Context) (*services.CitizenScope, bool) {
+	claims, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return nil, false
+	}
+
+	scope, err := services.ResolveCitizenScope(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to resolve data scope",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return scope, true
+}
+
+// GetAllCitizens maneja GET /citizens con filtros opcionales (clásicos y filter[campo][operador]=),
+// paginación (page, page_size) y orden (sort)
+// Con ?cursor= o ?limit= pagina por cursor firmado, pensado para recorrer tablas grandes
 func (h *CitizenHandler) GetAllCitizens(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	var filters dto.CitizenSearchFilters
 	if err := c.ShouldBindQuery(&filters); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -58,7 +102,30 @@ func (h *CitizenHandler) GetAllCitizens(c *gin.Context) {
 		return
 	}
 
-	citizens, err := h.citizenService.GetAllCitizens(&filters)
+	conditions, err := filtering.Parse(c.Request.URL.Query(), services.CitizenFilterFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if pagination.IsCursorRequest(c.Request.URL.Query()) {
+		h.getCitizensByCursor(c, scope, &filters, conditions)
+		return
+	}
+
+	page, err := pagination.Parse(c.Request.URL.Query(), services.CitizenSorting)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	citizens, meta, err := h.citizenService.GetAllCitizens(scope, &filters, conditions, page)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to retrieve citizens",
@@ -67,16 +134,89 @@ func (h *CitizenHandler) GetAllCitizens(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    citizens,
-		"count":   len(citizens),
-		"filters": filters,
-	})
+	utils.SendPage(c, citizens, meta)
+}
+
+// getCitizensByCursor modo cursor de GET /citizens: next_cursor en la respuesta y en la cabecera Link.
+// Cada ciudadano confirmado antes de la primera página sale una sola vez aunque haya altas
+// concurrentes; uno confirmado durante el recorrido con un id o created_at menor que la última
+// página entregada no aparece en ese recorrido.
+func (h *CitizenHandler) getCitizensByCursor(c *gin.Context, scope *services.CitizenScope, filters *dto.CitizenSearchFilters, conditions filtering.Conditions) {
+	cursor, err := pagination.ParseCursor(c.Request.URL.Query(), services.CitizenKeyset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	citizens, meta, err := h.citizenService.GetCitizensByCursor(scope, filters, conditions, cursor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve citizens",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	utils.SendPage(c, citizens, meta)
+}
+
+// SearchCitizens maneja GET /citizens/search?q= con relevancia y fragmentos resaltados
+// Acepta los mismos filtros, paginación (page, page_size) y orden (sort, por defecto -relevance) que el listado
+func (h *CitizenHandler) SearchCitizens(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
+	var search dto.CitizenSearchQuery
+	if err := c.ShouldBindQuery(&search); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	conditions, err := filtering.Parse(c.Request.URL.Query(), services.CitizenFilterFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	page, err := pagination.Parse(c.Request.URL.Query(), services.CitizenSearchSorting)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	results, meta, err := h.citizenService.SearchCitizens(scope, &search, conditions, page)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to search citizens",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	utils.SendPage(c, results, meta)
 }
 
 // GetCitizenByID maneja GET /citizens/:id
 func (h *CitizenHandler) GetCitizenByID(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
@@ -87,7 +227,7 @@ func (h *CitizenHandler) GetCitizenByID(c *gin.Context) {
 		return
 	}
 
-	citizen, err := h.citizenService.GetCitizenByID(uint(id))
+	citizen, err := h.citizenService.GetCitizenByID(scope, uint(id))
 	if err != nil {
 		h.handleError(c, err, "Failed to retrieve citizen", http.StatusInternalServerError)
 		return
@@ -101,6 +241,11 @@ func (h *CitizenHandler) GetCitizenByID(c *gin.Context) {
 
 // GetCitizenByEmail maneja GET /citizens/email/:email
 func (h *CitizenHandler) GetCitizenByEmail(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	email := c.Param("email")
 
 	if email == "" || !strings.Contains(email, "@") {
@@ -111,7 +256,7 @@ func (h *CitizenHandler) GetCitizenByEmail(c *gin.Context) {
 		return
 	}
 
-	citizen, err := h.citizenService.GetCitizenByEmail(email)
+	citizen, err := h.citizenService.GetCitizenByEmail(scope, email)
 	if err != nil {
 		h.handleError(c, err, "Failed to retrieve citizen by email", http.StatusInternalServerError)
 		return
@@ -125,6 +270,11 @@ func (h *CitizenHandler) GetCitizenByEmail(c *gin.Context) {
 
 // GetCitizenByIdentification maneja GET /citizens/identification/:numero
 func (h *CitizenHandler) GetCitizenByIdentification(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	numero := c.Param("numero")
 
 	if numero == "" || len(numero) < 10 {
@@ -135,7 +285,7 @@ func (h *CitizenHandler) GetCitizenByIdentification(c *gin.Context) {
 		return
 	}
 
-	citizen, err := h.citizenService.GetCitizenByNumeroIdentificacion(numero)
+	citizen, err := h.citizenService.GetCitizenByNumeroIdentificacion(scope, numero)
 	if err != nil {
 		h.handleError(c, err, "Failed to retrieve citizen by identification", http.StatusInternalServerError)
 		return
@@ -149,6 +299,11 @@ func (h *CitizenHandler) GetCitizenByIdentification(c *gin.Context) {
 
 // GetCitizenByRazonSocial maneja GET /citizens/razon-social/:razon
 func (h *CitizenHandler) GetCitizenByRazonSocial(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	razonSocial := c.Param("razon")
 
 	if razonSocial == "" {
@@ -159,7 +314,7 @@ func (h *CitizenHandler) GetCitizenByRazonSocial(c *gin.Context) {
 		return
 	}
 
-	citizen, err := h.citizenService.GetCitizenByRazonSocial(razonSocial)
+	citizen, err := h.citizenService.GetCitizenByRazonSocial(scope, razonSocial)
 	if err != nil {
 		h.handleError(c, err, "Failed to retrieve citizen by razon social", http.StatusInternalServerError)
 		return
@@ -173,6 +328,11 @@ func (h *CitizenHandler) GetCitizenByRazonSocial(c *gin.Context) {
 
 // CreateCitizen maneja POST /citizens
 func (h *CitizenHandler) CreateCitizen(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	var req dto.CreateCitizenRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -183,7 +343,7 @@ func (h *CitizenHandler) CreateCitizen(c *gin.Context) {
 		return
 	}
 
-	citizen, err := h.citizenService.CreateCitizen(&req)
+	citizen, err := h.citizenService.CreateCitizen(scope, &req)
 	if err != nil {
 		h.handleError(c, err, "Failed to create citizen", http.StatusInternalServerError)
 		return
@@ -198,6 +358,11 @@ func (h *CitizenHandler) CreateCitizen(c *gin.Context) {
 
 // UpdateCitizen maneja PUT /citizens/:id
 func (h *CitizenHandler) UpdateCitizen(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
@@ -218,7 +383,7 @@ func (h *CitizenHandler) UpdateCitizen(c *gin.Context) {
 		return
 	}
 
-	citizen, err := h.citizenService.UpdateCitizen(uint(id), &req)
+	citizen, err := h.citizenService.UpdateCitizen(scope, uint(id), &req)
 	if err != nil {
 		h.handleError(c, err, "Failed to update citizen", http.StatusInternalServerError)
 		return
@@ -233,6 +398,11 @@ func (h *CitizenHandler) UpdateCitizen(c *gin.Context) {
 
 // DeleteCitizen maneja DELETE /citizens/:id
 func (h *CitizenHandler) DeleteCitizen(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
@@ -243,7 +413,7 @@ func (h *CitizenHandler) DeleteCitizen(c *gin.Context) {
 		return
 	}
 
-	err = h.citizenService.DeleteCitizen(uint(id))
+	err = h.citizenService.DeleteCitizen(scope, uint(id))
 	if err != nil {
 		h.handleError(c, err, "Failed to delete citizen", http.StatusInternalServerError)
 		return
@@ -259,6 +429,11 @@ func (h *CitizenHandler) DeleteCitizen(c *gin.Context) {
 
 // CheckIdentificationAvailability maneja GET /citizens/check/identification/:numero
 func (h *CitizenHandler) CheckIdentificationAvailability(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	numero := c.Param("numero")
 
 	if numero == "" || len(numero) < 10 {
@@ -268,8 +443,11 @@ func (h *CitizenHandler) CheckIdentificationAvailability(c *gin.Context) {
 		return
 	}
 
-	_, err := h.citizenService.GetCitizenByNumeroIdentificacion(numero)
-	available := err != nil && strings.Contains(err.Error(), "not found")
+	available, err := h.citizenService.CheckAvailability(scope, "numero_identificacion", numero)
+	if err != nil {
+		h.handleError(c, err, "Failed to check availability", http.StatusInternalServerError)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"available": available,
@@ -279,6 +457,11 @@ func (h *CitizenHandler) CheckIdentificationAvailability(c *gin.Context) {
 
 // CheckEmailAvailability maneja GET /citizens/check/email/:email
 func (h *CitizenHandler) CheckEmailAvailability(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	email := c.Param("email")
 
 	if email == "" || !strings.Contains(email, "@") {
@@ -288,8 +471,11 @@ func (h *CitizenHandler) CheckEmailAvailability(c *gin.Context) {
 		return
 	}
 
-	_, err := h.citizenService.GetCitizenByEmail(email)
-	available := err != nil && strings.Contains(err.Error(), "not found")
+	available, err := h.citizenService.CheckAvailability(scope, "email", email)
+	if err != nil {
+		h.handleError(c, err, "Failed to check availability", http.StatusInternalServerError)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"available": available,
@@ -299,6 +485,11 @@ func (h *CitizenHandler) CheckEmailAvailability(c *gin.Context) {
 
 // CheckRazonSocialAvailability maneja GET /citizens/check/razon-social/:razon
 func (h *CitizenHandler) CheckRazonSocialAvailability(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		return
+	}
+
 	razonSocial := c.Param("razon")
 
 	if razonSocial == "" {
@@ -308,8 +499,11 @@ func (h *CitizenHandler) CheckRazonSocialAvailability(c *gin.Context) {
 		return
 	}
 
-	_, err := h.citizenService.GetCitizenByRazonSocial(razonSocial)
-	available := err != nil && strings.Contains(err.Error(), "not found")
+	available, err := h.citizenService.CheckAvailability(scope, "razon_social", razonSocial)
+	if err != nil {
+		h.handleError(c, err, "Failed to check availability", http.StatusInternalServerError)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"available":    available,
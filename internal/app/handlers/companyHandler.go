@@ -5,6 +5,9 @@ import (
 	"megabaseGo/internal/app/dto"
 	app_errors "megabaseGo/internal/app/errors"
 	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/filtering"
+	"megabaseGo/internal/pagination"
+	"megabaseGo/internal/utils"
 	"net/http"
 	"strconv"
 
@@ -119,15 +122,20 @@ func (h *CompanyHandler) GetCompanies(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
 		return
 	}
-	companies, err := h.svc.GetCompanies(&filters)
+	conditions, err := filtering.Parse(c.Request.URL.Query(), services.CompanyFilterFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	page, err := pagination.Parse(c.Request.URL.Query(), services.CompanySorting)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	companies, meta, err := h.svc.GetCompanies(&filters, conditions, page)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    companies,
-		"count":   len(companies),
-		"filters": filters,
-	})
+	utils.SendPage(c, companies, meta)
 }
\ No newline at end of file
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/app/middleware"
+	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+	authService       *services.AuthService
+}
+
+func NewInvitationHandler() *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: services.NewInvitationService(),
+		authService:       services.NewAuthService(),
+	}
+}
+
+// CreateInvitation invita a un email con el rol indicado y envía el enlace por correo
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	inviter, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	var req dto.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(inviter, &req)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusCreated, "Invitación enviada", gin.H{"invitation": invitation})
+}
+
+// GetInvitations lista las invitaciones; por defecto solo las pendientes (?status=all para todas)
+func (h *InvitationHandler) GetInvitations(c *gin.Context) {
+	invitations, err := h.invitationService.GetInvitations(c.Query("status"))
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{
+		"invitations": invitations,
+		"count":       len(invitations),
+	})
+}
+
+// ResendInvitation genera un nuevo enlace y lo reenvía; el anterior queda invalidado
+func (h *InvitationHandler) ResendInvitation(c *gin.Context) {
+	inviter, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	invitationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de invitación inválido"))
+		return
+	}
+
+	invitation, err := h.invitationService.ResendInvitation(inviter, uint(invitationID))
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Invitación reenviada", gin.H{"invitation": invitation})
+}
+
+// RevokeInvitation revoca una invitación pendiente
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	invitationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de invitación inválido"))
+		return
+	}
+
+	if err := h.invitationService.RevokeInvitation(userID, uint(invitationID)); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Invitación revocada", nil)
+}
+
+// PreviewInvitation muestra el email y rol de la invitación antes de aceptarla (pública)
+func (h *InvitationHandler) PreviewInvitation(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		utils.SendError(c, http.StatusBadRequest, "Token requerido")
+		return
+	}
+
+	invitation, err := h.invitationService.PreviewInvitation(token)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{"invitation": invitation})
+}
+
+// AcceptInvitation crea la cuenta con el rol de la invitación e inicia sesión (pública)
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req dto.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	authResponse, err := h.authService.AcceptInvitation(&req, clientInfo(c))
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	setAuthCookies(c, authResponse)
+
+	utils.SendSuccess(c, http.StatusCreated, "Cuenta creada exitosamente", gin.H{"user": authResponse.User})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"megabaseGo/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publica las claves públicas de verificación de tokens
+type JWKSHandler struct{}
+
+func NewJWKSHandler() *JWKSHandler {
+	return &JWKSHandler{}
+}
+
+// GetJWKS maneja GET /.well-known/jwks.json.
+// Devuelve el documento JWKS estándar (sin el envoltorio de JsonResponse) para que
+// otros servicios puedan verificar los tokens sin compartir secretos.
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	keys, err := utils.CurrentKeySet()
+	if err != nil {
+		utils.SendError(c, http.StatusInternalServerError, "Claves de firma no disponibles")
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(utils.JWKSMaxAge.Seconds())))
+	c.JSON(http.StatusOK, keys.JWKS())
+}
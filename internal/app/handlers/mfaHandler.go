@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/app/middleware"
+	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	mfaService *services.MFAService
+}
+
+func NewMFAHandler() *MFAHandler {
+	return &MFAHandler{
+		mfaService: services.NewMFAService(),
+	}
+}
+
+// EnrollTOTP genera el secreto TOTP y el QR para la app autenticadora
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	enrollment, err := h.mfaService.EnrollTOTP(userID)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Escanee el código QR y confirme con un código", enrollment)
+}
+
+// ConfirmTOTP activa TOTP y devuelve los códigos de recuperación
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	var req dto.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmTOTP(userID, req.Code)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "TOTP activado exitosamente", codes)
+}
+
+// DisableTOTP desactiva TOTP del usuario actual
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	var req dto.DisableTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	if err := h.mfaService.DisableTOTP(userID, &req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "TOTP desactivado exitosamente", nil)
+}
+
+// RegenerateRecoveryCodes reemplaza los códigos de recuperación
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	var req dto.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Códigos de recuperación regenerados", codes)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// oidcStateCookie liga el callback al navegador que inició el login
+const (
+	oidcStateCookie       = "oidc_state"
+	oidcStateCookieMaxAge = 600
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+}
+
+func NewOIDCHandler() *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: services.NewOIDCService(),
+	}
+}
+
+// GetProviders lista los proveedores de SSO configurados
+func (h *OIDCHandler) GetProviders(c *gin.Context) {
+	providers := h.oidcService.GetProviders()
+	utils.SendData(c, http.StatusOK, gin.H{
+		"providers": providers,
+		"count":     len(providers),
+	})
+}
+
+// Login redirige al proveedor. ?return_to=/ruta indica a qué ruta del front volver tras el login.
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, state, err := h.oidcService.BeginLogin(c.Request.Context(), c.Param("provider"), c.Query("return_to"))
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	setOIDCStateCookie(c, state, oidcStateCookieMaxAge)
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback recibe la respuesta del proveedor, abre la sesión con cookies y vuelve al front.
+// Los errores también vuelven al front (/login?error=oidc&message=...) porque es una navegación del navegador.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	provider := c.Param("provider")
+	cookieState := utils.GetCookie(c, oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	c.Header("Cache-Control", "no-store")
+
+	// El usuario canceló o el proveedor rechazó la autorización
+	if idpError := c.Query("error"); idpError != "" {
+		logger.Debug.WithFields(logrus.Fields{
+			"provider":    provider,
+			"error":       idpError,
+			"description": c.Query("error_description"),
+		}).Warn("El proveedor OIDC devolvió un error")
+		redirectToFront(c, "/login", url.Values{"error": {"oidc"}, "message": {"El proveedor rechazó el inicio de sesión"}}, "")
+		return
+	}
+
+	result, err := h.oidcService.CompleteLogin(c.Request.Context(), provider, c.Query("state"), cookieState, c.Query("code"), clientInfo(c))
+	if err != nil {
+		message := "No se pudo iniciar sesión"
+		if apiErr, ok := err.(*utils.APIError); ok {
+			message = apiErr.Message
+		}
+		redirectToFront(c, "/login", url.Values{"error": {"oidc"}, "message": {message}}, "")
+		return
+	}
+
+	// Con TOTP activo el front completa el segundo paso; el desafío va en el fragmento para no llegar a logs
+	if result.Auth.MFARequired {
+		redirectToFront(c, "/login/mfa", url.Values{"return_to": {result.ReturnTo}}, "mfa_token="+url.QueryEscape(result.Auth.MFAToken))
+		return
+	}
+
+	setAuthCookies(c, result.Auth)
+	redirectToFront(c, result.ReturnTo, nil, "")
+}
+
+// setOIDCStateCookie la cookie debe viajar en la redirección desde el proveedor (navegación entre sitios):
+// con SameSite=Strict configurado se usa Lax solo para ella
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	cfg := config.Get().Cookie
+	sameSite := cfg.SameSite
+	if sameSite == http.SameSiteStrictMode {
+		sameSite = http.SameSiteLaxMode
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cfg.Name(oidcStateCookie),
+		Value:    value,
+		MaxAge:   maxAge,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+// redirectToFront redirige a una ruta del front (FRONT_URL)
+func redirectToFront(c *gin.Context, path string, query url.Values, fragment string) {
+	target := strings.TrimRight(config.Get().FrontURL, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	if fragment != "" {
+		target += "#" + fragment
+	}
+	c.Redirect(http.StatusFound, target)
+}
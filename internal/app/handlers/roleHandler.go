@@ -5,19 +5,23 @@ import (
 	"strconv"
 
 	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/app/middleware"
 	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/pagination"
 	"megabaseGo/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type RoleHandler struct {
-	roleService *services.RoleService
+	roleService       *services.RoleService
+	permissionService *services.PermissionService
 }
 
 func NewRoleHandler() *RoleHandler {
 	return &RoleHandler{
-		roleService: services.NewRoleService(),
+		roleService:       services.NewRoleService(),
+		permissionService: services.NewPermissionService(),
 	}
 }
 
@@ -41,17 +45,20 @@ func (h *RoleHandler) CreateRole(c *gin.Context) {
 func (h *RoleHandler) GetRoles(c *gin.Context) {
 	includeInactive := c.Query("include_inactive") == "true"
 
-	roles, err := h.roleService.GetRoles(includeInactive)
+	page, err := pagination.Parse(c.Request.URL.Query(), services.RoleSorting)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError(err.Error()))
+		return
+	}
+
+	roles, meta, err := h.roleService.GetRoles(includeInactive, page)
 	if err != nil {
 		utils.HandleGinError(c, err)
 		return
 	}
 
-	// GET: Solo status y data (sin mensaje)
-	utils.SendData(c, http.StatusOK, gin.H{
-		"roles": roles,
-		"count": len(roles),
-	})
+	// GET paginado: data + metadatos de paginación y cabecera Link
+	utils.SendPage(c, roles, meta)
 }
 
 func (h *RoleHandler) GetRole(c *gin.Context) {
@@ -112,4 +119,116 @@ func (h *RoleHandler) DeleteRole(c *gin.Context) {
 
 	// DELETE: Mantiene mensaje
 	utils.SendSuccess(c, http.StatusOK, "Rol eliminado correctamente", nil)
-}
\ No newline at end of file
+}
+
+// GetPermissions lista el catálogo de permisos disponibles
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	permissions, err := h.permissionService.GetPermissions()
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{
+		"permissions": permissions,
+		"count":       len(permissions),
+	})
+}
+
+// GetRolePermissions lista los permisos del rol
+func (h *RoleHandler) GetRolePermissions(c *gin.Context) {
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	permissions, err := h.permissionService.GetRolePermissions(roleID)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{"permissions": permissions})
+}
+
+// SetRolePermissions reemplaza los permisos del rol
+func (h *RoleHandler) SetRolePermissions(c *gin.Context) {
+	actor, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.RolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	permissions, err := h.permissionService.SetRolePermissions(actor, roleID, req.Permissions)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Permisos del rol actualizados", gin.H{"permissions": permissions})
+}
+
+// AddRolePermissions agrega permisos al rol
+func (h *RoleHandler) AddRolePermissions(c *gin.Context) {
+	actor, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.RolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	permissions, err := h.permissionService.AddRolePermissions(actor, roleID, req.Permissions)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Permisos agregados al rol", gin.H{"permissions": permissions})
+}
+
+// RemoveRolePermission quita un permiso del rol
+func (h *RoleHandler) RemoveRolePermission(c *gin.Context) {
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	permissions, err := h.permissionService.RemoveRolePermission(roleID, c.Param("permission"))
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Permiso quitado del rol", gin.H{"permissions": permissions})
+}
+
+// parseRoleID lee el :id de la ruta; si es inválido responde el error
+func parseRoleID(c *gin.Context) (uint, bool) {
+	roleID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de rol inválido"))
+		return 0, false
+	}
+	return uint(roleID), true
+}
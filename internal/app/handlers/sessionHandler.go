@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"megabaseGo/internal/app/middleware"
+	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService    *services.SessionService
+	loginEventService *services.LoginEventService
+	userService       *services.UserService
+}
+
+func NewSessionHandler() *SessionHandler {
+	return &SessionHandler{
+		sessionService:    services.NewSessionService(),
+		loginEventService: services.NewLoginEventService(),
+		userService:       services.NewUserService(),
+	}
+}
+
+// GetMySessions lista las sesiones activas del usuario actual, marcando la de esta petición
+func (h *SessionHandler) GetMySessions(c *gin.Context) {
+	claims, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	sessions, err := h.sessionService.GetUserSessions(claims.UserID, claims.SessionID)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// RevokeMySession cierra una sesión del usuario actual (ej. un dispositivo perdido)
+func (h *SessionHandler) RevokeMySession(c *gin.Context) {
+	claims, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de sesión inválido"))
+		return
+	}
+
+	if err := h.sessionService.RevokeUserSession(claims.UserID, uint(sessionID), "user_revoked"); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Sesión cerrada correctamente", nil)
+}
+
+// SignOutEverywhere cierra todas las sesiones del usuario actual, incluida esta
+func (h *SessionHandler) SignOutEverywhere(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	if err := h.sessionService.SignOutEverywhere(userID, "logout_all"); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	cookieCfg := config.Get().Cookie
+	utils.ClearCookie(c, cookieCfg.AccessName, true)
+	utils.ClearCookie(c, cookieCfg.RefreshName, true)
+	utils.ClearCookie(c, cookieCfg.CSRFName, false)
+	utils.SendSuccess(c, http.StatusOK, "Se cerraron todas las sesiones", nil)
+}
+
+// GetMyLoginHistory últimos intentos de login de la cuenta del usuario actual (?limit=, máximo 200)
+func (h *SessionHandler) GetMyLoginHistory(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	events, err := h.loginEventService.GetUserLoginEvents(userID, limit)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+// GetUserSessions lista las sesiones activas de un usuario (admin)
+func (h *SessionHandler) GetUserSessions(c *gin.Context) {
+	userID, ok := h.targetUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionService.GetUserSessions(userID, "")
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// RevokeUserSession cierra una sesión de un usuario (admin)
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	userID, ok := h.targetUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("session_id"), 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de sesión inválido"))
+		return
+	}
+
+	if err := h.sessionService.RevokeUserSession(userID, uint(sessionID), "admin_revoked"); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Sesión cerrada correctamente", nil)
+}
+
+// SignOutUserEverywhere cierra todas las sesiones de un usuario (admin)
+func (h *SessionHandler) SignOutUserEverywhere(c *gin.Context) {
+	userID, ok := h.targetUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionService.SignOutEverywhere(userID, "admin_logout_all"); err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Se cerraron todas las sesiones del usuario", nil)
+}
+
+// GetUserLoginHistory últimos intentos de login de un usuario (admin)
+func (h *SessionHandler) GetUserLoginHistory(c *gin.Context) {
+	userID, ok := h.targetUserID(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	events, err := h.loginEventService.GetUserLoginEvents(userID, limit)
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendData(c, http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+// targetUserID lee el :id de la ruta y verifica que el usuario exista; si no, ya respondió el error
+func (h *SessionHandler) targetUserID(c *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de usuario inválido"))
+		return 0, false
+	}
+
+	if _, err := h.userService.GetUserByID(uint(userID)); err != nil {
+		utils.HandleGinError(c, err)
+		return 0, false
+	}
+	return uint(userID), true
+}
@@ -6,30 +6,42 @@ import (
 	"strings"
 
 	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/app/middleware"
 	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/filtering"
+	"megabaseGo/internal/pagination"
 	"megabaseGo/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService          *services.UserService
+	impersonationService *services.ImpersonationService
 }
 
 func NewUserHandler() *UserHandler {
 	return &UserHandler{
-		userService: services.NewUserService(),
+		userService:          services.NewUserService(),
+		impersonationService: services.NewImpersonationService(),
 	}
 }
 
 func (h *UserHandler) CreateUser(c *gin.Context) {
+	actor, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
 	var req dto.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.HandleGinError(c, err)
 		return
 	}
 
-	user, err := h.userService.CreateUser(&req)
+	user, err := h.userService.CreateUser(actor, &req)
 	if err != nil {
 		utils.HandleGinError(c, err)
 		return
@@ -49,17 +61,26 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		}
 	}
 
-	users, err := h.userService.GetUsers(includeInactive, roleID)
+	conditions, err := filtering.Parse(c.Request.URL.Query(), services.UserFilterFields)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError(err.Error()))
+		return
+	}
+
+	page, err := pagination.Parse(c.Request.URL.Query(), services.UserSorting)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError(err.Error()))
+		return
+	}
+
+	users, meta, err := h.userService.GetUsers(includeInactive, roleID, conditions, page)
 	if err != nil {
 		utils.HandleGinError(c, err)
 		return
 	}
 
-	// GET: Solo status y data (sin mensaje)
-	utils.SendData(c, http.StatusOK, gin.H{
-		"users": users,
-		"count": len(users),
-	})
+	// GET paginado: data + metadatos de paginación y cabecera Link
+	utils.SendPage(c, users, meta)
 }
 
 func (h *UserHandler) GetUser(c *gin.Context) {
@@ -81,6 +102,12 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 }
 
 func (h *UserHandler) UpdateUser(c *gin.Context) {
+	actor, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
 	id := c.Param("id")
 	userID, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
@@ -94,7 +121,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.UpdateUser(uint(userID), &req)
+	user, err := h.userService.UpdateUser(actor, uint(userID), &req)
 	if err != nil {
 		utils.HandleGinError(c, err)
 		return
@@ -122,6 +149,52 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	utils.SendSuccess(c, http.StatusOK, "Usuario eliminado correctamente", nil)
 }
 
+// UnlockUser quita el bloqueo por intentos de login fallidos (solo admin)
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	id := c.Param("id")
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de usuario inválido"))
+		return
+	}
+
+	user, err := h.userService.UnlockUser(uint(userID))
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SendSuccess(c, http.StatusOK, "Usuario desbloqueado correctamente", gin.H{"user": user})
+}
+
+// Impersonate emite un token de corta duración para actuar como el usuario indicado.
+// Se entrega en el cuerpo y en la cookie de acceso; la cookie de refresh del actor no se toca,
+// así que al refrescar (o tras stop-impersonation) vuelve a su propia sesión.
+func (h *UserHandler) Impersonate(c *gin.Context) {
+	id := c.Param("id")
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		utils.HandleGinError(c, utils.NewBadRequestError("ID de usuario inválido"))
+		return
+	}
+
+	actor, exists := middleware.GetCurrentUserClaims(c)
+	if !exists {
+		utils.SendError(c, http.StatusUnauthorized, "Usuario no autenticado")
+		return
+	}
+
+	response, err := h.impersonationService.Start(actor, uint(userID), clientInfo(c))
+	if err != nil {
+		utils.HandleGinError(c, err)
+		return
+	}
+
+	utils.SetCookie(c, config.Get().Cookie.AccessName, response.AccessToken, int(response.ExpiresIn), true)
+	c.Header("Cache-Control", "no-store")
+	utils.SendSuccess(c, http.StatusOK, "Suplantación iniciada", response)
+}
+
 // CheckUsernameAvailability maneja la verificación de username
 func (h *UserHandler) CheckUsernameAvailability(c *gin.Context) {
 	username := c.Query("username")
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditImpersonation registra cada petición hecha con un token de suplantación, incluidas
+// las rechazadas. Va antes de Authorize para ver el resultado final de la petición.
+func AuditImpersonation() gin.HandlerFunc {
+	audit := services.NewAuditService()
+
+	return func(c *gin.Context) {
+		c.Next()
+
+		claims, exists := GetCurrentUserClaims(c)
+		if !exists || !claims.IsImpersonated() {
+			return
+		}
+
+		audit.Record(&models.AuditLog{
+			ActorID:    claims.Act.UserID,
+			UserID:     claims.UserID,
+			Action:     services.AuditImpersonatedRequest,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			StatusCode: c.Writer.Status(),
+			TokenID:    claims.ID,
+			IPAddress:  c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+		})
+	}
+}
@@ -2,9 +2,10 @@ package middleware
 
 import (
 	"net/http"
-	// "strings" // Ya no es necesario, lo podemos quitar
+	"strings"
 
 	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/config"
 	"megabaseGo/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -12,78 +13,169 @@ import (
 
 // AuthMiddleware maneja la autenticación JWT
 type AuthMiddleware struct {
-	authService *services.AuthService
+	authService   *services.AuthService
+	apiKeyService *services.APIKeyService
+	extractToken  TokenExtractor
 }
 
 // NewAuthMiddleware crea una nueva instancia del middleware de autenticación
 func NewAuthMiddleware() *AuthMiddleware {
 	return &AuthMiddleware{
-		authService: services.NewAuthService(),
+		authService:   services.NewAuthService(),
+		apiKeyService: services.NewAPIKeyService(),
+		extractToken:  NewTokenExtractorChain(config.Get().AuthTokenSources),
 	}
 }
 
-// RequireAuth middleware que requiere autenticación (MODIFICADO)
+// RequireAuth middleware que requiere autenticación (API key o access token)
 func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// ---- INICIO DEL CAMBIO ----
-		// Ahora leemos el token desde la cookie "access_token"
-		tokenString, err := c.Cookie("access_token")
-
-		// Si hay un error (ej. la cookie no existe), devolvemos un error de no autorizado
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization token required",
-			})
-			c.Abort()
+		if !m.authenticate(c) {
 			return
 		}
-		// ---- FIN DEL CAMBIO ----
-
-		// El resto de la lógica para validar el token y guardar los datos en el contexto
-		// se mantiene exactamente igual.
-		claims, err := m.authService.ValidateToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid or expired token",
-			})
-			c.Abort()
-			return
+
+		c.Next()
+	}
+}
+
+// authenticate valida las credenciales de la petición y guarda el usuario en el contexto.
+// Si fallan responde y aborta; no llama a c.Next para que RequireRole pueda verificar el rol antes del handler.
+func (m *AuthMiddleware) authenticate(c *gin.Context) bool {
+	// Integraciones: API key en "Authorization: Bearer mbk_..." o "X-API-Key"
+	if rawKey := apiKeyFromRequest(c); rawKey != "" {
+		return m.authenticateAPIKey(c, rawKey)
+	}
+
+	// Access token según AUTH_TOKEN_SOURCES (header, cookie, query)
+	tokenString := m.extractToken(c)
+	if tokenString == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authorization token required",
+		})
+		c.Abort()
+		return false
+	}
+
+	claims, err := m.authService.ValidateToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired token",
+		})
+		c.Abort()
+		return false
+	}
+
+	setAuthContext(c, claims)
+	return true
+}
+
+// authenticateAPIKey valida la API key y que tenga el scope del recurso solicitado
+func (m *AuthMiddleware) authenticateAPIKey(c *gin.Context, rawKey string) bool {
+	apiKey, err := m.apiKeyService.Authenticate(rawKey, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid, expired or revoked API key",
+		})
+		c.Abort()
+		return false
+	}
+
+	scope, ok := apiKeyScopeFor(c)
+	if !ok || !services.APIKeyHasScope(apiKey, scope) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "API key not allowed for this resource",
+			"scope": scope,
+		})
+		c.Abort()
+		return false
+	}
+
+	user := apiKey.User
+	setAuthContext(c, &utils.JWTClaims{
+		UserID:   user.ID,
+		UserName: user.UserName,
+		Email:    user.Email,
+		RoleID:   user.RoleID,
+		RoleName: user.Role.Name,
+		AMR:      []string{"api_key"},
+	})
+	c.Set("api_key_id", apiKey.ID)
+	return true
+}
+
+// setAuthContext guarda los datos del usuario autenticado en el contexto
+func setAuthContext(c *gin.Context, claims *utils.JWTClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("user_name", claims.UserName)
+	c.Set("email", claims.Email)
+	c.Set("role_id", claims.RoleID)
+	c.Set("role_name", claims.RoleName)
+	c.Set("claims", claims)
+}
+
+// apiKeyFromRequest extrae la API key de X-API-Key o de un Bearer con el prefijo de API key
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader("X-API-Key")); key != "" {
+		return key
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	if len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "Bearer ") {
+		if token := strings.TrimSpace(authHeader[7:]); strings.HasPrefix(token, services.APIKeyPrefix) {
+			return token
 		}
+	}
+	return ""
+}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("user_name", claims.UserName)
-		c.Set("email", claims.Email)
-		c.Set("role_id", claims.RoleID)
-		c.Set("role_name", claims.RoleName)
-		c.Set("claims", claims)
+// apiKeyScopeFor arma el scope requerido "<recurso>:read|write" a partir de la ruta y el método.
+// El recurso es el primer segmento después de /api/v1.
+func apiKeyScopeFor(c *gin.Context) (string, bool) {
+	path := strings.TrimPrefix(c.FullPath(), "/api/v1/")
+	resource := strings.SplitN(path, "/", 2)[0]
+	if resource == "" {
+		return "", false
+	}
 
-		c.Next()
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return resource + ":read", true
+	default:
+		return resource + ":write", true
 	}
 }
 
-// RequireRole middleware que requiere un rol específico (SIN CAMBIOS)
-// Funciona correctamente porque llama internamente a la nueva versión de RequireAuth.
-func (m *AuthMiddleware) RequireRole(roleName string) gin.HandlerFunc {
+// IsAPIKeyRequest indica si la petición se autenticó con una API key
+func IsAPIKeyRequest(c *gin.Context) bool {
+	_, exists := c.Get("api_key_id")
+	return exists
+}
+
+// RequireMFAEnrollment bloquea a los usuarios cuyo rol exige MFA y todavía no lo activaron.
+// Debe ir después de RequireAuth; las rutas de enrolamiento MFA quedan fuera de este middleware.
+func (m *AuthMiddleware) RequireMFAEnrollment() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		m.RequireAuth()(c)
-		if c.IsAborted() {
+		if !checkMFAEnrollment(c) {
 			return
 		}
 
-		userRoleName, exists := c.Get("role_name")
-		if !exists {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Role information not found",
-			})
-			c.Abort()
+		c.Next()
+	}
+}
+
+// RequireRole middleware que requiere un rol específico
+func (m *AuthMiddleware) RequireRole(roleName string) gin.HandlerFunc {
+	return m.RequireAnyRole(roleName)
+}
+
+// RequireAnyRole middleware que requiere uno de varios roles
+func (m *AuthMiddleware) RequireAnyRole(roleNames ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Si un middleware anterior ya autenticó no se repite la validación
+		if !IsAuthenticated(c) && !m.authenticate(c) {
 			return
 		}
-
-		if userRoleName != roleName {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Insufficient permissions",
-			})
-			c.Abort()
+		if !checkAnyRole(c, roleNames) {
 			return
 		}
 
@@ -91,65 +183,119 @@ func (m *AuthMiddleware) RequireRole(roleName string) gin.HandlerFunc {
 	}
 }
 
-// RequireAnyRole middleware que requiere uno de varios roles (SIN CAMBIOS)
-func (m *AuthMiddleware) RequireAnyRole(roleNames ...string) gin.HandlerFunc {
+// RequirePermission middleware que requiere que el rol del usuario tenga todos los permisos indicados
+func (m *AuthMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		m.RequireAuth()(c)
-		if c.IsAborted() {
+		// Si un middleware anterior ya autenticó no se repite la validación
+		if !IsAuthenticated(c) && !m.authenticate(c) {
 			return
 		}
-
-		userRoleName, exists := c.Get("role_name")
-		if !exists {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Role information not found",
-			})
-			c.Abort()
+		if !checkPermissions(c, permissions) {
 			return
 		}
 
-		roleMatches := false
-		for _, roleName := range roleNames {
-			if userRoleName == roleName {
-				roleMatches = true
-				break
-			}
-		}
+		c.Next()
+	}
+}
 
-		if !roleMatches {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Insufficient permissions",
-			})
-			c.Abort()
-			return
+// checkMFAEnrollment responde 403 si el rol exige MFA y el usuario no lo activó
+func checkMFAEnrollment(c *gin.Context) bool {
+	claims, exists := GetCurrentUserClaims(c)
+	if exists && claims.MFAEnrollment {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                   "MFA enrollment required",
+			"mfa_enrollment_required": true,
+		})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// checkNotImpersonated responde 403 si la petición usa un token de suplantación
+func checkNotImpersonated(c *gin.Context) bool {
+	claims, exists := GetCurrentUserClaims(c)
+	if exists && claims.IsImpersonated() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":         "Not allowed while impersonating",
+			"impersonating": true,
+		})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// checkAnyRole responde 403 si el usuario autenticado no tiene ninguno de los roles
+func checkAnyRole(c *gin.Context, roleNames []string) bool {
+	userRoleName, exists := c.Get("role_name")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Role information not found",
+		})
+		c.Abort()
+		return false
+	}
+
+	for _, roleName := range roleNames {
+		if userRoleName == roleName {
+			return true
 		}
+	}
 
-		c.Next()
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "Insufficient permissions",
+	})
+	c.Abort()
+	return false
+}
+
+// checkPermissions responde 403 si el rol del usuario autenticado no tiene todos los permisos
+func checkPermissions(c *gin.Context, permissions []string) bool {
+	roleID, exists := c.Get("role_id")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Role information not found",
+		})
+		c.Abort()
+		return false
+	}
+
+	allowed, err := services.GetPermissionCache().HasPermissions(roleID.(uint), permissions...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Could not resolve permissions",
+		})
+		c.Abort()
+		return false
+	}
+
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":    "Insufficient permissions",
+			"required": permissions,
+		})
+		c.Abort()
+		return false
 	}
+	return true
 }
 
-// OptionalAuth middleware que permite autenticación opcional (MODIFICADO)
+// OptionalAuth middleware que permite autenticación opcional
 func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// ---- INICIO DEL CAMBIO ----
-		// Intentamos leer la cookie, pero no devolvemos un error si no existe.
-		tokenString, err := c.Cookie("access_token")
-		if err != nil {
+		// Intentamos leer el token, pero no devolvemos un error si no existe.
+		tokenString := m.extractToken(c)
+		if tokenString == "" {
 			// No hay token, pero la autenticación es opcional, así que continuamos.
 			c.Next()
 			return
 		}
-		// ---- FIN DEL CAMBIO ----
 
 		// Si encontramos un token, intentamos validarlo.
 		if claims, err := m.authService.ValidateToken(tokenString); err == nil {
 			// Token válido, guardamos la información en el contexto.
-			c.Set("user_id", claims.UserID)
-			c.Set("user_name", claims.UserName)
-			c.Set("email", claims.Email)
-			c.Set("role_id", claims.RoleID)
-			c.Set("role_name", claims.RoleName)
-			c.Set("claims", claims)
+			setAuthContext(c, claims)
 			c.Set("authenticated", true)
 		}
 
@@ -179,4 +325,4 @@ func GetCurrentUserClaims(c *gin.Context) (*utils.JWTClaims, bool) {
 func IsAuthenticated(c *gin.Context) bool {
 	_, exists := c.Get("user_id")
 	return exists
-}
\ No newline at end of file
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoutePolicy requisitos de acceso de una ruta (método + patrón de Gin, ej. "GET /api/v1/users/:id").
+// Sin Public, Roles ni Permissions la ruta solo exige estar autenticado.
+type RoutePolicy struct {
+	Method string
+	Path   string
+	// Public no exige autenticación
+	Public bool
+	// Roles el usuario debe tener alguno de ellos
+	Roles []string
+	// Permissions el rol del usuario debe tener todos
+	Permissions []string
+	// AllowMFAPending permite el acceso aunque el rol exija MFA y el usuario aún no lo haya activado
+	AllowMFAPending bool
+	// DenyImpersonation rechaza tokens de suplantación (credenciales y acciones sobre la propia cuenta)
+	DenyImpersonation bool
+}
+
+// Requirement describe el requisito en texto, para la matriz de políticas
+func (p RoutePolicy) Requirement() string {
+	if p.Public {
+		return "public"
+	}
+
+	var parts []string
+	if len(p.Roles) > 0 {
+		parts = append(parts, "role:"+strings.Join(p.Roles, "|"))
+	}
+	if len(p.Permissions) > 0 {
+		parts = append(parts, strings.Join(p.Permissions, "+"))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "authenticated")
+	}
+	if p.AllowMFAPending {
+		parts = append(parts, "(mfa pending ok)")
+	}
+	if p.DenyImpersonation {
+		parts = append(parts, "(no impersonation)")
+	}
+	return strings.Join(parts, " ")
+}
+
+func policyKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// PolicyTable políticas de todas las rutas indexadas por método y ruta
+type PolicyTable struct {
+	policies map[string]RoutePolicy
+}
+
+// NewPolicyTable arma la tabla; falla si hay políticas duplicadas o mal definidas
+func NewPolicyTable(policies []RoutePolicy) (*PolicyTable, error) {
+	table := &PolicyTable{policies: make(map[string]RoutePolicy, len(policies))}
+
+	var errs []error
+	for _, p := range policies {
+		key := policyKey(p.Method, p.Path)
+		if p.Method == "" || !strings.HasPrefix(p.Path, "/") {
+			errs = append(errs, fmt.Errorf("política inválida: %q", key))
+			continue
+		}
+		if p.Public && (len(p.Roles) > 0 || len(p.Permissions) > 0) {
+			errs = append(errs, fmt.Errorf("política %s: una ruta pública no puede exigir roles ni permisos", key))
+		}
+		if _, exists := table.policies[key]; exists {
+			errs = append(errs, fmt.Errorf("política duplicada: %s", key))
+			continue
+		}
+		p.Method = strings.ToUpper(p.Method)
+		table.policies[key] = p
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Lookup busca la política de una ruta registrada
+func (t *PolicyTable) Lookup(method, path string) (RoutePolicy, bool) {
+	p, ok := t.policies[policyKey(method, path)]
+	return p, ok
+}
+
+// Validate verifica que toda ruta registrada tenga política, que no queden políticas de rutas
+// inexistentes y que los permisos usados estén en el catálogo
+func (t *PolicyTable) Validate(routes gin.RoutesInfo, knownPermissions []string) error {
+	known := make(map[string]bool, len(knownPermissions))
+	for _, p := range knownPermissions {
+		known[p] = true
+	}
+
+	var errs []error
+	registered := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		key := policyKey(route.Method, route.Path)
+		registered[key] = true
+		if _, ok := t.policies[key]; !ok {
+			errs = append(errs, fmt.Errorf("ruta sin política de autorización: %s", key))
+		}
+	}
+
+	for _, key := range t.keys() {
+		if !registered[key] {
+			errs = append(errs, fmt.Errorf("política para una ruta no registrada: %s", key))
+		}
+		for _, permission := range t.policies[key].Permissions {
+			if !known[permission] {
+				errs = append(errs, fmt.Errorf("política %s: permiso desconocido %q", key, permission))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Policies devuelve las políticas ordenadas por ruta y método
+func (t *PolicyTable) Policies() []RoutePolicy {
+	keys := t.keys()
+	policies := make([]RoutePolicy, 0, len(keys))
+	for _, key := range keys {
+		policies = append(policies, t.policies[key])
+	}
+	return policies
+}
+
+func (t *PolicyTable) keys() []string {
+	keys := make([]string, 0, len(t.policies))
+	for key := range t.policies {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := t.policies[keys[i]], t.policies[keys[j]]
+		if pi.Path != pj.Path {
+			return pi.Path < pj.Path
+		}
+		return pi.Method < pj.Method
+	})
+	return keys
+}
+
+// Authorize aplica la política de la ruta solicitada. Se registra una sola vez a nivel del router;
+// una ruta sin política se rechaza (además de fallar al arrancar en Validate).
+func (m *AuthMiddleware) Authorize(table *PolicyTable) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Sin ruta coincidente (404): no hay nada que autorizar
+		if c.FullPath() == "" {
+			c.Next()
+			return
+		}
+
+		policy, ok := table.Lookup(c.Request.Method, c.FullPath())
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "No authorization policy for this route",
+			})
+			c.Abort()
+			return
+		}
+
+		if policy.Public {
+			c.Next()
+			return
+		}
+
+		if !IsAuthenticated(c) && !m.authenticate(c) {
+			return
+		}
+		if policy.DenyImpersonation && !checkNotImpersonated(c) {
+			return
+		}
+		if !policy.AllowMFAPending && !checkMFAEnrollment(c) {
+			return
+		}
+		if len(policy.Roles) > 0 && !checkAnyRole(c, policy.Roles) {
+			return
+		}
+		if len(policy.Permissions) > 0 && !checkPermissions(c, policy.Permissions) {
+			return
+		}
+
+		c.Next()
+	}
+}
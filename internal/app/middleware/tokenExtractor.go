@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"megabaseGo/internal/app/services"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Nombre del access token en la query string; el de la cookie viene de COOKIE_ACCESS_NAME
+const accessTokenQueryParam = "access_token"
+
+// TokenExtractor obtiene el access token de una fuente de la petición; "" si no está
+type TokenExtractor func(c *gin.Context) string
+
+// tokenExtractors fuentes disponibles para AUTH_TOKEN_SOURCES
+var tokenExtractors = map[string]TokenExtractor{
+	"header": headerTokenExtractor,
+	"cookie": cookieTokenExtractor,
+	"query":  queryTokenExtractor,
+}
+
+// NewTokenExtractorChain arma la cadena con las fuentes indicadas, en orden; el primer token encontrado gana
+func NewTokenExtractorChain(sources []string) TokenExtractor {
+	var chain []TokenExtractor
+	for _, source := range sources {
+		extractor, ok := tokenExtractors[source]
+		if !ok {
+			log.Printf("Fuente de token desconocida en AUTH_TOKEN_SOURCES: %s", source)
+			continue
+		}
+		chain = append(chain, extractor)
+	}
+
+	return func(c *gin.Context) string {
+		for _, extractor := range chain {
+			if token := extractor(c); token != "" {
+				return token
+			}
+		}
+		return ""
+	}
+}
+
+// headerTokenExtractor lee "Authorization: Bearer <token>"; las API keys se tratan aparte
+func headerTokenExtractor(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if len(authHeader) <= 7 || !strings.EqualFold(authHeader[:7], "Bearer ") {
+		return ""
+	}
+
+	token := strings.TrimSpace(authHeader[7:])
+	if strings.HasPrefix(token, services.APIKeyPrefix) {
+		return ""
+	}
+	return token
+}
+
+// cookieTokenExtractor lee la cookie HttpOnly que usan los navegadores
+func cookieTokenExtractor(c *gin.Context) string {
+	return utils.GetCookie(c, config.Get().Cookie.AccessName)
+}
+
+// queryTokenExtractor lee ?access_token=, solo en GET (descargas y SSE)
+func queryTokenExtractor(c *gin.Context) string {
+	if c.Request.Method != http.MethodGet {
+		return ""
+	}
+	return c.Query(accessTokenQueryParam)
+}
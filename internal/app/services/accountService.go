@@ -0,0 +1,286 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/mailer"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	passwordResetTTL     = 1 * time.Hour
+	emailVerificationTTL = 48 * time.Hour
+)
+
+// errInvalidUserToken mismo mensaje para token inexistente, usado, expirado o invalidado
+var errInvalidUserToken = utils.NewBadRequestError("Token inválido o expirado")
+
+// AccountService maneja la recuperación de contraseña y la verificación de email
+type AccountService struct {
+	mailer mailer.Mailer
+	hasher utils.PasswordHasher
+	policy *PasswordPolicy
+}
+
+// NewAccountService crea una nueva instancia del servicio de cuentas
+func NewAccountService() *AccountService {
+	return &AccountService{
+		mailer: mailer.Default(),
+		hasher: utils.NewPasswordHasher(),
+		policy: NewPasswordPolicy(),
+	}
+}
+
+// RequestPasswordReset envía el enlace de recuperación si el email existe.
+// No informa si el email está registrado para no permitir enumerar usuarios.
+func (s *AccountService) RequestPasswordReset(email string) error {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.Where("email = ?", strings.TrimSpace(email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	token, err := s.issueUserToken(&user, models.UserTokenPasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(config.Get().FrontURL, "/"), url.QueryEscape(token))
+	s.deliver(mailer.Message{
+		To:      user.Email,
+		Subject: "Recuperación de contraseña",
+		Body: fmt.Sprintf("Hola %s,\n\nPara restablecer su contraseña ingrese al siguiente enlace (válido por %d minutos):\n\n%s\n\n"+
+			"Si usted no lo solicitó, ignore este correo.", user.Name, int(passwordResetTTL.Minutes()), link),
+	})
+	return nil
+}
+
+// ResetPassword establece la nueva contraseña consumiendo el token de recuperación
+func (s *AccountService) ResetPassword(req *dto.ResetPasswordRequest) error {
+	db := database.GetDB()
+
+	// La política se valida antes de consumir el token para que un rechazo no lo gaste
+	user, err := s.consumeUserToken(req.Token, models.UserTokenPasswordReset, func(user *models.User) error {
+		if err := s.policy.Validate(req.NewPassword, user.UserName, user.Email); err != nil {
+			return err
+		}
+		return s.policy.CheckHistory(db, user, req.NewPassword)
+	})
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.HashPassword(req.NewPassword)
+	if err != nil {
+		return errors.New("failed to hash new password")
+	}
+
+	stamp, err := generateSecureToken(32)
+	if err != nil {
+		return errors.New("failed to generate remember token")
+	}
+
+	// Al demostrar acceso al correo también se levanta el bloqueo por intentos fallidos
+	updates := map[string]interface{}{
+		"password":              hashedPassword,
+		"remember_token":        stamp,
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}
+	// y el email queda verificado
+	if user.EmailVerifiedAt == nil {
+		updates["email_verified_at"] = time.Now()
+	}
+	if err := db.Model(user).Updates(updates).Error; err != nil {
+		return err
+	}
+	if err := s.policy.Remember(db, user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	GetLoginThrottle().Reset(usernameThrottleKey(user.UserName))
+
+	logger.Debug.WithFields(logrus.Fields{"user_id": user.ID}).Info("Contraseña restablecida por correo")
+
+	// La contraseña anterior pudo estar comprometida: se cierran todas las sesiones
+	return revokeUserAccess(user.ID, "password_reset", true)
+}
+
+// SendVerificationEmail envía el enlace de verificación al email del usuario
+func (s *AccountService) SendVerificationEmail(user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := s.issueUserToken(user, models.UserTokenEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/v1/auth/verify-email?token=%s", strings.TrimRight(config.Get().AppURL, "/"), url.QueryEscape(token))
+	s.deliver(mailer.Message{
+		To:      user.Email,
+		Subject: "Verifique su email",
+		Body: fmt.Sprintf("Hola %s,\n\nConfirme su dirección de correo ingresando al siguiente enlace:\n\n%s\n",
+			user.Name, link),
+	})
+	return nil
+}
+
+// ResendVerification reenvía la verificación; igual que la recuperación no revela si el email existe
+func (s *AccountService) ResendVerification(email string) error {
+	var user models.User
+	if err := database.GetDB().Where("email = ?", strings.TrimSpace(email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.SendVerificationEmail(&user)
+}
+
+// VerifyEmail marca el email como verificado consumiendo el token
+func (s *AccountService) VerifyEmail(token string) error {
+	user, err := s.consumeUserToken(token, models.UserTokenEmailVerification, nil)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return database.GetDB().Model(user).Update("email_verified_at", time.Now()).Error
+}
+
+// issueUserToken genera un token aleatorio, guarda su hash y descarta los pendientes del mismo propósito
+func (s *AccountService) issueUserToken(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	token, err := generateSecureToken(32)
+	if err != nil {
+		return "", errors.New("failed to generate token")
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// Los usuarios creados antes de usar RememberToken como sello no lo tienen
+		if user.RememberToken == "" {
+			stamp, err := generateSecureToken(32)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(user).Update("remember_token", stamp).Error; err != nil {
+				return err
+			}
+			user.RememberToken = stamp
+		}
+
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+			Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.UserToken{
+			UserID:    user.ID,
+			Purpose:   purpose,
+			TokenHash: hashUserToken(token),
+			Stamp:     user.RememberToken,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken valida el token y lo marca como usado en una sola actualización condicional.
+// check, si no es nil, se ejecuta antes de marcarlo: si falla el token sigue disponible.
+func (s *AccountService) consumeUserToken(token, purpose string, check func(*models.User) error) (*models.User, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, errInvalidUserToken
+	}
+
+	db := database.GetDB()
+
+	var userToken models.UserToken
+	if err := db.Where("token_hash = ? AND purpose = ?", hashUserToken(token), purpose).First(&userToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidUserToken
+		}
+		return nil, err
+	}
+	if userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+
+	var user models.User
+	if err := db.First(&user, userToken.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidUserToken
+		}
+		return nil, err
+	}
+	if !user.IsActive || user.RememberToken != userToken.Stamp {
+		return nil, errInvalidUserToken
+	}
+	if check != nil {
+		if err := check(&user); err != nil {
+			return nil, err
+		}
+	}
+
+	result := db.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", userToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidUserToken
+	}
+
+	return &user, nil
+}
+
+// deliver envía el correo en segundo plano para que el tiempo de respuesta no dependa del SMTP
+// ni revele si el email existe
+func (s *AccountService) deliver(msg mailer.Message) {
+	deliverMail(s.mailer, msg)
+}
+
+// deliverMail envía el correo en una goroutine y registra el error si falla
+func deliverMail(m mailer.Mailer, msg mailer.Message) {
+	go func() {
+		if err := m.Send(msg); err != nil {
+			logger.Debug.WithFields(logrus.Fields{"to": msg.To, "subject": msg.Subject}).
+				WithError(err).Error("Error enviando correo")
+		}
+	}()
+}
+
+// hashUserToken SHA-256 del token; es aleatorio de 256 bits, no necesita un hash lento
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// APIKeyPrefix identifica las API keys frente a los JWT en la cabecera Authorization
+	APIKeyPrefix = "mbk_"
+
+	maxAPIKeysPerUser = 20
+	// apiKeyTouchInterval evita escribir last_used_at en cada request
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyScopes scopes que puede tener una API key; el recurso es el primer segmento de la ruta bajo /api/v1
+var APIKeyScopes = []string{
+	"citizens:read", "citizens:write",
+	"companies:read", "companies:write",
+}
+
+var errInvalidAPIKey = utils.NewUnauthorizedError("API key inválida, expirada o revocada")
+
+// APIKeyService maneja las API keys personales
+type APIKeyService struct{}
+
+// NewAPIKeyService crea una nueva instancia del servicio de API keys
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{}
+}
+
+// CreateAPIKey genera una clave para el usuario; el valor completo solo se devuelve aquí
+func (s *APIKeyService) CreateAPIKey(userID uint, req *dto.CreateAPIKeyRequest) (*dto.CreatedAPIKeyResponse, error) {
+	db := database.GetDB()
+
+	scopes, err := normalizeAPIKeyScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, utils.NewConflictError("Se alcanzó el máximo de API keys activas por usuario")
+	}
+
+	prefix, err := generateSecureToken(4)
+	if err != nil {
+		return nil, errors.New("failed to generate api key")
+	}
+	secret, err := generateSecureToken(24)
+	if err != nil {
+		return nil, errors.New("failed to generate api key")
+	}
+
+	apiKey := models.APIKey{
+		UserID:     userID,
+		Name:       strings.TrimSpace(req.Name),
+		Prefix:     prefix,
+		SecretHash: hashAPIKeySecret(secret),
+		Scopes:     strings.Join(scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := db.Create(&apiKey).Error; err != nil {
+		return nil, err
+	}
+
+	logger.Debug.WithFields(logrus.Fields{"user_id": userID, "prefix": prefix, "scopes": apiKey.Scopes}).Info("API key creada")
+
+	return &dto.CreatedAPIKeyResponse{
+		APIKeyResponse: *s.toAPIKeyResponse(&apiKey),
+		Key:            APIKeyPrefix + prefix + "_" + secret,
+	}, nil
+}
+
+// GetUserAPIKeys lista las claves del usuario
+func (s *APIKeyService) GetUserAPIKeys(userID uint) ([]dto.APIKeyResponse, error) {
+	var apiKeys []models.APIKey
+	if err := database.GetDB().Where("user_id = ?", userID).Order("created_at DESC").Find(&apiKeys).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.APIKeyResponse, 0, len(apiKeys))
+	for i := range apiKeys {
+		responses = append(responses, *s.toAPIKeyResponse(&apiKeys[i]))
+	}
+	return responses, nil
+}
+
+// GetAllAPIKeys lista las claves de todos los usuarios (admin), opcionalmente filtradas por usuario
+func (s *APIKeyService) GetAllAPIKeys(userID *uint, includeRevoked bool) ([]dto.APIKeyResponse, error) {
+	query := database.GetDB().Preload("User").Order("created_at DESC")
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	if !includeRevoked {
+		query = query.Where("revoked_at IS NULL")
+	}
+
+	var apiKeys []models.APIKey
+	if err := query.Find(&apiKeys).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.APIKeyResponse, 0, len(apiKeys))
+	for i := range apiKeys {
+		response := s.toAPIKeyResponse(&apiKeys[i])
+		response.UserName = apiKeys[i].User.UserName
+		responses = append(responses, *response)
+	}
+	return responses, nil
+}
+
+// RevokeUserAPIKey revoca una clave propia del usuario
+func (s *APIKeyService) RevokeUserAPIKey(userID, keyID uint) error {
+	return s.revoke(database.GetDB().Where("id = ? AND user_id = ?", keyID, userID), "owner")
+}
+
+// RevokeAPIKey revoca cualquier clave (admin)
+func (s *APIKeyService) RevokeAPIKey(keyID uint) error {
+	return s.revoke(database.GetDB().Where("id = ?", keyID), "admin")
+}
+
+func (s *APIKeyService) revoke(query *gorm.DB, revokedBy string) error {
+	var apiKey models.APIKey
+	if err := query.First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewNotFoundError("API key")
+		}
+		return err
+	}
+	if apiKey.RevokedAt != nil {
+		return nil
+	}
+
+	if err := database.GetDB().Model(&apiKey).Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	logger.Debug.WithFields(logrus.Fields{
+		"user_id":    apiKey.UserID,
+		"prefix":     apiKey.Prefix,
+		"revoked_by": revokedBy,
+	}).Info("API key revocada")
+	return nil
+}
+
+// RevokeUserAPIKeys revoca todas las claves del usuario (ej. al eliminarlo)
+func (s *APIKeyService) RevokeUserAPIKeys(userID uint) error {
+	return database.GetDB().Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Authenticate valida la clave y devuelve la clave con su usuario y rol cargados
+func (s *APIKeyService) Authenticate(rawKey, ip string) (*models.APIKey, error) {
+	prefix, secret, ok := parseAPIKey(rawKey)
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+
+	db := database.GetDB()
+
+	var apiKey models.APIKey
+	if err := db.Preload("User.Role").Where("prefix = ?", prefix).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	expected, _ := hex.DecodeString(apiKey.SecretHash)
+	actual := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(expected, actual[:]) != 1 {
+		return nil, errInvalidAPIKey
+	}
+	if !apiKey.IsActive() || apiKey.User.ID == 0 || !apiKey.User.IsActive {
+		return nil, errInvalidAPIKey
+	}
+
+	// Registrar uso como máximo una vez por intervalo
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval || apiKey.LastUsedIP != ip {
+		db.Model(&apiKey).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+
+	return &apiKey, nil
+}
+
+// APIKeyHasScope indica si la clave incluye el scope pedido
+func APIKeyHasScope(apiKey *models.APIKey, scope string) bool {
+	for _, s := range apiKey.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAPIKey separa "mbk_<prefix>_<secret>"
+func parseAPIKey(rawKey string) (string, string, bool) {
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(rawKey, APIKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// normalizeAPIKeyScopes valida contra APIKeyScopes y elimina duplicados
+func normalizeAPIKeyScopes(scopes []string) ([]string, error) {
+	allowed := make(map[string]bool, len(APIKeyScopes))
+	for _, scope := range APIKeyScopes {
+		allowed[scope] = true
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !allowed[scope] {
+			return nil, utils.NewBadRequestError("Scope inválido: " + scope + ". Permitidos: " + strings.Join(APIKeyScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// toAPIKeyResponse convierte un modelo APIKey a APIKeyResponse
+func (s *APIKeyService) toAPIKeyResponse(apiKey *models.APIKey) *dto.APIKeyResponse {
+	return &dto.APIKeyResponse{
+		ID:         apiKey.ID,
+		UserID:     apiKey.UserID,
+		Name:       apiKey.Name,
+		Prefix:     APIKeyPrefix + apiKey.Prefix,
+		Scopes:     apiKey.ScopeList(),
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		LastUsedIP: apiKey.LastUsedIP,
+		RevokedAt:  apiKey.RevokedAt,
+		Active:     apiKey.IsActive(),
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Acciones registradas en la auditoría
+const (
+	AuditImpersonationStarted = "impersonation_started"
+	AuditImpersonationStopped = "impersonation_stopped"
+	AuditImpersonatedRequest  = "impersonated_request"
+)
+
+// AuditService guarda el registro de auditoría
+type AuditService struct{}
+
+// NewAuditService crea una nueva instancia del servicio de auditoría
+func NewAuditService() *AuditService {
+	return &AuditService{}
+}
+
+// Record guarda una entrada; un fallo se registra en el log pero no interrumpe la petición
+func (s *AuditService) Record(entry *models.AuditLog) {
+	if err := database.GetDB().Create(entry).Error; err != nil {
+		logger.Debug.WithFields(logrus.Fields{
+			"actor_id": entry.ActorID,
+			"user_id":  entry.UserID,
+			"action":   entry.Action,
+		}).WithError(err).Error("Error guardando auditoría")
+	}
+}
@@ -2,140 +2,275 @@ package services
 
 import (
 	"errors"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/config"
 	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
 	"megabaseGo/internal/models"
 	"megabaseGo/internal/utils"
 
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+// Métodos de autenticación registrados en el claim amr y en la sesión
+const (
+	authMethodPassword = "pwd"
+	authMethodOTP      = "otp"
+	authMethodOIDC     = "oidc"
+)
+
 type AuthService struct {
-	userService *UserService
-	jwtManager  *utils.JWTManager
-	hasher      utils.PasswordHasher
+	userService    *UserService
+	sessionService *SessionService
+	mfaService     *MFAService
+	jwtManager     *utils.JWTManager
+	hasher         utils.PasswordHasher
+	policy         *PasswordPolicy
+	localAuth      Authenticator
+	ldapAuth       Authenticator
 }
 
 // NewAuthService crea una nueva instancia del servicio de autenticación
 func NewAuthService() *AuthService {
-	return &AuthService{
-		userService: NewUserService(),
-		jwtManager:  utils.NewJWTManager(),
-		hasher:      utils.NewBcryptHasher(),
+	sessionService := NewSessionService()
+	hasher := utils.NewPasswordHasher()
+	service := &AuthService{
+		userService:    NewUserService(),
+		sessionService: sessionService,
+		mfaService:     NewMFAService(),
+		jwtManager:     utils.NewJWTManager(sessionService),
+		hasher:         hasher,
+		policy:         NewPasswordPolicy(),
+		localAuth:      NewDBAuthenticator(hasher),
+	}
+	if ldapCfg := config.Get().LDAP; ldapCfg.Enabled {
+		service.ldapAuth = NewLDAPAuthenticator(ldapCfg, hasher)
 	}
+	return service
+}
+
+// SetLDAPAuthenticator reemplaza el autenticador del directorio (ej. uno conectado a un stub en pruebas)
+func (s *AuthService) SetLDAPAuthenticator(authenticator Authenticator) {
+	s.ldapAuth = authenticator
 }
 
 // Login autentica un usuario y retorna tokens
-func (s *AuthService) Login(req *dto.LoginRequest) (*dto.AuthResponse, error) {
+func (s *AuthService) Login(req *dto.LoginRequest, client dto.ClientInfo) (*dto.AuthResponse, error) {
 	db := database.GetDB()
+	cfg := config.Get()
+	throttle := GetLoginThrottle()
+	userKey := usernameThrottleKey(req.UserName)
+	ipKey := ipThrottleKey(client.IPAddress)
+
+	// Backoff o bloqueo vigente por IP o por usuario (exista o no)
+	if wait := maxDuration(throttle.RetryAfter(ipKey), throttle.RetryAfter(userKey)); wait > 0 {
+		recordLoginFailure(nil, req.UserName, authMethodPassword, models.LoginFailureThrottled, client)
+		return nil, errTooManyLoginAttempts(wait)
+	}
 
-	// Buscar usuario por username con rol
-	var user models.User
-	if err := db.Preload("Role").Where("user_name = ?", req.UserName).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	// Buscar usuario por username con rol; con LDAP puede no existir todavía
+	var local *models.User
+	var found models.User
+	if err := db.Preload("Role").Where("user_name = ?", req.UserName).First(&found).Error; err == nil {
+		local = &found
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Bloqueo persistido de la cuenta
+	if local != nil && local.LockedUntil != nil && time.Now().Before(*local.LockedUntil) {
+		s.hasher.ComparePassword(local.Password, req.Password)
+		recordLoginFailure(local, req.UserName, authMethodPassword, models.LoginFailureLocked, client)
+		return nil, errTooManyLoginAttempts(time.Until(*local.LockedUntil))
+	}
+
+	// Verificar contraseña contra la base o el directorio
+	authenticated, err := s.authenticatorFor(req.UserName).Authenticate(req.UserName, req.Password, local)
+	if err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			s.registerFailedLogin(local, req.UserName, client)
+			recordLoginFailure(local, req.UserName, authMethodPassword, models.LoginFailureInvalidCredentials, client)
 			return nil, errors.New("invalid credentials")
 		}
 		return nil, err
 	}
+	user := *authenticated
 
-	// Verificar que el usuario esté activo
+	// Verificar que el usuario esté activo; solo se informa con la contraseña correcta
 	if !user.IsActive {
+		recordLoginFailure(&user, req.UserName, authMethodPassword, models.LoginFailureDisabled, client)
 		return nil, errors.New("user account is disabled")
 	}
 
-	// Verificar contraseña
-	if err := s.hasher.ComparePassword(user.Password, req.Password); err != nil {
-		return nil, errors.New("invalid credentials")
+	// Credenciales válidas: se olvidan los fallos del usuario (los de la IP expiran solos).
+	// Con TOTP el contador sigue vigente hasta superar el segundo factor, para que una contraseña
+	// conocida no permita pedir desafíos nuevos y probar códigos sin límite.
+	if !user.TOTPEnabled {
+		s.clearFailedLogins(&user)
+	}
+
+	// Verificación de email obligatoria si así se configuró
+	if cfg.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		recordLoginFailure(&user, req.UserName, authMethodPassword, models.LoginFailureEmailUnverified, client)
+		return nil, utils.NewForbiddenError("Debe verificar su email antes de iniciar sesión")
+	}
+
+	return s.finishLogin(&user, client, []string{authMethodPassword})
+}
+
+// finishLogin completa un login con el primer factor ya verificado (contraseña o proveedor externo).
+// Con TOTP activo no se emiten tokens todavía: se devuelve un desafío para el segundo paso.
+func (s *AuthService) finishLogin(user *models.User, client dto.ClientInfo, authMethods []string) (*dto.AuthResponse, error) {
+	if user.TOTPEnabled {
+		mfaToken, err := s.jwtManager.GeneratePurposeToken(utils.TokenTypeMFA, strconv.Itoa(int(user.ID)), mfaChallengeTTL)
+		if err != nil {
+			return nil, errors.New("failed to generate MFA challenge")
+		}
+		return &dto.AuthResponse{
+			User:        *s.toUserResponse(user),
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, nil
 	}
 
 	// Actualizar último login
 	user.LastLoginAt = time.Now()
-	db.Save(&user)
+	database.GetDB().Save(user)
 
-	// Generar tokens
-	accessToken, err := s.jwtManager.GenerateToken(
-		user.ID,
-		user.UserName,
-		user.Email,
-		user.RoleID,
-		user.Role.Name,
-	)
+	return s.issueTokens(user, client, authMethods)
+}
+
+// VerifyMFA completa el login validando el token de desafío y el código TOTP o de recuperación
+func (s *AuthService) VerifyMFA(req *dto.MFAVerifyRequest, client dto.ClientInfo) (*dto.AuthResponse, error) {
+	claims, err := s.jwtManager.ValidatePurposeToken(req.MFAToken, utils.TokenTypeMFA)
 	if err != nil {
-		return nil, errors.New("failed to generate access token")
+		return nil, utils.NewUnauthorizedError("Desafío MFA inválido o expirado")
+	}
+	if challengeAttempts.blocked(claims.ID) {
+		return nil, utils.NewUnauthorizedError("Demasiados intentos, inicie sesión nuevamente")
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID)
+	db := database.GetDB()
+	var user models.User
+	if err := db.Preload("Role").First(&user, claims.Subject).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewUnauthorizedError("Desafío MFA inválido o expirado")
+		}
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("user account is disabled")
+	}
+
+	// Los fallos del segundo factor cuentan en el mismo limitador que los de contraseña: se
+	// comprueba antes de verificar para no gastar comparaciones de códigos de recuperación
+	throttle := GetLoginThrottle()
+	wait := maxDuration(throttle.RetryAfter(ipThrottleKey(client.IPAddress)), throttle.RetryAfter(usernameThrottleKey(user.UserName)))
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		wait = maxDuration(wait, time.Until(*user.LockedUntil))
+	}
+	if wait > 0 {
+		recordLoginFailure(&user, user.UserName, authMethodPassword+","+authMethodOTP, models.LoginFailureThrottled, client)
+		return nil, errTooManyLoginAttempts(wait)
+	}
+
+	ok, err := s.mfaService.VerifyCode(&user, req.Code)
 	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
+		return nil, err
+	}
+	if !ok {
+		challengeAttempts.fail(claims.ID, claims.ExpiresAt.Time)
+		s.registerFailedLogin(&user, user.UserName, client)
+		recordLoginFailure(&user, user.UserName, authMethodPassword+","+authMethodOTP, models.LoginFailureMFA, client)
+		return nil, utils.NewUnauthorizedError("Código MFA inválido")
 	}
 
-	// Crear respuesta
-	userResponse := s.toUserResponse(&user)
+	// El desafío no puede reutilizarse tras completar el login
+	challengeAttempts.consume(claims.ID, claims.ExpiresAt.Time)
+	s.clearFailedLogins(&user)
 
-	return &dto.AuthResponse{
-		User:         *userResponse,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    s.jwtManager.GetTokenDuration(),
-	}, nil
+	user.LastLoginAt = time.Now()
+	db.Save(&user)
+
+	return s.issueTokens(&user, client, []string{authMethodPassword, authMethodOTP})
 }
 
 // Register registra un nuevo usuario
-func (s *AuthService) Register(req *dto.RegisterRequest) (*dto.AuthResponse, error) {
+func (s *AuthService) Register(req *dto.RegisterRequest, client dto.ClientInfo) (*dto.AuthResponse, error) {
+	cfg := config.Get()
+	if !cfg.RegistrationEnabled {
+		return nil, utils.NewForbiddenError("El registro público está deshabilitado; solicite una invitación")
+	}
+
+	// El rol lo fija la configuración: el cliente no puede elegirlo
+	db := database.GetDB()
+	var role models.Role
+	if err := db.Where("name = ? AND is_active = ?", cfg.RegistrationRole, true).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("registration role not configured")
+		}
+		return nil, err
+	}
+
 	// Usar el UserService para crear el usuario
 	createUserReq := &dto.CreateUserRequest{
 		Name:     req.Name,
 		UserName: req.UserName,
 		Email:    req.Email,
 		Password: req.Password,
-		RoleID:   req.RoleID,
+		RoleID:   role.ID,
 	}
 
-	userResponse, err := s.userService.CreateUser(createUserReq)
+	userResponse, err := s.userService.CreateUser(nil, createUserReq)
 	if err != nil {
 		return nil, err
 	}
 
+	// Con verificación obligatoria no hay sesión hasta confirmar el email, igual que en el login
+	if cfg.RequireEmailVerification {
+		return &dto.AuthResponse{User: *userResponse, EmailVerificationRequired: true}, nil
+	}
+
 	// Obtener el usuario completo con rol para generar tokens
-	db := database.GetDB()
 	var user models.User
 	if err := db.Preload("Role").First(&user, userResponse.ID).Error; err != nil {
 		return nil, err
 	}
 
-	// Generar tokens
-	accessToken, err := s.jwtManager.GenerateToken(
-		user.ID,
-		user.UserName,
-		user.Email,
-		user.RoleID,
-		user.Role.Name,
-	)
+	return s.issueTokens(&user, client, []string{authMethodPassword})
+}
+
+// AcceptInvitation crea la cuenta invitada e inicia sesión igual que el registro
+func (s *AuthService) AcceptInvitation(req *dto.AcceptInvitationRequest, client dto.ClientInfo) (*dto.AuthResponse, error) {
+	userResponse, err := NewInvitationService().AcceptInvitation(req)
 	if err != nil {
-		return nil, errors.New("failed to generate access token")
+		return nil, err
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID)
-	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
+	var user models.User
+	if err := database.GetDB().Preload("Role").First(&user, userResponse.ID).Error; err != nil {
+		return nil, err
 	}
 
-	return &dto.AuthResponse{
-		User:         *userResponse,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    s.jwtManager.GetTokenDuration(),
-	}, nil
+	return s.issueTokens(&user, client, []string{authMethodPassword})
 }
 
-// RefreshToken genera un nuevo access token usando el refresh token
+// RefreshToken genera un nuevo access token usando el refresh token.
+// El refresh token presentado queda invalidado (rotación de un solo uso).
 func (s *AuthService) RefreshToken(req *dto.RefreshTokenRequest) (*dto.AuthResponse, error) {
-	// Validar refresh token
-	userID, err := s.jwtManager.ValidateRefreshToken(req.RefreshToken)
+	// Validar refresh token contra el store de sesiones
+	claims, err := s.jwtManager.ValidateRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+	userID, err := claims.UserID()
 	if err != nil {
 		return nil, errors.New("invalid refresh token")
 	}
@@ -152,37 +287,70 @@ func (s *AuthService) RefreshToken(req *dto.RefreshTokenRequest) (*dto.AuthRespo
 
 	// Verificar que el usuario siga activo
 	if !user.IsActive {
+		s.jwtManager.RevokeRefreshToken(req.RefreshToken, "user_disabled")
 		return nil, errors.New("user account is disabled")
 	}
 
-	// Generar nuevos tokens
-	accessToken, err := s.jwtManager.GenerateToken(
-		user.ID,
-		user.UserName,
-		user.Email,
-		user.RoleID,
-		user.Role.Name,
-	)
+	// Una sesión emitida antes de exigir la verificación no la evita al refrescar
+	if config.Get().RequireEmailVerification && user.EmailVerifiedAt == nil {
+		s.jwtManager.RevokeRefreshToken(req.RefreshToken, "email_unverified")
+		return nil, utils.NewForbiddenError("Debe verificar su email antes de iniciar sesión")
+	}
+
+	// Métodos de autenticación con los que se abrió la sesión
+	session, err := s.sessionService.FindSession(claims.FamilyID)
 	if err != nil {
-		return nil, errors.New("failed to generate access token")
+		return nil, errors.New("invalid refresh token")
 	}
 
-	newRefreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID)
+	// Rotar el refresh token dentro de la misma sesión
+	newRefreshToken, err := s.jwtManager.RotateRefreshToken(claims)
 	if err != nil {
-		return nil, errors.New("failed to generate refresh token")
+		return nil, errors.New("invalid refresh token")
 	}
 
-	userResponse := s.toUserResponse(&user)
+	// Generar nuevo access token
+	accessClaims := s.accessClaims(&user, splitAuthMethods(session.AuthMethods))
+	accessClaims.SessionID = claims.FamilyID
+	accessToken, err := s.jwtManager.GenerateToken(accessClaims)
+	if err != nil {
+		return nil, errors.New("failed to generate access token")
+	}
 
 	return &dto.AuthResponse{
-		User:         *userResponse,
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    s.jwtManager.GetTokenDuration(),
+		User:                  *s.toUserResponse(&user),
+		AccessToken:           accessToken,
+		RefreshToken:          newRefreshToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             s.jwtManager.GetTokenDuration(),
+		RefreshExpiresIn:      s.jwtManager.GetRefreshTokenDuration(),
+		MFAEnrollmentRequired: requiresMFAEnrollment(&user),
 	}, nil
 }
 
+// Logout revoca en el servidor la sesión del refresh token y el access token en uso.
+// Al revocar la sesión se revocan también los demás access tokens que emitió.
+func (s *AuthService) Logout(refreshToken, accessToken string) error {
+	if accessToken != "" {
+		if claims, err := s.jwtManager.ValidateToken(accessToken); err == nil {
+			if err := GetTokenDenylist().RevokeToken(claims, "logout"); err != nil {
+				return err
+			}
+			// Clientes que solo envían el access token también cierran su sesión
+			if claims.SessionID != "" {
+				if err := s.sessionService.RevokeSession(claims.SessionID, "logout"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	return s.jwtManager.RevokeRefreshToken(refreshToken, "logout")
+}
+
 // ChangePassword cambia la contraseña de un usuario autenticado
 func (s *AuthService) ChangePassword(userID uint, req *dto.ChangePasswordRequest) error {
 	db := database.GetDB()
@@ -201,19 +369,38 @@ func (s *AuthService) ChangePassword(userID uint, req *dto.ChangePasswordRequest
 		return errors.New("current password is incorrect")
 	}
 
+	// Política y historial de contraseñas
+	if err := s.policy.Validate(req.NewPassword, user.UserName, user.Email); err != nil {
+		return err
+	}
+	if err := s.policy.CheckHistory(db, &user, req.NewPassword); err != nil {
+		return err
+	}
+
 	// Hash nueva contraseña
 	hashedPassword, err := s.hasher.HashPassword(req.NewPassword)
 	if err != nil {
 		return errors.New("failed to hash new password")
 	}
 
+	// Rotar el sello para invalidar enlaces de recuperación pendientes
+	stamp, err := generateSecureToken(32)
+	if err != nil {
+		return errors.New("failed to generate remember token")
+	}
+
 	// Actualizar contraseña
 	user.Password = hashedPassword
+	user.RememberToken = stamp
 	if err := db.Save(&user).Error; err != nil {
 		return err
 	}
+	if err := s.policy.Remember(db, user.ID, user.Password); err != nil {
+		return err
+	}
 
-	return nil
+	// Invalidar todos los tokens y sesiones emitidos con la contraseña anterior
+	return revokeUserAccess(user.ID, "password_changed", true)
 }
 
 // GetCurrentUser obtiene la información del usuario actual
@@ -221,23 +408,176 @@ func (s *AuthService) GetCurrentUser(userID uint) (*dto.UserResponse, error) {
 	return s.userService.GetUserByID(userID)
 }
 
-// ValidateToken valida un token y retorna los claims
+// ValidateToken valida un token, verifica que no esté revocado y retorna los claims
 func (s *AuthService) ValidateToken(tokenString string) (*utils.JWTClaims, error) {
-	return s.jwtManager.ValidateToken(tokenString)
+	claims, err := s.jwtManager.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if GetTokenDenylist().IsRevoked(claims) {
+		return nil, errors.New("token has been revoked")
+	}
+	// En suplantación también cuenta la revocación del actor (desactivado, cambio de rol, etc.)
+	if claims.IsImpersonated() {
+		actorClaims := &utils.JWTClaims{UserID: claims.Act.UserID, RegisteredClaims: claims.RegisteredClaims}
+		if GetTokenDenylist().IsRevoked(actorClaims) {
+			return nil, errors.New("token has been revoked")
+		}
+	}
+
+	return claims, nil
+}
+
+// registerFailedLogin suma el fallo a los contadores de usuario e IP y, al llegar al máximo,
+// bloquea la cuenta en la base de datos para que el bloqueo sea visible y desbloqueable por un admin
+func (s *AuthService) registerFailedLogin(user *models.User, userName string, client dto.ClientInfo) {
+	cfg := config.Get()
+	throttle := GetLoginThrottle()
+
+	ipFailures := throttle.Fail(ipThrottleKey(client.IPAddress), cfg.LoginIPMaxAttempts, cfg.LoginLockout)
+	userFailures := throttle.Fail(usernameThrottleKey(userName), cfg.LoginMaxAttempts, cfg.LoginLockout)
+
+	fields := logrus.Fields{
+		"user_name":     userName,
+		"ip":            client.IPAddress,
+		"user_failures": userFailures,
+		"ip_failures":   ipFailures,
+	}
+
+	if ipFailures == cfg.LoginIPMaxAttempts {
+		logger.Debug.WithFields(fields).Warn("IP bloqueada temporalmente por intentos de login fallidos")
+	}
+
+	if user == nil {
+		if userFailures == cfg.LoginMaxAttempts {
+			logger.Debug.WithFields(fields).Warn("Usuario inexistente bloqueado temporalmente por intentos de login fallidos")
+		}
+		return
+	}
+
+	updates := map[string]interface{}{"failed_login_attempts": gorm.Expr("failed_login_attempts + 1")}
+	if userFailures >= cfg.LoginMaxAttempts {
+		lockedUntil := time.Now().Add(cfg.LoginLockout)
+		updates["locked_until"] = lockedUntil
+		fields["user_id"] = user.ID
+		fields["locked_until"] = lockedUntil
+		logger.Debug.WithFields(fields).Warn("Cuenta bloqueada por intentos de login fallidos")
+	}
+	database.GetDB().Model(user).Updates(updates)
+}
+
+// clearFailedLogins olvida los fallos de login del usuario tras completar la autenticación
+func (s *AuthService) clearFailedLogins(user *models.User) {
+	GetLoginThrottle().Reset(usernameThrottleKey(user.UserName))
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		database.GetDB().Model(user).Updates(map[string]interface{}{"failed_login_attempts": 0, "locked_until": nil})
+		user.FailedLoginAttempts = 0
+		user.LockedUntil = nil
+	}
+}
+
+// errTooManyLoginAttempts mismo error para bloqueo por usuario, por IP o de cuenta
+func errTooManyLoginAttempts(wait time.Duration) error {
+	return utils.NewTooManyRequestsError("Demasiados intentos fallidos, intente nuevamente más tarde", wait)
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
+)
+
+// dummyPasswordHash hash calculado una sola vez para igualar el costo de login con usuarios inexistentes
+func dummyPasswordHash(hasher utils.PasswordHasher) string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = hasher.HashPassword("megabase-dummy-password")
+	})
+	return dummyHash
+}
+
+// issueTokens abre una sesión nueva, emite access y refresh token para el usuario y registra el login
+func (s *AuthService) issueTokens(user *models.User, client dto.ClientInfo, authMethods []string) (*dto.AuthResponse, error) {
+	session := models.Session{
+		UserID:      user.ID,
+		IPAddress:   client.IPAddress,
+		UserAgent:   client.UserAgent,
+		AuthMethods: strings.Join(authMethods, ","),
+	}
+	refreshToken, err := s.jwtManager.GenerateRefreshToken(&session)
+	if err != nil {
+		return nil, errors.New("failed to generate refresh token")
+	}
+
+	claims := s.accessClaims(user, authMethods)
+	claims.SessionID = session.FamilyID
+	accessToken, err := s.jwtManager.GenerateToken(claims)
+	if err != nil {
+		return nil, errors.New("failed to generate access token")
+	}
+
+	recordLoginSuccess(user, session.AuthMethods, session.FamilyID, client)
+
+	return &dto.AuthResponse{
+		User:                  *s.toUserResponse(user),
+		AccessToken:           accessToken,
+		RefreshToken:          refreshToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             s.jwtManager.GetTokenDuration(),
+		RefreshExpiresIn:      s.jwtManager.GetRefreshTokenDuration(),
+		MFAEnrollmentRequired: requiresMFAEnrollment(user),
+	}, nil
+}
+
+// accessClaims arma los claims del access token a partir del usuario
+func (s *AuthService) accessClaims(user *models.User, authMethods []string) utils.JWTClaims {
+	return utils.JWTClaims{
+		UserID:        user.ID,
+		UserName:      user.UserName,
+		Email:         user.Email,
+		RoleID:        user.RoleID,
+		RoleName:      user.Role.Name,
+		AMR:           authMethods,
+		MFAEnrollment: requiresMFAEnrollment(user),
+	}
+}
+
+// requiresMFAEnrollment indica si el rol exige MFA y el usuario aún no lo activó
+func requiresMFAEnrollment(user *models.User) bool {
+	return user.Role.RequireMFA && !user.TOTPEnabled
+}
+
+// splitAuthMethods convierte "pwd,otp" en []string{"pwd", "otp"}
+func splitAuthMethods(value string) []string {
+	if value == "" {
+		return []string{authMethodPassword}
+	}
+	return strings.Split(value, ",")
 }
 
 // toUserResponse convierte un modelo User a UserResponse
 func (s *AuthService) toUserResponse(user *models.User) *dto.UserResponse {
 	return &dto.UserResponse{
-		ID:          user.ID,
-		Name:        user.Name,
-		UserName:    user.UserName,
-		Email:       user.Email,
-		RoleID:      user.RoleID,
-		Role:        user.Role,
-		IsActive:    user.IsActive,
-		LastLoginAt: user.LastLoginAt,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-	}
-}
\ No newline at end of file
+		ID:              user.ID,
+		Name:            user.Name,
+		UserName:        user.UserName,
+		Email:           user.Email,
+		RoleID:          user.RoleID,
+		Role:            user.Role,
+		IsActive:        user.IsActive,
+		MFAEnabled:      user.TOTPEnabled,
+		EmailVerified:   user.EmailVerifiedAt != nil,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		Locked:          user.LockedUntil != nil && time.Now().Before(*user.LockedUntil),
+		LockedUntil:     user.LockedUntil,
+		LastLoginAt:     user.LastLoginAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/models"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// createTestMFAUser crea un usuario con TOTP activo y devuelve su secreto
+func createTestMFAUser(t *testing.T, userName string) (*models.User, string) {
+	t.Helper()
+	db := setupTestDB(t)
+	loadTestConfig(t, nil)
+
+	user := createTestUser(t, db, userName, "Secret123!", models.RoleUser)
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: user.Email})
+	if err != nil {
+		t.Fatalf("generating TOTP secret: %v", err)
+	}
+	if err := db.Model(user).Updates(map[string]interface{}{"totp_enabled": true, "totp_secret": key.Secret()}).Error; err != nil {
+		t.Fatalf("enabling TOTP: %v", err)
+	}
+
+	throttle := GetLoginThrottle()
+	throttle.Reset(usernameThrottleKey(userName))
+	t.Cleanup(func() { throttle.Reset(usernameThrottleKey(userName)) })
+	return user, key.Secret()
+}
+
+// wrongTOTPCode devuelve un código de 6 dígitos distinto del vigente
+func wrongTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatalf("generating TOTP code: %v", err)
+	}
+	last := (code[5]-'0'+5)%10 + '0'
+	return code[:5] + string(last)
+}
+
+func TestVerifyMFAFailuresCountAgainstUser(t *testing.T) {
+	user, secret := createTestMFAUser(t, "mfa-brute")
+	service := NewAuthService()
+	client := dto.ClientInfo{IPAddress: "198.51.100.10"}
+	t.Cleanup(func() { GetLoginThrottle().Reset(ipThrottleKey(client.IPAddress)) })
+
+	// Cada intento usa un desafío nuevo: el límite por desafío no basta para frenar la fuerza bruta
+	var lastChallenge string
+	for attempt := 1; attempt <= loginBackoffFree+1; attempt++ {
+		login, err := service.Login(&dto.LoginRequest{UserName: user.UserName, Password: "Secret123!"}, client)
+		if err != nil {
+			t.Fatalf("attempt %d: login: %v", attempt, err)
+		}
+		if !login.MFARequired {
+			t.Fatalf("attempt %d: expected an MFA challenge", attempt)
+		}
+		lastChallenge = login.MFAToken
+
+		_, err = service.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: lastChallenge, Code: wrongTOTPCode(t, secret)}, client)
+		if !hasStatus(err, http.StatusUnauthorized) {
+			t.Fatalf("attempt %d: expected unauthorized, got %v", attempt, err)
+		}
+	}
+
+	// La contraseña correcta ya no reinicia el contador ni entrega otro desafío
+	if _, err := service.Login(&dto.LoginRequest{UserName: user.UserName, Password: "Secret123!"}, client); !hasStatus(err, http.StatusTooManyRequests) {
+		t.Fatalf("expected login to be throttled, got %v", err)
+	}
+
+	// Ni siquiera el código correcto se verifica mientras dura la espera
+	code, _ := totp.GenerateCode(secret, time.Now())
+	if _, err := service.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: lastChallenge, Code: code}, client); !hasStatus(err, http.StatusTooManyRequests) {
+		t.Fatalf("expected MFA verification to be throttled, got %v", err)
+	}
+}
+
+func TestVerifyMFASuccessResetsUserFailures(t *testing.T) {
+	user, secret := createTestMFAUser(t, "mfa-ok")
+	service := NewAuthService()
+	client := dto.ClientInfo{IPAddress: "198.51.100.11"}
+	t.Cleanup(func() { GetLoginThrottle().Reset(ipThrottleKey(client.IPAddress)) })
+
+	login, err := service.Login(&dto.LoginRequest{UserName: user.UserName, Password: "Secret123!"}, client)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if _, err := service.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: login.MFAToken, Code: wrongTOTPCode(t, secret)}, client); err == nil {
+		t.Fatal("expected the wrong code to be rejected")
+	}
+
+	code, _ := totp.GenerateCode(secret, time.Now())
+	result, err := service.VerifyMFA(&dto.MFAVerifyRequest{MFAToken: login.MFAToken, Code: code}, client)
+	if err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if result.AccessToken == "" {
+		t.Fatal("expected tokens after the second factor")
+	}
+
+	throttle := GetLoginThrottle()
+	throttle.mu.Lock()
+	_, pending := throttle.counters[usernameThrottleKey(user.UserName)]
+	throttle.mu.Unlock()
+	if pending {
+		t.Fatal("expected the user failure counter to be reset after the second factor")
+	}
+}
+
+func TestRegisterRequiresEmailVerification(t *testing.T) {
+	db := setupTestDB(t)
+	loadTestConfig(t, map[string]string{"REQUIRE_EMAIL_VERIFICATION": "true"})
+	service := NewAuthService()
+
+	result, err := service.Register(&dto.RegisterRequest{
+		Name:     "Ana",
+		UserName: "ana",
+		Email:    "ana@example.com",
+		Password: "Registro2024",
+	}, dto.ClientInfo{IPAddress: "198.51.100.12"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if !result.EmailVerificationRequired || result.AccessToken != "" || result.RefreshToken != "" {
+		t.Fatalf("expected no tokens before verifying the email, got %+v", result)
+	}
+	var sessions int64
+	db.Model(&models.Session{}).Count(&sessions)
+	if sessions != 0 {
+		t.Fatalf("expected no session to be opened, found %d", sessions)
+	}
+
+	// Una sesión previa a exigir la verificación no puede refrescarse
+	var user models.User
+	db.Preload("Role").First(&user, result.User.ID)
+	issued, err := service.issueTokens(&user, dto.ClientInfo{}, []string{authMethodPassword})
+	if err != nil {
+		t.Fatalf("issueTokens: %v", err)
+	}
+	if _, err := service.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: issued.RefreshToken}); !hasStatus(err, http.StatusForbidden) {
+		t.Fatalf("expected refresh to be forbidden for an unverified user, got %v", err)
+	}
+
+	// Tras verificar el email el refresh vuelve a funcionar
+	now := time.Now()
+	db.Model(&user).Update("email_verified_at", &now)
+	issued, err = service.issueTokens(&user, dto.ClientInfo{}, []string{authMethodPassword})
+	if err != nil {
+		t.Fatalf("issueTokens: %v", err)
+	}
+	if _, err := service.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: issued.RefreshToken}); err != nil {
+		t.Fatalf("expected refresh to work once verified, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errInvalidCredentials usuario o contraseña incorrectos; Login lo cuenta como intento fallido
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator valida el primer factor del login con usuario y contraseña.
+// local es la cuenta con ese username, o nil si no existe; devuelve la cuenta autenticada
+// (con Role cargado), que puede haberse creado o actualizado en el proceso.
+type Authenticator interface {
+	Authenticate(userName, password string, local *models.User) (*models.User, error)
+}
+
+// DBAuthenticator valida la contraseña contra el hash guardado en la base de datos
+type DBAuthenticator struct {
+	hasher utils.PasswordHasher
+}
+
+// NewDBAuthenticator crea el autenticador de cuentas locales
+func NewDBAuthenticator(hasher utils.PasswordHasher) *DBAuthenticator {
+	return &DBAuthenticator{hasher: hasher}
+}
+
+func (a *DBAuthenticator) Authenticate(userName, password string, local *models.User) (*models.User, error) {
+	if local == nil {
+		// Comparar contra un hash ficticio para que el tiempo de respuesta no delate
+		// que el usuario no existe
+		a.hasher.ComparePassword(dummyPasswordHash(a.hasher), password)
+		return nil, errInvalidCredentials
+	}
+
+	if err := a.hasher.ComparePassword(local.Password, password); err != nil {
+		return nil, errInvalidCredentials
+	}
+
+	// Migrar el hash al algoritmo y costo configurados ahora que se conoce la contraseña
+	if local.IsActive {
+		a.rehashIfNeeded(local, password)
+	}
+	return local, nil
+}
+
+// rehashIfNeeded rehace el hash si usa un algoritmo o costo desactualizado; un fallo no impide el login
+func (a *DBAuthenticator) rehashIfNeeded(user *models.User, password string) {
+	if !a.hasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := a.hasher.HashPassword(password)
+	if err != nil {
+		return
+	}
+
+	// Condicional sobre el hash anterior por si la contraseña cambió mientras tanto
+	result := database.GetDB().Model(&models.User{}).
+		Where("id = ? AND password = ?", user.ID, user.Password).
+		Update("password", hashedPassword)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	user.Password = hashedPassword
+
+	logger.Debug.WithFields(logrus.Fields{"user_id": user.ID}).Info("Hash de contraseña actualizado")
+}
+
+// authenticatorFor elige dónde validar la contraseña: con LDAP activo, el directorio, salvo para
+// las cuentas locales configuradas (el admin sembrado), que siguen entrando con la contraseña de la base
+func (s *AuthService) authenticatorFor(userName string) Authenticator {
+	if s.ldapAuth == nil {
+		return s.localAuth
+	}
+	for _, localUser := range config.Get().LDAP.LocalUsers {
+		if strings.EqualFold(localUser, userName) {
+			return s.localAuth
+		}
+	}
+	return s.ldapAuth
+}
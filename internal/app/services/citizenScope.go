@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// PermissionCitizensAllScopes permite ver y modificar ciudadanos de cualquier usuario o compañía
+const PermissionCitizensAllScopes = "citizens:all"
+
+// CitizenScope alcance de datos de ciudadanos del usuario autenticado.
+// Sin All, el usuario ve los registros que creó y los de su compañía.
+type CitizenScope struct {
+	UserID    uint
+	CompanyID *uint
+	All       bool
+}
+
+// ResolveCitizenScope arma el alcance del usuario: con el permiso citizens:all (admin) ve todo;
+// si no, la compañía se lee de la base para que un cambio aplique sin reemitir tokens
+func ResolveCitizenScope(claims *utils.JWTClaims) (*CitizenScope, error) {
+	all, err := GetPermissionCache().HasPermissions(claims.RoleID, PermissionCitizensAllScopes)
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		return &CitizenScope{UserID: claims.UserID, All: true}, nil
+	}
+
+	var user models.User
+	if err := database.GetDB().Select("id", "company_id").First(&user, claims.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewUnauthorizedError("Usuario no encontrado")
+		}
+		return nil, err
+	}
+
+	return &CitizenScope{UserID: user.ID, CompanyID: user.CompanyID}, nil
+}
+
+// Apply restringe la consulta a los ciudadanos dentro del alcance
+func (s *CitizenScope) Apply(query *gorm.DB) *gorm.DB {
+	if s.All {
+		return query
+	}
+	if s.CompanyID != nil {
+		return query.Where("(citizens.created_by = ? OR citizens.company_id = ?)", s.UserID, *s.CompanyID)
+	}
+	return query.Where("citizens.created_by = ?", s.UserID)
+}
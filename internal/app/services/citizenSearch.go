@@ -0,0 +1,162 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/filtering"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/pagination"
+)
+
+// Marcas con las que ts_headline delimita lo encontrado. Se reemplazan por <mark> después de
+// escapar el texto, así el HTML que recibe el front nunca incluye datos sin escapar.
+const (
+	highlightStart   = "\uE000"
+	highlightStop    = "\uE001"
+	highlightOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MinWords=10, MaxWords=30"
+)
+
+// citizenTSQuery consulta de texto a partir de lo que escribe el usuario (admite "frases", OR y -exclusión)
+const citizenTSQuery = "websearch_to_tsquery('" + database.CitizenSearchConfig + "', ?)"
+
+// identificationPrefix búsquedas solo con dígitos se comparan además como prefijo de la identificación
+var identificationPrefix = regexp.MustCompile(`^[0-9]{3,13}$`)
+
+// CitizenSearchSorting orden de la búsqueda: por relevancia salvo que se pida otro campo del listado
+var CitizenSearchSorting = func() pagination.Sortable {
+	fields := map[string]string{"relevance": "search_score"}
+	for name, column := range CitizenSorting.Fields {
+		fields[name] = column
+	}
+	return pagination.Sortable{Fields: fields, Default: "-relevance"}
+}()
+
+// citizenSearchRow ciudadano con la relevancia calculada por la consulta
+type citizenSearchRow struct {
+	models.Citizen
+	SearchScore float64
+}
+
+// citizenHighlightRow fragmentos de ts_headline de una fila de la página
+type citizenHighlightRow struct {
+	ID                          uint
+	Nombre                      string
+	RazonSocial                 string
+	NombreComercial             string
+	ActividadEconomicaPrincipal string
+}
+
+// SearchCitizens busca por texto en nombre, razón social, nombre comercial y actividad económica
+// (tsvector en español sin acentos, tolerante a errores de tipeo con pg_trgm) y por prefijo de
+// identificación. Respeta el alcance del usuario, los filtros del listado y la paginación.
+func (s *CitizenService) SearchCitizens(scope *CitizenScope, search *dto.CitizenSearchQuery, conditions filtering.Conditions, page *pagination.Params) ([]dto.CitizenSearchResult, *pagination.Meta, error) {
+	db := database.GetDB()
+	term := strings.TrimSpace(search.Q)
+
+	// Relevancia: rango del texto (0..1, normalizado) + similitud por palabras (0..1) + 1 si coincide la identificación
+	score := "ts_rank_cd(citizens.search_vector, " + citizenTSQuery + ", 32) + word_similarity(f_unaccent(lower(?)), citizens.search_text)"
+	match := "citizens.search_vector @@ " + citizenTSQuery + " OR f_unaccent(lower(?)) <% citizens.search_text"
+	scoreVars := []interface{}{term, term}
+	matchVars := []interface{}{term, term}
+
+	byIdentification := identificationPrefix.MatchString(term)
+	if byIdentification {
+		score += " + CASE WHEN citizens.numero_identificacion LIKE ? THEN 1 ELSE 0 END"
+		match += " OR citizens.numero_identificacion LIKE ?"
+		scoreVars = append(scoreVars, term+"%")
+		matchVars = append(matchVars, term+"%")
+	}
+
+	matched := s.filteredQuery(scope, &search.CitizenSearchFilters, conditions).
+		Select("citizens.*, ("+score+") AS search_score", scoreVars...).
+		Where("("+match+")", matchVars...)
+
+	// La subconsulta conserva el nombre citizens para que el orden use las mismas columnas que el
+	// listado; el borrado lógico ya se aplicó dentro
+	var rows []citizenSearchRow
+	meta, err := pagination.Find(db.Table("(?) AS citizens", matched).Unscoped(), page, &rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	highlights, err := s.searchHighlights(rows, term)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]dto.CitizenSearchResult, 0, len(rows))
+	for _, row := range rows {
+		result := dto.CitizenSearchResult{
+			CitizenResponse: *s.toCitizenResponse(&row.Citizen),
+			Score:           row.SearchScore,
+			Highlights:      highlights[row.ID],
+		}
+		if byIdentification && strings.HasPrefix(row.NumeroIdentificacion, term) {
+			if result.Highlights == nil {
+				result.Highlights = map[string]string{}
+			}
+			result.Highlights["numero_identificacion"] = "<mark>" + term + "</mark>" + html.EscapeString(strings.TrimPrefix(row.NumeroIdentificacion, term))
+		}
+		results = append(results, result)
+	}
+
+	return results, meta, nil
+}
+
+// searchHighlights arma los fragmentos resaltados solo para la página devuelta: ts_headline es caro
+// y calcularlo dentro de la búsqueda lo aplicaría a todas las coincidencias antes de paginar.
+// Las coincidencias solo por similitud (errores de tipeo) no tienen fragmento que resaltar.
+func (s *CitizenService) searchHighlights(rows []citizenSearchRow, term string) (map[uint]map[string]string, error) {
+	highlights := make(map[uint]map[string]string)
+	if len(rows) == 0 {
+		return highlights, nil
+	}
+
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	columns := []string{"nombre", "razon_social", "nombre_comercial", "actividad_economica_principal"}
+	selects := []string{"id"}
+	vars := make([]interface{}, 0, len(columns)*2)
+	for _, column := range columns {
+		selects = append(selects, "ts_headline('"+database.CitizenSearchConfig+"', coalesce("+column+", ''), "+citizenTSQuery+", ?) AS "+column)
+		vars = append(vars, term, highlightOptions)
+	}
+
+	var fragments []citizenHighlightRow
+	if err := database.GetDB().Table("citizens").Select(strings.Join(selects, ", "), vars...).Where("id IN ?", ids).Scan(&fragments).Error; err != nil {
+		return nil, err
+	}
+
+	for _, fragment := range fragments {
+		values := map[string]string{
+			"nombre":                        fragment.Nombre,
+			"razon_social":                  fragment.RazonSocial,
+			"nombre_comercial":              fragment.NombreComercial,
+			"actividad_economica_principal": fragment.ActividadEconomicaPrincipal,
+		}
+		for field, value := range values {
+			if !strings.Contains(value, highlightStart) {
+				continue
+			}
+			if highlights[fragment.ID] == nil {
+				highlights[fragment.ID] = map[string]string{}
+			}
+			highlights[fragment.ID][field] = markHighlights(value)
+		}
+	}
+	return highlights, nil
+}
+
+// markHighlights escapa el fragmento y cambia las marcas de ts_headline por <mark>
+func markHighlights(fragment string) string {
+	escaped := html.EscapeString(fragment)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
@@ -5,7 +5,11 @@ import (
 	"time"
 	"megabaseGo/internal/app/dto"
 	"megabaseGo/internal/database"
+	"megabaseGo/internal/filtering"
+	"megabaseGo/internal/identification"
 	"megabaseGo/internal/models"
+	"megabaseGo/internal/pagination"
+	"megabaseGo/internal/utils"
 	"gorm.io/gorm"
 )
 
@@ -19,14 +23,105 @@ func NewCitizenService() *CitizenService {
 	return &CitizenService{}
 }
 
-// GetAllCitizens obtiene todos los ciudadanos con filtros opcionales
+// CitizenSorting campos por los que se puede ordenar el listado de ciudadanos (?sort=)
+var CitizenSorting = pagination.Sortable{
+	Fields: map[string]string{
+		"id":                    "citizens.id",
+		"numero_identificacion": "citizens.numero_identificacion",
+		"tipo_identificacion":   "citizens.tipo_identificacion",
+		"nombre":                "citizens.nombre",
+		"razon_social":          "citizens.razon_social",
+		"nombre_comercial":      "citizens.nombre_comercial",
+		"estado_contribuyente":  "citizens.estado_contribuyente",
+		"provincia":             "citizens.provincia",
+		"ciudad":                "citizens.ciudad",
+		"created_at":            "citizens.created_at",
+		"updated_at":            "citizens.updated_at",
+	},
+	Default: "id",
+}
+
+// CitizenFilterFields campos admitidos en filter[campo][operador]= del listado, el cursor y la búsqueda
+var CitizenFilterFields = filtering.Fields{
+	"id":                            {Column: "citizens.id", Type: filtering.Number},
+	"numero_identificacion":         {Column: "citizens.numero_identificacion", Type: filtering.String},
+	"tipo_identificacion":           {Column: "citizens.tipo_identificacion", Type: filtering.String},
+	"email":                         {Column: "citizens.email", Type: filtering.String},
+	"pais":                          {Column: "citizens.pais", Type: filtering.String},
+	"provincia":                     {Column: "citizens.provincia", Type: filtering.String},
+	"ciudad":                        {Column: "citizens.ciudad", Type: filtering.String},
+	"nombre":                        {Column: "citizens.nombre", Type: filtering.String},
+	"fecha_nacimiento":              {Column: "citizens.fecha_nacimiento", Type: filtering.Time},
+	"nacionalidad":                  {Column: "citizens.nacionalidad", Type: filtering.String},
+	"estado_civil":                  {Column: "citizens.estado_civil", Type: filtering.String},
+	"genero":                        {Column: "citizens.genero", Type: filtering.String},
+	"razon_social":                  {Column: "citizens.razon_social", Type: filtering.String},
+	"nombre_comercial":              {Column: "citizens.nombre_comercial", Type: filtering.String},
+	"tipo_empresa":                  {Column: "citizens.tipo_empresa", Type: filtering.String},
+	"representantes_legales":        {Column: "citizens.representantes_legales", Type: filtering.JSON},
+	"tipo_contribuyente":            {Column: "citizens.tipo_contribuyente", Type: filtering.String},
+	"estado_contribuyente":          {Column: "citizens.estado_contribuyente", Type: filtering.String},
+	"regimen":                       {Column: "citizens.regimen", Type: filtering.String},
+	"categoria":                     {Column: "citizens.categoria", Type: filtering.String},
+	"obligado_contabilidad":         {Column: "citizens.obligado_contabilidad", Type: filtering.String},
+	"agente_retencion":              {Column: "citizens.agente_retencion", Type: filtering.String},
+	"contribuyente_especial":        {Column: "citizens.contribuyente_especial", Type: filtering.String},
+	"actividad_economica_principal": {Column: "citizens.actividad_economica_principal", Type: filtering.String},
+	"sucursales":                    {Column: "citizens.sucursales", Type: filtering.JSON},
+	"company_id":                    {Column: "citizens.company_id", Type: filtering.Number},
+	"created_by":                    {Column: "citizens.created_by", Type: filtering.Number},
+	"created_at":                    {Column: "citizens.created_at", Type: filtering.Time},
+	"updated_at":                    {Column: "citizens.updated_at", Type: filtering.Time},
+}
+
+// CitizenKeyset orden estable para recorrer ciudadanos con cursor (?cursor=&limit=); usa el
+// índice idx_citizens_created_at_id y no se degrada con millones de filas como OFFSET
+var CitizenKeyset = pagination.Keyset[models.Citizen]{
+	Resource: "citizens",
+	Fields: map[string]pagination.KeysetField[models.Citizen]{
+		"id":         {Column: "citizens.id"},
+		"created_at": {Column: "citizens.created_at", Value: func(c *models.Citizen) time.Time { return c.CreatedAt }},
+	},
+	Default:  "id",
+	IDColumn: "citizens.id",
+	ID:       func(c *models.Citizen) uint { return c.ID },
+}
+
+// GetAllCitizens obtiene los ciudadanos con filtros opcionales, paginados y ordenados
 // Este método es inteligente - permite filtrar por múltiples criterios
-func (s *CitizenService) GetAllCitizens(filters *dto.CitizenSearchFilters) ([]dto.CitizenResponse, error) {
-	db := database.GetDB()
+func (s *CitizenService) GetAllCitizens(scope *CitizenScope, filters *dto.CitizenSearchFilters, conditions filtering.Conditions, page *pagination.Params) ([]dto.CitizenResponse, *pagination.Meta, error) {
+	var citizens []models.Citizen
+
+	// Contar el total y traer solo la página pedida
+	// Esto es importante para no sobrecargar el sistema con muchos resultados
+	meta, err := pagination.Find(s.filteredQuery(scope, filters, conditions), page, &citizens)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.toCitizenResponses(citizens), meta, nil
+}
+
+// GetCitizensByCursor obtiene la siguiente tanda de ciudadanos a partir del cursor, con los mismos filtros
+// No cuenta el total: en tablas grandes el COUNT cuesta tanto como el OFFSET que se quiere evitar
+func (s *CitizenService) GetCitizensByCursor(scope *CitizenScope, filters *dto.CitizenSearchFilters, conditions filtering.Conditions, cursor *pagination.CursorParams) ([]dto.CitizenResponse, *pagination.CursorMeta, error) {
 	var citizens []models.Citizen
 
-	// Construir la query base
-	query := db.Model(&models.Citizen{})
+	meta, err := pagination.FindAfter(s.filteredQuery(scope, filters, conditions), CitizenKeyset, cursor, &citizens)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.toCitizenResponses(citizens), meta, nil
+}
+
+// filteredQuery arma la query del listado: alcance del usuario, los filtros clásicos presentes
+// y las condiciones genéricas filter[campo][operador]=
+func (s *CitizenService) filteredQuery(scope *CitizenScope, filters *dto.CitizenSearchFilters, conditions filtering.Conditions) *gorm.DB {
+	db := database.GetDB()
+
+	// Construir la query base, limitada al alcance del usuario
+	query := scope.Apply(db.Model(&models.Citizen{}))
 
 	// Aplicar filtros si están presentes
 	// Esto es como construir una búsqueda personalizada paso a paso
@@ -52,33 +147,27 @@ func (s *CitizenService) GetAllCitizens(filters *dto.CitizenSearchFilters) ([]dt
 		if filters.ObligadoContabilidad != nil {
 			query = query.Where("obligado_contabilidad = ?", *filters.ObligadoContabilidad)
 		}
-
-		// Aplicar paginación
-		// Esto es importante para no sobrecargar el sistema con muchos resultados
-		offset := (filters.Page - 1) * filters.PageSize
-		query = query.Offset(offset).Limit(filters.PageSize)
 	}
 
-	// Ejecutar la consulta
-	if err := query.Find(&citizens).Error; err != nil {
-		return nil, err
-	}
+	return conditions.Apply(query)
+}
 
-	// Convertir a DTOs de respuesta
-	var responses []dto.CitizenResponse
+// toCitizenResponses convierte una página de modelos a DTOs de respuesta
+func (s *CitizenService) toCitizenResponses(citizens []models.Citizen) []dto.CitizenResponse {
+	responses := make([]dto.CitizenResponse, 0, len(citizens))
 	for _, citizen := range citizens {
 		responses = append(responses, *s.toCitizenResponse(&citizen))
 	}
-
-	return responses, nil
+	return responses
 }
 
 // GetCitizenByID obtiene un ciudadano por su ID
-func (s *CitizenService) GetCitizenByID(id uint) (*dto.CitizenResponse, error) {
+// Fuera del alcance responde igual que si no existiera
+func (s *CitizenService) GetCitizenByID(scope *CitizenScope, id uint) (*dto.CitizenResponse, error) {
 	db := database.GetDB()
 	var citizen models.Citizen
 
-	if err := db.First(&citizen, id).Error; err != nil {
+	if err := scope.Apply(db).First(&citizen, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("citizen not found")
 		}
@@ -90,11 +179,11 @@ func (s *CitizenService) GetCitizenByID(id uint) (*dto.CitizenResponse, error) {
 
 // GetCitizenByEmail busca un ciudadano por email
 // Email debe ser único en el sistema
-func (s *CitizenService) GetCitizenByEmail(email string) (*dto.CitizenResponse, error) {
+func (s *CitizenService) GetCitizenByEmail(scope *CitizenScope, email string) (*dto.CitizenResponse, error) {
 	db := database.GetDB()
 	var citizen models.Citizen
 
-	if err := db.Where("email = ?", email).First(&citizen).Error; err != nil {
+	if err := scope.Apply(db).Where("email = ?", email).First(&citizen).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("ciudadano no encontrado")
 		}
@@ -106,11 +195,11 @@ func (s *CitizenService) GetCitizenByEmail(email string) (*dto.CitizenResponse,
 
 // GetCitizenByNumeroIdentificacion busca por número de identificación
 // Este es el método más importante - la identificación fiscal es única por ley
-func (s *CitizenService) GetCitizenByNumeroIdentificacion(numero string) (*dto.CitizenResponse, error) {
+func (s *CitizenService) GetCitizenByNumeroIdentificacion(scope *CitizenScope, numero string) (*dto.CitizenResponse, error) {
 	db := database.GetDB()
 	var citizen models.Citizen
 
-	if err := db.Where("numero_identificacion = ?", numero).First(&citizen).Error; err != nil {
+	if err := scope.Apply(db).Where("numero_identificacion = ?", numero).First(&citizen).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("citizen not found with this identification number")
 		}
@@ -122,11 +211,11 @@ func (s *CitizenService) GetCitizenByNumeroIdentificacion(numero string) (*dto.C
 
 // GetCitizenByRazonSocial busca empresas por razón social
 // Solo aplica para empresas (RUC), no para personas naturales
-func (s *CitizenService) GetCitizenByRazonSocial(razonSocial string) (*dto.CitizenResponse, error) {
+func (s *CitizenService) GetCitizenByRazonSocial(scope *CitizenScope, razonSocial string) (*dto.CitizenResponse, error) {
 	db := database.GetDB()
 	var citizen models.Citizen
 
-	if err := db.Where("razon_social = ?", razonSocial).First(&citizen).Error; err != nil {
+	if err := scope.Apply(db).Where("razon_social = ?", razonSocial).First(&citizen).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("citizen not found with this razon social")
 		}
@@ -138,9 +227,15 @@ func (s *CitizenService) GetCitizenByRazonSocial(razonSocial string) (*dto.Citiz
 
 // CreateCitizen crea un nuevo ciudadano con todas las validaciones necesarias
 // Este método es el corazón del sistema - debe validar todo cuidadosamente
-func (s *CitizenService) CreateCitizen(req *dto.CreateCitizenRequest) (*dto.CitizenResponse, error) {
+func (s *CitizenService) CreateCitizen(scope *CitizenScope, req *dto.CreateCitizenRequest) (*dto.CitizenResponse, error) {
 	db := database.GetDB()
 
+	// VALIDACIÓN 0: Compañía dueña dentro del alcance del usuario
+	companyID, err := s.resolveOwnerCompany(scope, req.CompanyID, scope.CompanyID)
+	if err != nil {
+		return nil, err
+	}
+
 	// VALIDACIÓN 1: Verificar que el número de identificación no exista
 	// Esta es la validación más crítica - no puede haber duplicados fiscales
 	if err := s.validateUniqueNumeroIdentificacion(req.NumeroIdentificacion, 0); err != nil {
@@ -166,6 +261,9 @@ func (s *CitizenService) CreateCitizen(req *dto.CreateCitizenRequest) (*dto.Citi
 
 	// Crear el modelo desde el DTO
 	citizen := s.createCitizenFromRequest(req)
+	citizen.CreatedBy = &scope.UserID
+	citizen.UpdatedBy = &scope.UserID
+	citizen.CompanyID = companyID
 
 	// Guardar en base de datos
 	if err := db.Create(&citizen).Error; err != nil {
@@ -176,18 +274,32 @@ func (s *CitizenService) CreateCitizen(req *dto.CreateCitizenRequest) (*dto.Citi
 }
 
 // UpdateCitizen actualiza un ciudadano existente
-func (s *CitizenService) UpdateCitizen(id uint, req *dto.UpdateCitizenRequest) (*dto.CitizenResponse, error) {
+func (s *CitizenService) UpdateCitizen(scope *CitizenScope, id uint, req *dto.UpdateCitizenRequest) (*dto.CitizenResponse, error) {
 	db := database.GetDB()
 	var citizen models.Citizen
 
-	// Obtener ciudadano existente
-	if err := db.First(&citizen, id).Error; err != nil {
+	// Obtener ciudadano existente dentro del alcance
+	if err := scope.Apply(db).First(&citizen, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("citizen not found")
 		}
 		return nil, err
 	}
 
+	// El número y el tipo deben seguir siendo coherentes aunque solo cambie uno de ellos
+	if req.NumeroIdentificacion != nil || req.TipoIdentificacion != nil {
+		numero, tipo := citizen.NumeroIdentificacion, citizen.TipoIdentificacion
+		if req.NumeroIdentificacion != nil {
+			numero = *req.NumeroIdentificacion
+		}
+		if req.TipoIdentificacion != nil {
+			tipo = *req.TipoIdentificacion
+		}
+		if _, err := identification.Validate(tipo, numero); err != nil {
+			return nil, utils.NewBadRequestError(err.Error())
+		}
+	}
+
 	// Validar cambios únicos si se están modificando
 	if req.NumeroIdentificacion != nil && *req.NumeroIdentificacion != citizen.NumeroIdentificacion {
 		if err := s.validateUniqueNumeroIdentificacion(*req.NumeroIdentificacion, id); err != nil {
@@ -214,8 +326,22 @@ func (s *CitizenService) UpdateCitizen(id uint, req *dto.UpdateCitizenRequest) (
 		}
 	}
 
+	// Cambio de compañía dueña
+	if req.CompanyID != nil {
+		var requested *uint
+		if *req.CompanyID != 0 {
+			requested = req.CompanyID
+		}
+		companyID, err := s.resolveOwnerCompany(scope, requested, citizen.CompanyID)
+		if err != nil {
+			return nil, err
+		}
+		citizen.CompanyID = companyID
+	}
+
 	// Aplicar cambios al modelo
 	s.applyCitizenUpdates(&citizen, req)
+	citizen.UpdatedBy = &scope.UserID
 
 	// Guardar cambios
 	if err := db.Save(&citizen).Error; err != nil {
@@ -226,12 +352,12 @@ func (s *CitizenService) UpdateCitizen(id uint, req *dto.UpdateCitizenRequest) (
 }
 
 // DeleteCitizen elimina un ciudadano (soft delete)
-func (s *CitizenService) DeleteCitizen(id uint) error {
+func (s *CitizenService) DeleteCitizen(scope *CitizenScope, id uint) error {
 	db := database.GetDB()
 
-	// Verificar que el ciudadano existe
+	// Verificar que el ciudadano existe dentro del alcance
 	var citizen models.Citizen
-	if err := db.First(&citizen, id).Error; err != nil {
+	if err := scope.Apply(db).First(&citizen, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("citizen not found")
 		}
@@ -242,6 +368,40 @@ func (s *CitizenService) DeleteCitizen(id uint) error {
 	return db.Delete(&citizen).Error
 }
 
+// CheckAvailability indica si el valor está libre para el campo único indicado dentro del
+// alcance del usuario. Un registro fuera del alcance no se revela aquí; la unicidad global
+// se sigue exigiendo al crear o actualizar.
+func (s *CitizenService) CheckAvailability(scope *CitizenScope, field, value string) (bool, error) {
+	switch field {
+	case "numero_identificacion", "email", "razon_social":
+	default:
+		return false, errors.New("unsupported availability field")
+	}
+
+	var count int64
+	if err := scope.Apply(database.GetDB().Model(&models.Citizen{})).
+		Where("citizens."+field+" = ?", value).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// resolveOwnerCompany decide la compañía dueña del registro. Quien ve todo puede asignar
+// cualquier compañía existente; el resto solo la suya (o dejar la actual).
+func (s *CitizenService) resolveOwnerCompany(scope *CitizenScope, requested, current *uint) (*uint, error) {
+	if requested == nil {
+		return current, nil
+	}
+
+	if !scope.All && (scope.CompanyID == nil || *requested != *scope.CompanyID) {
+		return nil, errors.New("cannot assign citizen to a company outside your scope")
+	}
+	if err := validateCompanyExists(database.GetDB(), *requested); err != nil {
+		return nil, err
+	}
+	return requested, nil
+}
+
 // --- MÉTODOS DE VALIDACIÓN PRIVADOS ---
 
 // validateUniqueNumeroIdentificacion verifica que el número de identificación sea único
@@ -513,6 +673,11 @@ func (s *CitizenService) toCitizenResponse(citizen *models.Citizen) *dto.Citizen
 		MotivoCancelacionSuspension: citizen.MotivoCancelacionSuspension,
 		CreatedAt:                   citizen.CreatedAt,
 		UpdatedAt:                   citizen.UpdatedAt,
+
+		// Propiedad
+		CreatedBy: citizen.CreatedBy,
+		UpdatedBy: citizen.UpdatedBy,
+		CompanyID: citizen.CompanyID,
 	}
 
 	// Calcular edad si hay fecha de nacimiento
@@ -537,6 +702,11 @@ func calculateAge(birthDate time.Time) int {
 	return age
 }
 
+// ValidateDNI valida una cédula o RUC ecuatoriano y devuelve su clase (cedula, ruc_natural, ...)
 func (s *CitizenService) ValidateDNI(numeroIdentificacion string) (string, error) {
-	return numeroIdentificacion, nil
+	kind, err := identification.Detect(numeroIdentificacion)
+	if err != nil {
+		return "", err
+	}
+	return string(kind), nil
 }
\ No newline at end of file
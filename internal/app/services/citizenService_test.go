@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/identification"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+)
+
+func TestUpdateCitizenRejectsInvalidIdentification(t *testing.T) {
+	db := setupTestDB(t)
+	citizen := models.Citizen{NumeroIdentificacion: "1710034065", TipoIdentificacion: identification.TypeCedula, Email: "ana@example.com"}
+	if err := db.Create(&citizen).Error; err != nil {
+		t.Fatalf("creating citizen: %v", err)
+	}
+
+	ruc := identification.TypeRUC
+	invalidCedula := "1710034066"
+	tests := []struct {
+		name string
+		req  dto.UpdateCitizenRequest
+	}{
+		// Solo cambia el tipo: la cédula actual no es un RUC válido
+		{name: "type change only", req: dto.UpdateCitizenRequest{TipoIdentificacion: &ruc}},
+		// Solo cambia el número: se valida contra el tipo actual
+		{name: "number change only", req: dto.UpdateCitizenRequest{NumeroIdentificacion: &invalidCedula}},
+	}
+
+	scope := &CitizenScope{All: true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCitizenService().UpdateCitizen(scope, citizen.ID, &tt.req)
+			var apiErr *utils.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+				t.Fatalf("expected bad request error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckAvailabilityIsScoped(t *testing.T) {
+	db := setupTestDB(t)
+	owner := createTestUser(t, db, "owner", "Secret123!", models.RoleUser)
+	other := createTestUser(t, db, "other", "Secret123!", models.RoleUser)
+
+	razon := "Comercial Andina S.A."
+	citizen := models.Citizen{
+		NumeroIdentificacion: "1710034065",
+		TipoIdentificacion:   identification.TypeCedula,
+		Email:                "ana@example.com",
+		RazonSocial:          &razon,
+		CreatedBy:            &owner.ID,
+	}
+	if err := db.Create(&citizen).Error; err != nil {
+		t.Fatalf("creating citizen: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		scope *CitizenScope
+		field string
+		value string
+		want  bool
+	}{
+		{name: "owner sees taken identification", scope: &CitizenScope{UserID: owner.ID}, field: "numero_identificacion", value: "1710034065", want: false},
+		{name: "owner sees taken email", scope: &CitizenScope{UserID: owner.ID}, field: "email", value: "ana@example.com", want: false},
+		{name: "owner sees taken razon social", scope: &CitizenScope{UserID: owner.ID}, field: "razon_social", value: razon, want: false},
+		{name: "global scope sees taken identification", scope: &CitizenScope{UserID: other.ID, All: true}, field: "numero_identificacion", value: "1710034065", want: false},
+		// Fuera del alcance no se confirma que el valor exista en otra parte
+		{name: "out of scope identification", scope: &CitizenScope{UserID: other.ID}, field: "numero_identificacion", value: "1710034065", want: true},
+		{name: "out of scope email", scope: &CitizenScope{UserID: other.ID}, field: "email", value: "ana@example.com", want: true},
+		{name: "out of scope razon social", scope: &CitizenScope{UserID: other.ID}, field: "razon_social", value: razon, want: true},
+		{name: "free value", scope: &CitizenScope{UserID: owner.ID}, field: "email", value: "nadie@example.com", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			available, err := NewCitizenService().CheckAvailability(tt.scope, tt.field, tt.value)
+			if err != nil {
+				t.Fatalf("CheckAvailability: %v", err)
+			}
+			if available != tt.want {
+				t.Fatalf("available = %v, want %v", available, tt.want)
+			}
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"megabaseGo/internal/app/dto"
 	app_errors "megabaseGo/internal/app/errors"
 	"megabaseGo/internal/database"
+	"megabaseGo/internal/filtering"
 	"megabaseGo/internal/models"
+	"megabaseGo/internal/pagination"
 
 	"gorm.io/gorm"
 )
@@ -106,7 +108,28 @@ func (s *CompanyService) DeleteCompany(id uint) error {
 	return nil
 }
 
-func (s *CompanyService) GetCompanies(filters *dto.CompanySearchFilters) ([]dto.CompanyResponse, error) {
+// CompanySorting campos por los que se puede ordenar el listado de compañías (?sort=)
+var CompanySorting = pagination.Sortable{
+	Fields: map[string]string{
+		"id":         "id",
+		"name":       "name",
+		"is_active":  "is_active",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+	Default: "id",
+}
+
+// CompanyFilterFields campos admitidos en filter[campo][operador]= del listado de compañías
+var CompanyFilterFields = filtering.Fields{
+	"id":         {Column: "id", Type: filtering.Number},
+	"name":       {Column: "name", Type: filtering.String},
+	"is_active":  {Column: "is_active", Type: filtering.Bool},
+	"created_at": {Column: "created_at", Type: filtering.Time},
+	"updated_at": {Column: "updated_at", Type: filtering.Time},
+}
+
+func (s *CompanyService) GetCompanies(filters *dto.CompanySearchFilters, conditions filtering.Conditions, page *pagination.Params) ([]dto.CompanyResponse, *pagination.Meta, error) {
 	var companies []models.Company
 	query := s.db.Model(&models.Company{})
 	if filters.Name != nil && *filters.Name != "" {
@@ -115,14 +138,15 @@ func (s *CompanyService) GetCompanies(filters *dto.CompanySearchFilters) ([]dto.
 	if filters.IsActive != nil {
 		query = query.Where("is_active = ?", *filters.IsActive)
 	}
-	if err := query.Find(&companies).Error; err != nil {
-		return nil, err
+	meta, err := pagination.Find(conditions.Apply(query), page, &companies)
+	if err != nil {
+		return nil, nil, err
 	}
-	resp := make([]dto.CompanyResponse, 0)
+	resp := make([]dto.CompanyResponse, 0, len(companies))
 	for _, c := range companies {
 		resp = append(resp, *toCompanyResponse(&c))
 	}
-	return resp, nil
+	return resp, meta, nil
 }
 
 func toCompanyResponse(company *models.Company) *dto.CompanyResponse {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/identification"
 	"megabaseGo/internal/logger"
 	"megabaseGo/internal/models"
 	"megabaseGo/internal/database"
@@ -28,23 +29,22 @@ func (s *ConsultService) GetCitizenByNumeroIdentificacion(req *dto.ConsultReques
 	length := len(id)
 	logger.Debug.WithFields(logrus.Fields{"id": id, "length": length}).Debug("Iniciando validación de identificación")
 
-	if length != 10 && length != 13 {
-		msg := "El número debe tener 10 o 13 dígitos"
+	// Cédula (módulo 10) o RUC (natural, sociedad privada o pública); el consumidor final no se consulta
+	kind, err := identification.Detect(id)
+	if err == nil && kind == identification.KindConsumidorFinal {
+		err = fmt.Errorf("El consumidor final no tiene datos que consultar")
+	}
+	if err != nil {
+		msg := err.Error()
 		logger.Debug.WithFields(logrus.Fields{"id": id}).Warn(msg)
 		return &dto.ConsultResponse{NumeroIdentificacion: id, Status: "invalid", Message: msg}, nil
 	}
 
 	idType := "cedula"
-	if length == 13 {
+	if kind.IsRUC() {
 		idType = "ruc"
-		suffix := id[10:]
-		logger.Debug.WithFields(logrus.Fields{"id": id, "suffix": suffix}).Debug("Validando sufijo de RUC")
-		if suffix != "001" {
-			msg := "Los últimos 3 dígitos del RUC son inválidos"
-			logger.Debug.WithFields(logrus.Fields{"id": id}).Warn(msg)
-			return &dto.ConsultResponse{NumeroIdentificacion: id, Status: "invalid", Message: msg}, nil
-		}
 	}
+	logger.Debug.WithFields(logrus.Fields{"id": id, "kind": kind}).Debug("Identificación válida")
 
 	logger.Debug.WithFields(logrus.Fields{"id": id}).Info("Consultando API externa")
 	raw, err := s.fetchExternalData(idType, id)
@@ -167,6 +167,11 @@ func (s *ConsultService) saveOrUpdateDB(idType string, raw interface{}) error {
 		logger.Debug.WithFields(logrus.Fields{"citizen_id": cit.ID}).Info("Citizen creado con éxito")
 	} else {
 		cit.ID = existing.ID
+		// La consulta actualiza los datos del SRI sin cambiar a quién pertenece el registro
+		cit.CreatedAt = existing.CreatedAt
+		cit.CreatedBy = existing.CreatedBy
+		cit.UpdatedBy = existing.UpdatedBy
+		cit.CompanyID = existing.CompanyID
 		if err := database.DB.Save(&cit).Error; err != nil {
 			return err
 		}
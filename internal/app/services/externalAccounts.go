@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Cuentas creadas y sincronizadas desde un proveedor externo (OIDC o directorio LDAP)
+
+// userNameInvalidChars caracteres no permitidos al derivar el username de una cuenta externa
+var userNameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// firstActiveRole primer rol activo de la lista de candidatos, en orden. Un candidato
+// inexistente o inactivo se ignora (queda en el log); sin ninguno devuelve nil.
+func firstActiveRole(candidates []string, source string) (*models.Role, error) {
+	db := database.GetDB()
+	for _, name := range candidates {
+		var role models.Role
+		err := db.Where("name = ? AND is_active = ?", name, true).First(&role).Error
+		if err == nil {
+			return &role, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		logger.Debug.WithFields(logrus.Fields{"source": source, "role": name}).Warn("Rol del mapeo externo inexistente o inactivo")
+	}
+	return nil, nil
+}
+
+// syncExternalRole aplica el rol que indica el proveedor. No degrada al último administrador
+// activo y revoca los tokens emitidos con el rol anterior.
+func syncExternalRole(user *models.User, role *models.Role, source string) (*models.User, error) {
+	if role == nil || role.ID == user.RoleID {
+		return user, nil
+	}
+
+	db := database.GetDB()
+	if err := ensureNotLastActiveAdmin(db, user); err != nil {
+		logger.Debug.WithFields(logrus.Fields{"source": source, "user_id": user.ID, "role": role.Name}).Warn("Rol externo no aplicado: es el último administrador activo")
+		return user, nil
+	}
+	if err := db.Model(user).Update("role_id", role.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := revokeUserAccess(user.ID, "role_synced", false); err != nil {
+		return nil, err
+	}
+
+	logger.Debug.WithFields(logrus.Fields{"source": source, "user_id": user.ID, "role": role.Name}).Info("Rol sincronizado desde el proveedor externo")
+	user.RoleID = role.ID
+	user.Role = *role
+	return user, nil
+}
+
+// createExternalUser crea la cuenta con email verificado y su vínculo con la identidad externa.
+// La contraseña es aleatoria: la cuenta entra por el proveedor hasta que el usuario la restablezca por correo.
+func createExternalUser(hasher utils.PasswordHasher, preferredUserName, name, email string, role *models.Role, identity models.UserIdentity) (*models.User, error) {
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&models.User{}).Where("LOWER(email) = ?", email).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, utils.NewConflictError("Ya existe otra cuenta con el email de esta identidad")
+	}
+
+	password, err := generateSecureToken(32)
+	if err != nil {
+		return nil, errors.New("failed to generate password")
+	}
+	hashedPassword, err := hasher.HashPassword(password)
+	if err != nil {
+		return nil, errors.New("failed to hash password")
+	}
+	rememberToken, err := generateSecureToken(32)
+	if err != nil {
+		return nil, errors.New("failed to generate remember token")
+	}
+	userName, err := availableUserName(db, preferredUserName, email)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = userName
+	}
+
+	now := time.Now()
+	user := models.User{
+		Name:            name,
+		UserName:        userName,
+		Email:           email,
+		Password:        hashedPassword,
+		RoleID:          role.ID,
+		IsActive:        true,
+		RememberToken:   rememberToken,
+		EmailVerifiedAt: &now,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return linkIdentity(tx, &user, identity)
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.Role = *role
+
+	logger.Debug.WithFields(logrus.Fields{
+		"provider": identity.Provider,
+		"user_id":  user.ID,
+		"role":     role.Name,
+	}).Info("Cuenta creada desde proveedor externo")
+
+	return &user, nil
+}
+
+// linkIdentity registra el vínculo entre la cuenta local y la identidad externa
+func linkIdentity(db *gorm.DB, user *models.User, identity models.UserIdentity) error {
+	now := time.Now()
+	identity.UserID = user.ID
+	identity.LastLoginAt = &now
+	return db.Create(&identity).Error
+}
+
+// availableUserName deriva un username libre del preferido o de la parte local del email
+func availableUserName(db *gorm.DB, preferred, email string) (string, error) {
+	base := strings.ToLower(strings.TrimSpace(preferred))
+	if base == "" || strings.Contains(base, "@") {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = strings.Trim(userNameInvalidChars.ReplaceAllString(strings.ToLower(base), ""), "._-")
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s%d", base, i)
+		}
+		var count int64
+		if err := db.Model(&models.User{}).Unscoped().Where("user_name = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return "", utils.NewConflictError("No se pudo generar un nombre de usuario disponible")
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	// Los tokens se firman con la clave HS256 para no depender de archivos de claves
+	os.Setenv("JWT_SECRET", "test-secret")
+	os.Setenv("PASSWORD_HASHER", "bcrypt")
+	os.Setenv("BCRYPT_COST", "4")
+	os.Exit(m.Run())
+}
+
+// setupTestDB abre una base SQLite en memoria propia de la prueba, migra los modelos y
+// siembra los roles de sistema. Reemplaza la conexión global mientras dura la prueba.
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	if err := db.AutoMigrate(models.AllModels...); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+
+	for _, name := range []string{models.RoleAdmin, models.RoleUser} {
+		role := models.Role{Name: name, DisplayName: name, IsActive: true, IsSystem: true}
+		if err := db.Create(&role).Error; err != nil {
+			t.Fatalf("seeding role %s: %v", name, err)
+		}
+	}
+
+	// Los IDs de rol se repiten entre bases de prueba: se descartan los permisos cacheados
+	cache := GetPermissionCache()
+	cache.mu.Lock()
+	cache.entries = make(map[uint]permissionCacheEntry)
+	cache.mu.Unlock()
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// loadTestConfig carga la configuración con las variables de entorno indicadas
+func loadTestConfig(t *testing.T, env map[string]string) *config.Config {
+	t.Helper()
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return config.LoadConfig()
+}
+
+// createTestRole crea un rol activo adicional
+func createTestRole(t *testing.T, db *gorm.DB, name string) *models.Role {
+	t.Helper()
+	role := models.Role{Name: name, DisplayName: name, IsActive: true}
+	if err := db.Create(&role).Error; err != nil {
+		t.Fatalf("creating role %s: %v", name, err)
+	}
+	return &role
+}
+
+// createTestUser crea un usuario local activo con el rol indicado y la contraseña hasheada
+func createTestUser(t *testing.T, db *gorm.DB, userName, password, roleName string) *models.User {
+	t.Helper()
+
+	var role models.Role
+	if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		t.Fatalf("finding role %s: %v", roleName, err)
+	}
+	hashed, err := utils.NewPasswordHasher().HashPassword(password)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+
+	user := models.User{
+		Name:          userName,
+		UserName:      userName,
+		Email:         userName + "@example.com",
+		Password:      hashed,
+		RoleID:        role.ID,
+		IsActive:      true,
+		RememberToken: userName + "-remember",
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("creating user %s: %v", userName, err)
+	}
+	user.Role = role
+	return &user
+}
+
+// hasStatus indica si el error es un APIError con el código HTTP indicado
+func hasStatus(err error, status int) bool {
+	var apiErr *utils.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
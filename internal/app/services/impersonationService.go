@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// PermissionUsersImpersonate permite suplantar a otros usuarios
+const PermissionUsersImpersonate = "users:impersonate"
+
+// ImpersonationService emite y termina tokens de suplantación para soporte.
+// El token es de corta duración, no tiene refresh token y lleva al actor real en el claim act.
+type ImpersonationService struct {
+	jwtManager  *utils.JWTManager
+	userService *UserService
+	audit       *AuditService
+}
+
+// NewImpersonationService crea una nueva instancia del servicio de suplantación
+func NewImpersonationService() *ImpersonationService {
+	return &ImpersonationService{
+		jwtManager:  utils.NewJWTManager(NewSessionService()),
+		userService: NewUserService(),
+		audit:       NewAuditService(),
+	}
+}
+
+// Start emite un access token del usuario indicado en nombre del actor autenticado
+func (s *ImpersonationService) Start(actor *utils.JWTClaims, targetID uint, client dto.ClientInfo) (*dto.ImpersonationResponse, error) {
+	if actor.IsImpersonated() {
+		return nil, utils.NewForbiddenError("Termine la suplantación actual antes de iniciar otra")
+	}
+	for _, method := range actor.AMR {
+		if method == "api_key" {
+			return nil, utils.NewForbiddenError("La suplantación no está disponible con API keys")
+		}
+	}
+	if actor.UserID == targetID {
+		return nil, utils.NewBadRequestError("No puede suplantarse a sí mismo")
+	}
+
+	var target models.User
+	if err := database.GetDB().Preload("Role").First(&target, targetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("Usuario")
+		}
+		return nil, err
+	}
+	if !target.IsActive {
+		return nil, utils.NewBadRequestError("No se puede suplantar a un usuario inactivo")
+	}
+
+	// Suplantar a otro usuario con el mismo privilegio permitiría encadenar identidades
+	privileged, err := GetPermissionCache().HasPermissions(target.RoleID, PermissionUsersImpersonate)
+	if err != nil {
+		return nil, err
+	}
+	if privileged {
+		return nil, utils.NewForbiddenError("No se puede suplantar a un usuario con permiso de suplantación")
+	}
+
+	ttl := config.Get().ImpersonationTTL
+	accessToken, err := s.jwtManager.GenerateTokenWithTTL(utils.JWTClaims{
+		UserID:   target.ID,
+		UserName: target.UserName,
+		Email:    target.Email,
+		RoleID:   target.RoleID,
+		RoleName: target.Role.Name,
+		AMR:      actor.AMR,
+		Act: &utils.ActorClaim{
+			Subject:  strconv.Itoa(int(actor.UserID)),
+			UserID:   actor.UserID,
+			UserName: actor.UserName,
+		},
+	}, ttl)
+	if err != nil {
+		return nil, errors.New("failed to generate access token")
+	}
+
+	// Se relee el token para registrar su jti y vigencia efectiva
+	claims, err := s.jwtManager.ValidateToken(accessToken)
+	if err != nil {
+		return nil, errors.New("failed to generate access token")
+	}
+
+	s.audit.Record(&models.AuditLog{
+		ActorID:   actor.UserID,
+		UserID:    target.ID,
+		Action:    AuditImpersonationStarted,
+		TokenID:   claims.ID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	})
+	logger.Debug.WithFields(logrus.Fields{"actor_id": actor.UserID, "user_id": target.ID}).Warn("Suplantación iniciada")
+
+	return &dto.ImpersonationResponse{
+		AccessToken:   accessToken,
+		TokenType:     "Bearer",
+		ExpiresIn:     int64(claims.ExpiresAt.Time.Sub(claims.IssuedAt.Time).Seconds()),
+		User:          *s.userService.toUserResponse(&target),
+		Impersonation: ImpersonationInfoFor(claims),
+	}, nil
+}
+
+// Stop revoca el token de suplantación con el que se hace la petición
+func (s *ImpersonationService) Stop(claims *utils.JWTClaims, client dto.ClientInfo) error {
+	if !claims.IsImpersonated() {
+		return utils.NewBadRequestError("La sesión actual no es una suplantación")
+	}
+
+	if err := GetTokenDenylist().RevokeToken(claims, "impersonation_stopped"); err != nil {
+		return err
+	}
+
+	s.audit.Record(&models.AuditLog{
+		ActorID:   claims.Act.UserID,
+		UserID:    claims.UserID,
+		Action:    AuditImpersonationStopped,
+		TokenID:   claims.ID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	})
+	logger.Debug.WithFields(logrus.Fields{"actor_id": claims.Act.UserID, "user_id": claims.UserID}).Info("Suplantación terminada")
+
+	return nil
+}
+
+// ImpersonationInfoFor describe la suplantación en curso para las respuestas; nil si no hay
+func ImpersonationInfoFor(claims *utils.JWTClaims) *dto.ImpersonationInfo {
+	if !claims.IsImpersonated() {
+		return nil
+	}
+
+	info := &dto.ImpersonationInfo{
+		Active:    true,
+		ActorID:   claims.Act.UserID,
+		ActorName: claims.Act.UserName,
+	}
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return info
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/mailer"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// errInvalidInvitation mismo mensaje para token inválido, invitación aceptada, revocada o expirada
+var errInvalidInvitation = utils.NewBadRequestError("Invitación inválida, expirada o revocada")
+
+// InvitationService maneja las invitaciones para crear cuentas con un rol asignado
+type InvitationService struct {
+	jwtManager  *utils.JWTManager
+	userService *UserService
+	mailer      mailer.Mailer
+}
+
+// NewInvitationService crea una nueva instancia del servicio de invitaciones
+func NewInvitationService() *InvitationService {
+	return &InvitationService{
+		jwtManager:  utils.NewJWTManager(NewSessionService()),
+		userService: NewUserService(),
+		mailer:      mailer.Default(),
+	}
+}
+
+// CreateInvitation registra la invitación y envía el enlace al email indicado
+func (s *InvitationService) CreateInvitation(inviter *utils.JWTClaims, req *dto.CreateInvitationRequest) (*dto.InvitationResponse, error) {
+	db := database.GetDB()
+	email := normalizeInvitationEmail(req.Email)
+
+	role, err := s.assignableRole(inviter, req.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	if req.CompanyID != nil {
+		if err := validateCompanyExists(db, *req.CompanyID); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkEmailNotRegistered(db, email); err != nil {
+		return nil, err
+	}
+
+	var pending int64
+	if err := pendingInvitations(db.Model(&models.Invitation{})).
+		Where("LOWER(email) = ?", email).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, utils.NewConflictError("Ya existe una invitación pendiente para ese email; puede reenviarla")
+	}
+
+	ttl := config.Get().InvitationTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	token, err := s.jwtManager.GeneratePurposeToken(utils.TokenTypeInvite, email, ttl)
+	if err != nil {
+		return nil, errors.New("failed to generate invitation token")
+	}
+
+	now := time.Now()
+	invitation := models.Invitation{
+		Email:      email,
+		RoleID:     role.ID,
+		CompanyID:  req.CompanyID,
+		InvitedBy:  inviter.UserID,
+		TokenHash:  hashUserToken(token),
+		ExpiresAt:  now.Add(ttl),
+		SentCount:  1,
+		LastSentAt: &now,
+	}
+	if err := db.Create(&invitation).Error; err != nil {
+		return nil, err
+	}
+	invitation.Role = *role
+
+	s.send(&invitation, inviter.UserName, token)
+
+	logger.Debug.WithFields(logrus.Fields{
+		"invitation_id": invitation.ID,
+		"email":         email,
+		"role":          role.Name,
+		"invited_by":    inviter.UserID,
+	}).Info("Invitación creada")
+
+	return s.toInvitationResponse(&invitation), nil
+}
+
+// GetInvitations lista las invitaciones del estado indicado (pending, accepted, revoked, expired o all)
+func (s *InvitationService) GetInvitations(status string) ([]dto.InvitationResponse, error) {
+	query := database.GetDB().Model(&models.Invitation{}).Preload("Role").Preload("Inviter").Order("created_at DESC")
+
+	switch status {
+	case "", models.InvitationPending:
+		query = pendingInvitations(query)
+	case models.InvitationAccepted:
+		query = query.Where("accepted_at IS NOT NULL")
+	case models.InvitationRevoked:
+		query = query.Where("revoked_at IS NOT NULL")
+	case models.InvitationExpired:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", time.Now())
+	case "all":
+	default:
+		return nil, utils.NewBadRequestError("Estado inválido: use pending, accepted, revoked, expired o all")
+	}
+
+	var invitations []models.Invitation
+	if err := query.Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.InvitationResponse, 0, len(invitations))
+	for i := range invitations {
+		responses = append(responses, *s.toInvitationResponse(&invitations[i]))
+	}
+	return responses, nil
+}
+
+// ResendInvitation rota el token, renueva la vigencia y vuelve a enviar el correo.
+// El enlace anterior deja de funcionar.
+func (s *InvitationService) ResendInvitation(inviter *utils.JWTClaims, id uint) (*dto.InvitationResponse, error) {
+	db := database.GetDB()
+
+	invitation, err := s.findInvitation(id)
+	if err != nil {
+		return nil, err
+	}
+	switch invitation.Status() {
+	case models.InvitationAccepted:
+		return nil, utils.NewConflictError("La invitación ya fue aceptada")
+	case models.InvitationRevoked:
+		return nil, utils.NewConflictError("La invitación fue revocada")
+	}
+
+	// Quien reenvía asume la invitación: no puede otorgar más permisos de los que tiene
+	if _, err := s.assignableRole(inviter, invitation.RoleID); err != nil {
+		return nil, err
+	}
+	if err := checkEmailNotRegistered(db, invitation.Email); err != nil {
+		return nil, err
+	}
+
+	ttl := config.Get().InvitationTTL
+	token, err := s.jwtManager.GeneratePurposeToken(utils.TokenTypeInvite, invitation.Email, ttl)
+	if err != nil {
+		return nil, errors.New("failed to generate invitation token")
+	}
+
+	now := time.Now()
+	result := db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+		Updates(map[string]interface{}{
+			"token_hash":   hashUserToken(token),
+			"expires_at":   now.Add(ttl),
+			"sent_count":   gorm.Expr("sent_count + 1"),
+			"last_sent_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, utils.NewConflictError("La invitación ya no está pendiente")
+	}
+
+	invitation, err = s.findInvitation(id)
+	if err != nil {
+		return nil, err
+	}
+	s.send(invitation, inviter.UserName, token)
+
+	logger.Debug.WithFields(logrus.Fields{
+		"invitation_id": invitation.ID,
+		"email":         invitation.Email,
+		"resent_by":     inviter.UserID,
+	}).Info("Invitación reenviada")
+
+	return s.toInvitationResponse(invitation), nil
+}
+
+// RevokeInvitation invalida una invitación pendiente
+func (s *InvitationService) RevokeInvitation(revokedBy, id uint) error {
+	invitation, err := s.findInvitation(id)
+	if err != nil {
+		return err
+	}
+	switch invitation.Status() {
+	case models.InvitationAccepted:
+		return utils.NewConflictError("La invitación ya fue aceptada")
+	case models.InvitationRevoked:
+		return nil
+	}
+
+	if err := database.GetDB().Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL", invitation.ID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	logger.Debug.WithFields(logrus.Fields{
+		"invitation_id": invitation.ID,
+		"email":         invitation.Email,
+		"revoked_by":    revokedBy,
+	}).Info("Invitación revocada")
+	return nil
+}
+
+// PreviewInvitation datos de la invitación para el formulario de aceptación
+func (s *InvitationService) PreviewInvitation(token string) (*dto.InvitationPreviewResponse, error) {
+	invitation, err := s.resolveToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.InvitationPreviewResponse{
+		Email:     invitation.Email,
+		RoleName:  invitation.Role.Name,
+		ExpiresAt: invitation.ExpiresAt,
+	}, nil
+}
+
+// AcceptInvitation crea el usuario con el email, rol y compañía de la invitación.
+// La invitación se marca antes de crear el usuario para que el enlace no pueda usarse dos veces;
+// si la creación falla (ej. username tomado o contraseña débil) vuelve a quedar pendiente.
+func (s *InvitationService) AcceptInvitation(req *dto.AcceptInvitationRequest) (*dto.UserResponse, error) {
+	db := database.GetDB()
+
+	invitation, err := s.resolveToken(req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	result := db.Model(&models.Invitation{}).
+		Where("id = ? AND token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID, invitation.TokenHash).
+		Update("accepted_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidInvitation
+	}
+
+	user, err := s.userService.CreateUser(nil, &dto.CreateUserRequest{
+		Name:      req.Name,
+		UserName:  req.UserName,
+		Email:     invitation.Email,
+		Password:  req.Password,
+		RoleID:    invitation.RoleID,
+		CompanyID: invitation.CompanyID,
+	})
+	if err != nil {
+		db.Model(&models.Invitation{}).Where("id = ?", invitation.ID).Update("accepted_at", nil)
+		return nil, err
+	}
+
+	// Recibir el enlace demuestra acceso al correo
+	now := time.Now()
+	if err := db.Model(&models.User{}).Where("id = ?", user.ID).Update("email_verified_at", now).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.Invitation{}).Where("id = ?", invitation.ID).Update("accepted_user_id", user.ID).Error; err != nil {
+		return nil, err
+	}
+
+	logger.Debug.WithFields(logrus.Fields{
+		"invitation_id": invitation.ID,
+		"user_id":       user.ID,
+		"role_id":       invitation.RoleID,
+	}).Info("Invitación aceptada")
+
+	return s.userService.GetUserByID(user.ID)
+}
+
+// resolveToken valida la firma del token y devuelve la invitación pendiente a la que corresponde
+func (s *InvitationService) resolveToken(token string) (*models.Invitation, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, errInvalidInvitation
+	}
+	if _, err := s.jwtManager.ValidatePurposeToken(token, utils.TokenTypeInvite); err != nil {
+		return nil, errInvalidInvitation
+	}
+
+	var invitation models.Invitation
+	if err := database.GetDB().Preload("Role").Where("token_hash = ?", hashUserToken(token)).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidInvitation
+		}
+		return nil, err
+	}
+	if invitation.Status() != models.InvitationPending || !invitation.Role.IsActive {
+		return nil, errInvalidInvitation
+	}
+	return &invitation, nil
+}
+
+// assignableRole verifica que el rol exista, esté activo y no otorgue permisos que el invitador no tiene
+func (s *InvitationService) assignableRole(inviter *utils.JWTClaims, roleID uint) (*models.Role, error) {
+	var role models.Role
+	if err := database.GetDB().First(&role, roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("role not found")
+		}
+		return nil, err
+	}
+	if !role.IsActive {
+		return nil, utils.NewBadRequestError("No se puede invitar con un rol inactivo")
+	}
+
+	if err := ensureRoleGrantable(inviter, role.ID); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (s *InvitationService) findInvitation(id uint) (*models.Invitation, error) {
+	var invitation models.Invitation
+	if err := database.GetDB().Preload("Role").Preload("Inviter").First(&invitation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("Invitación")
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// send envía el enlace de aceptación al invitado
+func (s *InvitationService) send(invitation *models.Invitation, inviterName, token string) {
+	link := fmt.Sprintf("%s/accept-invitation?token=%s", strings.TrimRight(config.Get().FrontURL, "/"), url.QueryEscape(token))
+	deliverMail(s.mailer, mailer.Message{
+		To:      invitation.Email,
+		Subject: "Invitación para crear su cuenta",
+		Body: fmt.Sprintf("Hola,\n\n%s lo invitó a crear una cuenta con el rol %s. Para aceptar ingrese al siguiente enlace "+
+			"(válido hasta el %s):\n\n%s\n\nSi no esperaba esta invitación, ignore este correo.",
+			inviterName, invitation.Role.DisplayName, invitation.ExpiresAt.Format("02/01/2006 15:04"), link),
+	})
+}
+
+// toInvitationResponse convierte un modelo Invitation a InvitationResponse
+func (s *InvitationService) toInvitationResponse(invitation *models.Invitation) *dto.InvitationResponse {
+	return &dto.InvitationResponse{
+		ID:             invitation.ID,
+		Email:          invitation.Email,
+		RoleID:         invitation.RoleID,
+		RoleName:       invitation.Role.Name,
+		CompanyID:      invitation.CompanyID,
+		InvitedBy:      invitation.InvitedBy,
+		InviterName:    invitation.Inviter.UserName,
+		Status:         invitation.Status(),
+		ExpiresAt:      invitation.ExpiresAt,
+		SentCount:      invitation.SentCount,
+		LastSentAt:     invitation.LastSentAt,
+		AcceptedAt:     invitation.AcceptedAt,
+		AcceptedUserID: invitation.AcceptedUserID,
+		RevokedAt:      invitation.RevokedAt,
+		CreatedAt:      invitation.CreatedAt,
+	}
+}
+
+// pendingInvitations restringe la consulta a invitaciones sin aceptar, revocar ni expirar
+func pendingInvitations(query *gorm.DB) *gorm.DB {
+	return query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now())
+}
+
+// checkEmailNotRegistered rechaza invitar un email que ya tiene cuenta
+func checkEmailNotRegistered(db *gorm.DB, email string) error {
+	var count int64
+	if err := db.Model(&models.User{}).Where("LOWER(email) = ?", email).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return utils.NewConflictError("Ya existe un usuario con ese email")
+	}
+	return nil
+}
+
+func normalizeInvitationEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
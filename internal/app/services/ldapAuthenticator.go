@@ -0,0 +1,296 @@
+package services
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ldapIdentityProvider nombre del proveedor en user_identities para las cuentas del directorio
+const ldapIdentityProvider = "ldap"
+
+var errDirectoryUnavailable = utils.NewInternalServerError("Servicio de directorio no disponible")
+
+// LDAPConn operaciones del directorio que usa el autenticador; *ldap.Conn la implementa
+// y un stub en memoria puede reemplazarla
+type LDAPConn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// LDAPDialer abre una conexión nueva con el directorio
+type LDAPDialer func() (LDAPConn, error)
+
+// LDAPAuthenticator valida la contraseña con un bind al directorio (LDAP o Active Directory):
+// busca al usuario con la cuenta de servicio, hace bind con su DN y mapea sus grupos a un rol local
+type LDAPAuthenticator struct {
+	cfg    config.LDAPConfig
+	dial   LDAPDialer
+	hasher utils.PasswordHasher
+}
+
+// NewLDAPAuthenticator crea el autenticador del directorio configurado
+func NewLDAPAuthenticator(cfg config.LDAPConfig, hasher utils.PasswordHasher) *LDAPAuthenticator {
+	return NewLDAPAuthenticatorWithDialer(cfg, hasher, func() (LDAPConn, error) {
+		return dialLDAP(cfg)
+	})
+}
+
+// NewLDAPAuthenticatorWithDialer permite conectar con otro servidor, por ejemplo un stub en pruebas
+func NewLDAPAuthenticatorWithDialer(cfg config.LDAPConfig, hasher utils.PasswordHasher, dial LDAPDialer) *LDAPAuthenticator {
+	return &LDAPAuthenticator{cfg: cfg, dial: dial, hasher: hasher}
+}
+
+func dialLDAP(cfg config.LDAPConfig) (LDAPConn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(cfg.Timeout)
+
+	if cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// ldapEntry datos del usuario leídos del directorio
+type ldapEntry struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+func (a *LDAPAuthenticator) Authenticate(userName, password string, local *models.User) (*models.User, error) {
+	// Un bind con contraseña vacía es un bind anónimo que muchos servidores aceptan
+	userName = strings.TrimSpace(userName)
+	if userName == "" || password == "" {
+		return nil, errInvalidCredentials
+	}
+
+	entry, err := a.verify(userName, password)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug.WithFields(logrus.Fields{"user_name": userName, "dn": entry.DN}).Info("Login LDAP")
+	return a.resolveUser(userName, entry, local)
+}
+
+// verify busca al usuario con la cuenta de servicio y valida su contraseña con un bind como él
+func (a *LDAPAuthenticator) verify(userName, password string) (*ldapEntry, error) {
+	conn, err := a.dial()
+	if err != nil {
+		logger.Debug.WithError(err).Error("Error conectando con el directorio LDAP")
+		return nil, errDirectoryUnavailable
+	}
+	defer conn.Close()
+
+	if a.cfg.BindDN != "" {
+		err = conn.Bind(a.cfg.BindDN, a.cfg.BindPassword)
+	} else {
+		err = conn.Bind("", "")
+	}
+	if err != nil {
+		logger.Debug.WithError(err).Error("Error en el bind de servicio LDAP")
+		return nil, errDirectoryUnavailable
+	}
+
+	filter := strings.ReplaceAll(a.cfg.UserFilter, "{username}", ldap.EscapeFilter(userName))
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.cfg.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(a.cfg.Timeout/time.Second), false,
+		filter,
+		[]string{a.cfg.EmailAttribute, a.cfg.NameAttribute, a.cfg.GroupAttribute},
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		logger.Debug.WithError(err).WithField("filter", filter).Error("Error buscando el usuario en LDAP")
+		return nil, errDirectoryUnavailable
+	}
+
+	// Un filtro que devuelve varias entradas es ambiguo: no se elige ninguna
+	if result == nil || len(result.Entries) != 1 {
+		if result != nil && len(result.Entries) > 1 {
+			logger.Debug.WithFields(logrus.Fields{"user_name": userName, "filter": filter}).Warn("El filtro LDAP devolvió varias entradas")
+		}
+		return nil, errInvalidCredentials
+	}
+	found := result.Entries[0]
+
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errInvalidCredentials
+		}
+		logger.Debug.WithError(err).WithField("dn", found.DN).Error("Error en el bind del usuario LDAP")
+		return nil, errDirectoryUnavailable
+	}
+
+	return &ldapEntry{
+		DN:     found.DN,
+		Email:  strings.ToLower(strings.TrimSpace(found.GetAttributeValue(a.cfg.EmailAttribute))),
+		Name:   strings.TrimSpace(found.GetAttributeValue(a.cfg.NameAttribute)),
+		Groups: found.GetAttributeValues(a.cfg.GroupAttribute),
+	}, nil
+}
+
+// resolveUser busca la cuenta vinculada al usuario del directorio; si no hay vínculo enlaza la cuenta
+// local con el mismo username (si se permite) o crea una nueva con el rol que indiquen sus grupos
+func (a *LDAPAuthenticator) resolveUser(userName string, entry *ldapEntry, local *models.User) (*models.User, error) {
+	db := database.GetDB()
+	now := time.Now()
+	identity := models.UserIdentity{
+		Provider: ldapIdentityProvider,
+		Subject:  strings.ToLower(userName),
+		Email:    entry.Email,
+	}
+
+	// 1. Identidad ya vinculada
+	var linked models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&linked).Error
+	if err == nil {
+		var user models.User
+		if err := db.Preload("Role").First(&user, linked.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, utils.NewForbiddenError("La cuenta vinculada a este usuario del directorio ya no existe")
+			}
+			return nil, err
+		}
+		db.Model(&linked).Updates(map[string]interface{}{"email": entry.Email, "last_login_at": now})
+		return a.syncRole(entry, &user)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 2. Cuenta local con el mismo username: solo se vincula si la configuración lo permite. Desde
+	// ese momento el directorio valida su contraseña, así que quien controle ese nombre en el
+	// directorio controla la cuenta: las cuentas privilegiadas nunca se vinculan
+	if local == nil {
+		var user models.User
+		err := db.Preload("Role").Where("LOWER(user_name) = ?", identity.Subject).First(&user).Error
+		if err == nil {
+			local = &user
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if local != nil {
+		if err := a.ensureLinkable(db, local); err != nil {
+			logger.Debug.WithFields(logrus.Fields{"user_id": local.ID, "dn": entry.DN}).Warn("Cuenta local no vinculada al directorio LDAP")
+			return nil, err
+		}
+		if err := linkIdentity(db, local, identity); err != nil {
+			return nil, err
+		}
+		logger.Debug.WithFields(logrus.Fields{"user_id": local.ID, "dn": entry.DN}).Info("Cuenta vinculada al directorio LDAP")
+		return a.syncRole(entry, local)
+	}
+
+	// 3. Alta con el rol de sus grupos
+	if entry.Email == "" {
+		return nil, utils.NewForbiddenError("El directorio no tiene un email para esta cuenta")
+	}
+	role, err := a.mappedRole(entry, true)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, utils.NewForbiddenError("Su usuario no tiene un rol asignado en esta aplicación")
+	}
+
+	return createExternalUser(a.hasher, userName, entry.Name, entry.Email, role, identity)
+}
+
+// ensureLinkable verifica que la cuenta local pueda pasar a validarse contra el directorio:
+// LinkLocalAccounts debe estar activo y la cuenta no puede tener rol admin ni de sistema
+func (a *LDAPAuthenticator) ensureLinkable(db *gorm.DB, user *models.User) error {
+	errConflict := utils.NewConflictError("Ya existe una cuenta local con este usuario; solicite al administrador que la vincule al directorio")
+	if !a.cfg.LinkLocalAccounts {
+		return errConflict
+	}
+
+	role := user.Role
+	if role.ID != user.RoleID {
+		if err := db.First(&role, user.RoleID).Error; err != nil {
+			return err
+		}
+	}
+	if role.Name == models.RoleAdmin || role.IsSystem {
+		return errConflict
+	}
+	return nil
+}
+
+// syncRole con SyncRole aplica el mapeo de grupos en cada login; sin grupo coincidente conserva el rol
+func (a *LDAPAuthenticator) syncRole(entry *ldapEntry, user *models.User) (*models.User, error) {
+	if !a.cfg.SyncRole {
+		return user, nil
+	}
+
+	role, err := a.mappedRole(entry, false)
+	if err != nil {
+		return nil, err
+	}
+	return syncExternalRole(user, role, ldapIdentityProvider)
+}
+
+// mappedRole primera regla de RoleMap cuyo grupo tenga el usuario; withDefault recurre a DefaultRole.
+// El grupo de la regla se compara, sin distinguir mayúsculas, con el DN completo o con su CN.
+func (a *LDAPAuthenticator) mappedRole(entry *ldapEntry, withDefault bool) (*models.Role, error) {
+	groups := make(map[string]bool)
+	for _, group := range entry.Groups {
+		groups[strings.ToLower(group)] = true
+		if cn := groupCommonName(group); cn != "" {
+			groups[strings.ToLower(cn)] = true
+		}
+	}
+
+	var candidates []string
+	for _, mapping := range a.cfg.RoleMap {
+		if groups[strings.ToLower(mapping.Value)] {
+			candidates = append(candidates, mapping.Role)
+		}
+	}
+	if withDefault && a.cfg.DefaultRole != "" {
+		candidates = append(candidates, a.cfg.DefaultRole)
+	}
+
+	return firstActiveRole(candidates, ldapIdentityProvider)
+}
+
+// groupCommonName CN del primer RDN de un DN de grupo ("CN=Admins,OU=Grupos,DC=corp" -> "Admins")
+func groupCommonName(groupDN string) string {
+	dn, err := ldap.ParseDN(groupDN)
+	if err != nil || len(dn.RDNs) == 0 {
+		return ""
+	}
+	for _, attr := range dn.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testLDAPBindDN       = "CN=svc-megabase,OU=Service,DC=corp,DC=local"
+	testLDAPBindPassword = "service-secret"
+)
+
+// fakeDirectory directorio LDAP en memoria: acepta el bind de servicio y el de cada usuario con su contraseña
+type fakeDirectory struct {
+	users       map[string]fakeDirectoryUser // sAMAccountName -> usuario
+	unavailable bool
+	dials       int
+}
+
+type fakeDirectoryUser struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+func (d *fakeDirectory) add(userName, password string, groups ...string) {
+	if d.users == nil {
+		d.users = make(map[string]fakeDirectoryUser)
+	}
+	d.users[strings.ToLower(userName)] = fakeDirectoryUser{
+		dn:       "CN=" + userName + ",OU=Users,DC=corp,DC=local",
+		password: password,
+		attrs: map[string][]string{
+			"mail":        {userName + "@corp.local"},
+			"displayName": {strings.ToUpper(userName[:1]) + userName[1:]},
+			"memberOf":    groups,
+		},
+	}
+}
+
+func (d *fakeDirectory) dial() (LDAPConn, error) {
+	d.dials++
+	if d.unavailable {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	return &fakeLDAPConn{dir: d}, nil
+}
+
+// fakeLDAPConn conexión con el directorio en memoria
+type fakeLDAPConn struct {
+	dir   *fakeDirectory
+	bound bool
+}
+
+var accountNameFilter = regexp.MustCompile(`\(sAMAccountName=([^)]*)\)`)
+
+func (c *fakeLDAPConn) Bind(username, password string) error {
+	c.bound = false
+	if username == testLDAPBindDN && password == testLDAPBindPassword {
+		c.bound = true
+		return nil
+	}
+	for _, user := range c.dir.users {
+		if user.dn == username && user.password == password && password != "" {
+			c.bound = true
+			return nil
+		}
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *fakeLDAPConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if !c.bound {
+		return nil, ldap.NewError(ldap.LDAPResultOperationsError, errors.New("bind required"))
+	}
+	result := &ldap.SearchResult{}
+	match := accountNameFilter.FindStringSubmatch(req.Filter)
+	if match == nil {
+		return result, nil
+	}
+	if user, ok := c.dir.users[strings.ToLower(match[1])]; ok {
+		result.Entries = append(result.Entries, ldap.NewEntry(user.dn, user.attrs))
+	}
+	return result, nil
+}
+
+func (c *fakeLDAPConn) Close() error { return nil }
+
+// setupLDAPTest configura LDAP contra el directorio en memoria y devuelve el servicio de login
+func setupLDAPTest(t *testing.T, dir *fakeDirectory, adjust func(*config.LDAPConfig)) (*AuthService, *LDAPAuthenticator) {
+	t.Helper()
+
+	cfg := loadTestConfig(t, map[string]string{"LDAP_ENABLED": "false"})
+	cfg.LDAP = config.LDAPConfig{
+		Enabled:        true,
+		BindDN:         testLDAPBindDN,
+		BindPassword:   testLDAPBindPassword,
+		BaseDN:         "DC=corp,DC=local",
+		UserFilter:     "(&(objectClass=user)(sAMAccountName={username}))",
+		EmailAttribute: "mail",
+		NameAttribute:  "displayName",
+		GroupAttribute: "memberOf",
+		RoleMap: []config.RoleMapping{
+			{Value: "CN=Megabase Admins,OU=Groups,DC=corp,DC=local", Role: models.RoleAdmin},
+			{Value: "megabase auditors", Role: "auditor"},
+		},
+		DefaultRole: models.RoleUser,
+		SyncRole:    true,
+		LocalUsers:  []string{"admin"},
+	}
+	if adjust != nil {
+		adjust(&cfg.LDAP)
+	}
+
+	authenticator := NewLDAPAuthenticatorWithDialer(cfg.LDAP, utils.NewPasswordHasher(), dir.dial)
+	service := NewAuthService()
+	service.SetLDAPAuthenticator(authenticator)
+	return service, authenticator
+}
+
+func testLogin(service *AuthService, userName, password string) (*dto.AuthResponse, error) {
+	return service.Login(&dto.LoginRequest{UserName: userName, Password: password}, dto.ClientInfo{IPAddress: "10.0.0." + userName})
+}
+
+func TestLDAPBind(t *testing.T) {
+	tests := []struct {
+		name        string
+		userName    string
+		password    string
+		unavailable bool
+		wantErr     error
+	}{
+		{name: "valid password", userName: "jdoe", password: "directory-pass"},
+		{name: "case-insensitive username", userName: "JDoe", password: "directory-pass"},
+		{name: "wrong password", userName: "jdoe", password: "wrong", wantErr: errInvalidCredentials},
+		{name: "empty password is not an anonymous bind", userName: "jdoe", password: "", wantErr: errInvalidCredentials},
+		{name: "unknown user", userName: "ghost", password: "directory-pass", wantErr: errInvalidCredentials},
+		{name: "filter injection", userName: "*)(sAMAccountName=jdoe", password: "directory-pass", wantErr: errInvalidCredentials},
+		{name: "directory unavailable", userName: "jdoe", password: "directory-pass", unavailable: true, wantErr: errDirectoryUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			dir := &fakeDirectory{unavailable: tt.unavailable}
+			dir.add("jdoe", "directory-pass")
+			_, authenticator := setupLDAPTest(t, dir, nil)
+
+			user, err := authenticator.Authenticate(tt.userName, tt.password, nil)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if user.Email != "jdoe@corp.local" || user.Name != "Jdoe" {
+				t.Fatalf("unexpected provisioned user %+v", user)
+			}
+		})
+	}
+}
+
+func TestLDAPGroupRoleMapping(t *testing.T) {
+	tests := []struct {
+		name      string
+		groups    []string
+		noDefault bool
+		wantRole  string
+		wantErr   bool
+	}{
+		{name: "full group DN", groups: []string{"CN=Megabase Admins,OU=Groups,DC=corp,DC=local"}, wantRole: models.RoleAdmin},
+		{name: "group DN in other case", groups: []string{"cn=megabase admins,ou=groups,dc=corp,dc=local"}, wantRole: models.RoleAdmin},
+		{name: "group CN", groups: []string{"CN=Megabase Auditors,OU=Other,DC=corp,DC=local"}, wantRole: "auditor"},
+		{
+			name:     "first matching rule wins",
+			groups:   []string{"CN=Megabase Auditors,OU=Groups,DC=corp,DC=local", "CN=Megabase Admins,OU=Groups,DC=corp,DC=local"},
+			wantRole: models.RoleAdmin,
+		},
+		{name: "no matching group uses default role", groups: []string{"CN=Staff,OU=Groups,DC=corp,DC=local"}, wantRole: models.RoleUser},
+		{name: "no matching group and no default role", groups: []string{"CN=Staff,OU=Groups,DC=corp,DC=local"}, noDefault: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			createTestRole(t, db, "auditor")
+			dir := &fakeDirectory{}
+			dir.add("jdoe", "directory-pass", tt.groups...)
+			_, authenticator := setupLDAPTest(t, dir, func(cfg *config.LDAPConfig) {
+				if tt.noDefault {
+					cfg.DefaultRole = ""
+				}
+			})
+
+			user, err := authenticator.Authenticate("jdoe", "directory-pass", nil)
+			if tt.wantErr {
+				var apiErr *utils.APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+					t.Fatalf("expected forbidden error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if user.Role.Name != tt.wantRole {
+				t.Fatalf("role = %q, want %q", user.Role.Name, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestLDAPSyncRoleOnLogin(t *testing.T) {
+	db := setupTestDB(t)
+	createTestRole(t, db, "auditor")
+	dir := &fakeDirectory{}
+	dir.add("jdoe", "directory-pass")
+	_, authenticator := setupLDAPTest(t, dir, nil)
+
+	user, err := authenticator.Authenticate("jdoe", "directory-pass", nil)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if user.Role.Name != models.RoleUser {
+		t.Fatalf("initial role = %q, want user", user.Role.Name)
+	}
+
+	dir.add("jdoe", "directory-pass", "CN=Megabase Auditors,OU=Groups,DC=corp,DC=local")
+	user, err = authenticator.Authenticate("jdoe", "directory-pass", nil)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if user.Role.Name != "auditor" {
+		t.Fatalf("synced role = %q, want auditor", user.Role.Name)
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected a single account, found %d", count)
+	}
+}
+
+func TestLDAPLocalAccountLinking(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     bool
+		role     string
+		wantLink bool
+	}{
+		{name: "linking disabled", link: false, role: "auditor"},
+		{name: "linking enabled", link: true, role: "auditor", wantLink: true},
+		{name: "admin account is never linked", link: true, role: models.RoleAdmin},
+		{name: "system role account is never linked", link: true, role: models.RoleUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			createTestRole(t, db, "auditor")
+			local := createTestUser(t, db, "jdoe", "local-pass-123", tt.role)
+			dir := &fakeDirectory{}
+			dir.add("jdoe", "directory-pass")
+			service, _ := setupLDAPTest(t, dir, func(cfg *config.LDAPConfig) {
+				cfg.LinkLocalAccounts = tt.link
+				cfg.SyncRole = false
+			})
+
+			auth, err := testLogin(service, "jdoe", "directory-pass")
+			var linked int64
+			db.Model(&models.UserIdentity{}).Where("user_id = ?", local.ID).Count(&linked)
+
+			if !tt.wantLink {
+				var apiErr *utils.APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+					t.Fatalf("expected conflict error, got %v", err)
+				}
+				if linked != 0 {
+					t.Fatal("local account must not be linked to the directory")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			if auth.User.ID != local.ID || linked != 1 {
+				t.Fatalf("expected local account %d to be linked, got user %d (links: %d)", local.ID, auth.User.ID, linked)
+			}
+		})
+	}
+}
+
+func TestLDAPFallbackToLocalAuthenticator(t *testing.T) {
+	db := setupTestDB(t)
+	createTestUser(t, db, "admin", "local-admin-pass", models.RoleAdmin)
+	dir := &fakeDirectory{unavailable: true}
+	service, _ := setupLDAPTest(t, dir, nil)
+
+	// Las cuentas de LocalUsers entran con la contraseña de la base aunque el directorio no responda
+	auth, err := testLogin(service, "admin", "local-admin-pass")
+	if err != nil {
+		t.Fatalf("local login: %v", err)
+	}
+	if auth.User.UserName != "admin" || auth.AccessToken == "" {
+		t.Fatalf("unexpected login response %+v", auth.User)
+	}
+	if dir.dials != 0 {
+		t.Fatalf("local account must not reach the directory, dialed %d times", dir.dials)
+	}
+	if _, err := testLogin(service, "admin", "wrong-pass"); err == nil || err.Error() != "invalid credentials" {
+		t.Fatalf("expected invalid credentials for a wrong local password, got %v", err)
+	}
+
+	// El resto de las cuentas depende del directorio
+	if _, err := testLogin(service, "jdoe", "directory-pass"); !errors.Is(err, errDirectoryUnavailable) {
+		t.Fatalf("expected directory unavailable, got %v", err)
+	}
+	if dir.dials != 1 {
+		t.Fatalf("expected the directory account to dial once, dialed %d times", dir.dials)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultLoginHistoryLimit = 50
+	maxLoginHistoryLimit     = 200
+)
+
+// LoginEventService historial de intentos de inicio de sesión
+type LoginEventService struct{}
+
+// NewLoginEventService crea una nueva instancia del servicio de historial de login
+func NewLoginEventService() *LoginEventService {
+	return &LoginEventService{}
+}
+
+// Record guarda un intento; un fallo se registra en el log pero no interrumpe el login
+func (s *LoginEventService) Record(event *models.LoginEvent) {
+	event.UserAgent = truncateRunes(event.UserAgent, 255)
+	event.UserName = truncateRunes(event.UserName, 255)
+	if err := database.GetDB().Create(event).Error; err != nil {
+		logger.Debug.WithFields(logrus.Fields{
+			"user_name": event.UserName,
+			"success":   event.Success,
+		}).WithError(err).Error("Error guardando el historial de login")
+	}
+}
+
+// GetUserLoginEvents últimos intentos de login del usuario, del más reciente al más antiguo
+func (s *LoginEventService) GetUserLoginEvents(userID uint, limit int) ([]dto.LoginEventResponse, error) {
+	if limit <= 0 {
+		limit = defaultLoginHistoryLimit
+	}
+	if limit > maxLoginHistoryLimit {
+		limit = maxLoginHistoryLimit
+	}
+
+	var events []models.LoginEvent
+	if err := database.GetDB().
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.LoginEventResponse, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, dto.LoginEventResponse{
+			ID:            event.ID,
+			Success:       event.Success,
+			Method:        event.Method,
+			FailureReason: event.FailureReason,
+			IPAddress:     event.IPAddress,
+			UserAgent:     event.UserAgent,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+	return responses, nil
+}
+
+// recordLoginSuccess registra el login que abrió la sesión sessionID
+func recordLoginSuccess(user *models.User, method, sessionID string, client dto.ClientInfo) {
+	NewLoginEventService().Record(&models.LoginEvent{
+		UserID:    &user.ID,
+		UserName:  user.UserName,
+		Success:   true,
+		Method:    method,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		SessionID: sessionID,
+	})
+}
+
+// recordLoginFailure registra un intento fallido; user es nil si la cuenta no existe o no se identificó
+func recordLoginFailure(user *models.User, userName, method, reason string, client dto.ClientInfo) {
+	event := &models.LoginEvent{
+		UserName:      userName,
+		Method:        method,
+		FailureReason: reason,
+		IPAddress:     client.IPAddress,
+		UserAgent:     client.UserAgent,
+	}
+	if user != nil {
+		event.UserID = &user.ID
+		event.UserName = user.UserName
+	}
+	NewLoginEventService().Record(event)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	loginBackoffBase = 1 * time.Second
+	// loginBackoffFree fallos que no generan espera, para no castigar un error de tipeo
+	loginBackoffFree = 2
+)
+
+// loginCounter fallos consecutivos de una clave (usuario o IP)
+type loginCounter struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// LoginThrottle cuenta los fallos de login por usuario y por IP en memoria.
+// Cada fallo aplica un backoff exponencial y al llegar al máximo bloquea la clave
+// durante el tiempo de bloqueo. Funciona igual exista o no el usuario.
+type LoginThrottle struct {
+	mu       sync.Mutex
+	counters map[string]*loginCounter
+}
+
+var (
+	loginThrottle     *LoginThrottle
+	loginThrottleOnce sync.Once
+)
+
+// GetLoginThrottle devuelve la instancia compartida del limitador de login
+func GetLoginThrottle() *LoginThrottle {
+	loginThrottleOnce.Do(func() {
+		loginThrottle = &LoginThrottle{counters: make(map[string]*loginCounter)}
+	})
+	return loginThrottle
+}
+
+func usernameThrottleKey(userName string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(userName))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// RetryAfter indica cuánto falta para que la clave pueda volver a intentar; 0 si no está bloqueada
+func (t *LoginThrottle) RetryAfter(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counter, ok := t.counters[key]
+	if !ok {
+		return 0
+	}
+	if wait := time.Until(counter.blockedUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Fail registra un fallo y devuelve el total de fallos consecutivos de la clave.
+// maxFailures y lockout definen el bloqueo; antes de llegar al máximo la espera crece 1s, 2s, 4s...
+func (t *LoginThrottle) Fail(key string, maxFailures int, lockout time.Duration) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.prune(now, lockout)
+
+	counter, ok := t.counters[key]
+	if !ok {
+		counter = &loginCounter{}
+		t.counters[key] = counter
+	}
+	counter.failures++
+	counter.lastFailure = now
+
+	switch {
+	case counter.failures >= maxFailures:
+		counter.blockedUntil = now.Add(lockout)
+	case counter.failures > loginBackoffFree:
+		delay := loginBackoffBase << uint(counter.failures-loginBackoffFree-1)
+		if delay > lockout {
+			delay = lockout
+		}
+		counter.blockedUntil = now.Add(delay)
+	}
+
+	return counter.failures
+}
+
+// Reset olvida los fallos de la clave (login exitoso o desbloqueo manual)
+func (t *LoginThrottle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.counters, key)
+}
+
+// prune descarta contadores sin fallos recientes ni bloqueo vigente; se llama con el mutex tomado
+func (t *LoginThrottle) prune(now time.Time, window time.Duration) {
+	for key, counter := range t.counters {
+		if now.After(counter.blockedUntil) && now.Sub(counter.lastFailure) > window {
+			delete(t.counters, key)
+		}
+	}
+}
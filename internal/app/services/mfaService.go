@@ -0,0 +1,397 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"image/png"
+	"strings"
+	"sync"
+	"time"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer         = "MegabaseGo"
+	totpPeriod         = 30
+	totpSkew           = 1 // pasos de tolerancia hacia atrás y adelante
+	recoveryCodeCount  = 10
+	mfaChallengeTTL    = 5 * time.Minute
+	mfaMaxFailedChecks = 5 // intentos fallidos permitidos por token de desafío
+)
+
+// MFAService maneja el enrolamiento TOTP, los códigos de recuperación y la verificación del segundo factor
+type MFAService struct {
+	hasher utils.PasswordHasher
+}
+
+// NewMFAService crea una nueva instancia del servicio MFA
+func NewMFAService() *MFAService {
+	return &MFAService{
+		hasher: utils.NewBcryptHasher(),
+	}
+}
+
+// EnrollTOTP genera un secreto TOTP pendiente de confirmación para el usuario
+func (s *MFAService) EnrollTOTP(userID uint) (*dto.TOTPEnrollmentResponse, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("User")
+		}
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return nil, utils.NewConflictError("TOTP ya está activado para este usuario")
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, errors.New("failed to generate TOTP secret")
+	}
+
+	qrCode, err := totpQRCode(key)
+	if err != nil {
+		return nil, errors.New("failed to generate QR code")
+	}
+
+	// El secreto queda guardado pero inactivo hasta que se confirme con un código válido
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":    key.Secret(),
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &dto.TOTPEnrollmentResponse{
+		Secret:     key.Secret(),
+		OTPAuthURI: key.URL(),
+		QRCodePNG:  qrCode,
+	}, nil
+}
+
+// ConfirmTOTP activa TOTP si el código corresponde al secreto pendiente y genera los códigos de recuperación
+func (s *MFAService) ConfirmTOTP(userID uint, code string) (*dto.RecoveryCodesResponse, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("User")
+		}
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return nil, utils.NewConflictError("TOTP ya está activado para este usuario")
+	}
+	if user.TOTPSecret == "" {
+		return nil, utils.NewBadRequestError("No hay un enrolamiento TOTP pendiente")
+	}
+
+	ok, err := s.checkTOTP(&user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, utils.NewBadRequestError("Código TOTP inválido")
+	}
+
+	if err := db.Model(&user).Update("totp_enabled", true).Error; err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug.WithFields(logrus.Fields{"user_id": user.ID}).Info("TOTP activado")
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP desactiva TOTP tras verificar contraseña y código
+func (s *MFAService) DisableTOTP(userID uint, req *dto.DisableTOTPRequest) error {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.Preload("Role").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewNotFoundError("User")
+		}
+		return err
+	}
+
+	if !user.TOTPEnabled {
+		return utils.NewBadRequestError("TOTP no está activado")
+	}
+	if user.Role.RequireMFA {
+		return utils.NewForbiddenError("El rol del usuario exige MFA")
+	}
+
+	if err := s.hasher.ComparePassword(user.Password, req.Password); err != nil {
+		return utils.NewUnauthorizedError("Contraseña incorrecta")
+	}
+	ok, err := s.VerifyCode(&user, req.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return utils.NewUnauthorizedError("Código MFA inválido")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled":   false,
+			"totp_secret":    "",
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes invalida los códigos anteriores y genera nuevos
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) (*dto.RecoveryCodesResponse, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewNotFoundError("User")
+		}
+		return nil, err
+	}
+
+	if !user.TOTPEnabled {
+		return nil, utils.NewBadRequestError("TOTP no está activado")
+	}
+
+	ok, err := s.checkTOTP(&user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, utils.NewUnauthorizedError("Código TOTP inválido")
+	}
+
+	codes, err := s.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// VerifyCode acepta un código TOTP vigente o un código de recuperación no usado
+func (s *MFAService) VerifyCode(user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if len(code) == 6 {
+		return s.checkTOTP(user, code)
+	}
+	return s.useRecoveryCode(user.ID, code)
+}
+
+// checkTOTP valida el código y registra su paso para que no pueda reutilizarse
+func (s *MFAService) checkTOTP(user *models.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+
+	now := time.Now()
+	opts := totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+	for offset := -totpSkew; offset <= totpSkew; offset++ {
+		at := now.Add(time.Duration(offset*totpPeriod) * time.Second)
+		step := at.Unix() / totpPeriod
+		if step <= user.TOTPLastStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCodeCustom(user.TOTPSecret, at, opts)
+		if err != nil {
+			return false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+			continue
+		}
+
+		// Actualización condicional: si otro request ya usó este paso, el código no vale
+		result := database.GetDB().Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return false, nil
+		}
+		user.TOTPLastStep = step
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// useRecoveryCode marca como usado el código de recuperación que coincida
+func (s *MFAService) useRecoveryCode(userID uint, code string) (bool, error) {
+	db := database.GetDB()
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return false, nil
+	}
+
+	var recoveryCodes []models.RecoveryCode
+	if err := db.Where("user_id = ? AND used_at IS NULL", userID).Find(&recoveryCodes).Error; err != nil {
+		return false, err
+	}
+
+	for _, rc := range recoveryCodes {
+		if s.hasher.ComparePassword(rc.CodeHash, code) != nil {
+			continue
+		}
+		result := db.Model(&models.RecoveryCode{}).
+			Where("id = ? AND used_at IS NULL", rc.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			logger.Debug.WithFields(logrus.Fields{"user_id": userID}).Warn("Código de recuperación MFA usado")
+			return true, nil
+		}
+		return false, nil
+	}
+
+	return false, nil
+}
+
+// replaceRecoveryCodes borra los códigos existentes y guarda el hash de los nuevos
+func (s *MFAService) replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashed := make([]models.RecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(raw)
+		hash, err := s.hasher.HashPassword(code)
+		if err != nil {
+			return nil, errors.New("failed to hash recovery code")
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashed = append(hashed, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&hashed).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode admite el código con o sin guion y en mayúsculas
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(code) != 10 {
+		return ""
+	}
+	return code
+}
+
+// totpQRCode genera el QR del URI otpauth como data URI PNG
+func totpQRCode(key *otp.Key) (string, error) {
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// mfaChallengeAttempts cuenta los intentos fallidos por token de desafío (jti)
+// para que un mismo desafío no permita probar códigos indefinidamente
+type mfaChallengeAttempts struct {
+	mu       sync.Mutex
+	failures map[string]int
+	expires  map[string]time.Time
+}
+
+var challengeAttempts = &mfaChallengeAttempts{
+	failures: make(map[string]int),
+	expires:  make(map[string]time.Time),
+}
+
+// blocked indica si el desafío ya agotó sus intentos
+func (a *mfaChallengeAttempts) blocked(jti string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.failures[jti] >= mfaMaxFailedChecks
+}
+
+// fail registra un intento fallido
+func (a *mfaChallengeAttempts) fail(jti string, expiresAt time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune()
+	a.failures[jti]++
+	a.expires[jti] = expiresAt
+}
+
+// consume agota el desafío para que no pueda reutilizarse tras un login exitoso
+func (a *mfaChallengeAttempts) consume(jti string, expiresAt time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune()
+	a.failures[jti] = mfaMaxFailedChecks
+	a.expires[jti] = expiresAt
+}
+
+// prune purga los desafíos expirados; se llama con el mutex tomado
+func (a *mfaChallengeAttempts) prune() {
+	now := time.Now()
+	for id, exp := range a.expires {
+		if now.After(exp) {
+			delete(a.expires, id)
+			delete(a.failures, id)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/oidc"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// oidcStateTTL tiempo que tiene el usuario para autenticarse en el proveedor
+const oidcStateTTL = 10 * time.Minute
+
+var errInvalidOIDCState = utils.NewUnauthorizedError("Inicio de sesión externo inválido o expirado, intente nuevamente")
+
+var (
+	oidcProviders   = make(map[string]*oidc.Provider)
+	oidcProvidersMu sync.Mutex
+)
+
+// oidcProvider devuelve el cliente del proveedor configurado; se comparte para reutilizar discovery y JWKS
+func oidcProvider(name string) (*oidc.Provider, *config.OIDCProviderConfig, error) {
+	providerCfg, ok := config.Get().OIDCProvider(name)
+	if !ok {
+		return nil, nil, utils.NewNotFoundError("OIDC provider")
+	}
+
+	oidcProvidersMu.Lock()
+	defer oidcProvidersMu.Unlock()
+
+	provider, ok := oidcProviders[name]
+	if !ok {
+		provider = oidc.NewProvider(oidc.Config{
+			Issuer:       providerCfg.Issuer,
+			ClientID:     providerCfg.ClientID,
+			ClientSecret: providerCfg.ClientSecret,
+			RedirectURL:  providerCfg.RedirectURL,
+			Scopes:       providerCfg.Scopes,
+		}, nil)
+		oidcProviders[name] = provider
+	}
+	return provider, providerCfg, nil
+}
+
+// OIDCLoginResult resultado del callback: la sesión emitida y a dónde volver en el front
+type OIDCLoginResult struct {
+	Auth     *dto.AuthResponse
+	ReturnTo string
+}
+
+// OIDCService inicio de sesión con proveedores OpenID Connect (SSO)
+type OIDCService struct {
+	authService *AuthService
+	hasher      utils.PasswordHasher
+}
+
+// NewOIDCService crea una nueva instancia del servicio OIDC
+func NewOIDCService() *OIDCService {
+	return &OIDCService{
+		authService: NewAuthService(),
+		hasher:      utils.NewPasswordHasher(),
+	}
+}
+
+// GetProviders lista los proveedores configurados para mostrarlos en la pantalla de login
+func (s *OIDCService) GetProviders() []dto.OIDCProviderResponse {
+	providers := config.Get().OIDCProviders
+	responses := make([]dto.OIDCProviderResponse, 0, len(providers))
+	for _, p := range providers {
+		responses = append(responses, dto.OIDCProviderResponse{
+			Name:        p.Name,
+			DisplayName: p.DisplayName,
+			LoginURL:    "/api/v1/auth/oidc/" + p.Name + "/login",
+		})
+	}
+	return responses
+}
+
+// BeginLogin registra state, nonce y code verifier y devuelve la URL de autorización del proveedor.
+// El state también se devuelve para guardarlo en una cookie y ligar el callback al navegador.
+func (s *OIDCService) BeginLogin(ctx context.Context, providerName, returnTo string) (string, string, error) {
+	provider, _, err := oidcProvider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := oidc.NewRandomToken()
+	if err != nil {
+		return "", "", errors.New("failed to generate oidc state")
+	}
+	nonce, err := oidc.NewRandomToken()
+	if err != nil {
+		return "", "", errors.New("failed to generate oidc nonce")
+	}
+	verifier, err := oidc.NewRandomToken()
+	if err != nil {
+		return "", "", errors.New("failed to generate pkce verifier")
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		logger.Debug.WithFields(logrus.Fields{"provider": providerName}).WithError(err).Error("Error consultando el proveedor OIDC")
+		return "", "", utils.NewInternalServerError("El proveedor de identidad no está disponible")
+	}
+
+	db := database.GetDB()
+	db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{})
+	if err := db.Create(&models.OIDCLoginState{
+		StateHash:    hashUserToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ReturnTo:     safeReturnPath(returnTo),
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}).Error; err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// CompleteLogin valida el callback, canjea el código, verifica el ID token y emite la sesión local
+func (s *OIDCService) CompleteLogin(ctx context.Context, providerName, state, cookieState, code string, client dto.ClientInfo) (*OIDCLoginResult, error) {
+	provider, providerCfg, err := oidcProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	// El state debe coincidir con el de la cookie del navegador que inició el login
+	if state == "" || code == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		return nil, errInvalidOIDCState
+	}
+	loginState, err := consumeOIDCState(state)
+	if err != nil {
+		return nil, err
+	}
+	if loginState.Provider != providerName {
+		return nil, errInvalidOIDCState
+	}
+
+	tokens, err := provider.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		logger.Debug.WithFields(logrus.Fields{"provider": providerName}).WithError(err).Warn("Error canjeando el código OIDC")
+		return nil, utils.NewUnauthorizedError("No se pudo completar el inicio de sesión con el proveedor")
+	}
+	idToken, err := provider.VerifyIDToken(ctx, tokens.IDToken, loginState.Nonce)
+	if err != nil {
+		logger.Debug.WithFields(logrus.Fields{"provider": providerName}).WithError(err).Warn("ID token OIDC rechazado")
+		return nil, utils.NewUnauthorizedError("No se pudo completar el inicio de sesión con el proveedor")
+	}
+
+	user, err := s.resolveUser(providerCfg, idToken)
+	if err != nil {
+		recordLoginFailure(nil, idToken.Email, authMethodOIDC, models.LoginFailureExternal, client)
+		return nil, err
+	}
+	if !user.IsActive {
+		recordLoginFailure(user, user.UserName, authMethodOIDC, models.LoginFailureDisabled, client)
+		return nil, errors.New("user account is disabled")
+	}
+
+	logger.Debug.WithFields(logrus.Fields{
+		"provider": providerName,
+		"subject":  idToken.Subject,
+		"user_id":  user.ID,
+	}).Info("Login OIDC")
+
+	auth, err := s.authService.finishLogin(user, client, []string{authMethodOIDC})
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCLoginResult{Auth: auth, ReturnTo: loginState.ReturnTo}, nil
+}
+
+// resolveUser busca la cuenta vinculada a la identidad; si no hay vínculo la enlaza por email verificado
+// o, con AutoProvision, crea la cuenta con el rol que indique el mapeo de claims
+func (s *OIDCService) resolveUser(providerCfg *config.OIDCProviderConfig, idToken *oidc.IDToken) (*models.User, error) {
+	db := database.GetDB()
+	now := time.Now()
+	email := strings.ToLower(strings.TrimSpace(idToken.Email))
+	emailVerified := email != "" && (idToken.EmailVerified || providerCfg.TrustEmail)
+
+	// 1. Identidad ya vinculada
+	var identity models.UserIdentity
+	err := db.Where("provider = ? AND subject = ?", providerCfg.Name, idToken.Subject).First(&identity).Error
+	if err == nil {
+		var user models.User
+		if err := db.Preload("Role").First(&user, identity.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, utils.NewForbiddenError("La cuenta vinculada a esta identidad ya no existe")
+			}
+			return nil, err
+		}
+		db.Model(&identity).Updates(map[string]interface{}{"email": email, "last_login_at": now})
+		return s.syncRole(providerCfg, idToken, &user)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 2. Cuenta existente con el mismo email: solo se vincula si el proveedor lo verificó
+	if email != "" {
+		var user models.User
+		err := db.Preload("Role").Where("LOWER(email) = ?", email).First(&user).Error
+		if err == nil {
+			if !emailVerified {
+				return nil, utils.NewForbiddenError("El proveedor no verificó el email; no se puede vincular la cuenta existente")
+			}
+			if err := linkIdentity(db, &user, s.identity(providerCfg, idToken, email)); err != nil {
+				return nil, err
+			}
+			if user.EmailVerifiedAt == nil {
+				db.Model(&user).Update("email_verified_at", now)
+				user.EmailVerifiedAt = &now
+			}
+			logger.Debug.WithFields(logrus.Fields{"provider": providerCfg.Name, "user_id": user.ID}).Info("Identidad OIDC vinculada por email")
+			return s.syncRole(providerCfg, idToken, &user)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	// 3. Alta just-in-time
+	if !providerCfg.AutoProvision {
+		return nil, utils.NewForbiddenError("No existe una cuenta asociada a esta identidad; solicite acceso al administrador")
+	}
+	if !emailVerified {
+		return nil, utils.NewForbiddenError("El proveedor no entregó un email verificado")
+	}
+	role, err := s.mappedRole(providerCfg, idToken, true)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, utils.NewForbiddenError("Su identidad no tiene un rol asignado en esta aplicación")
+	}
+
+	return createExternalUser(s.hasher, idToken.PreferredUsername, idToken.Name, email, role, s.identity(providerCfg, idToken, email))
+}
+
+// identity vínculo de la cuenta con el sub del proveedor
+func (s *OIDCService) identity(providerCfg *config.OIDCProviderConfig, idToken *oidc.IDToken, email string) models.UserIdentity {
+	return models.UserIdentity{Provider: providerCfg.Name, Subject: idToken.Subject, Email: email}
+}
+
+// syncRole con SyncRole aplica el mapeo de claims en cada login; sin regla coincidente conserva el rol
+func (s *OIDCService) syncRole(providerCfg *config.OIDCProviderConfig, idToken *oidc.IDToken, user *models.User) (*models.User, error) {
+	if !providerCfg.SyncRole {
+		return user, nil
+	}
+
+	role, err := s.mappedRole(providerCfg, idToken, false)
+	if err != nil {
+		return nil, err
+	}
+	return syncExternalRole(user, role, "oidc:"+providerCfg.Name)
+}
+
+// mappedRole primera regla de RoleMap cuyo valor esté en el claim; withDefault recurre a DefaultRole.
+// Una regla que apunta a un rol inexistente o inactivo se ignora.
+func (s *OIDCService) mappedRole(providerCfg *config.OIDCProviderConfig, idToken *oidc.IDToken, withDefault bool) (*models.Role, error) {
+	values := make(map[string]bool)
+	for _, value := range idToken.Values(providerCfg.RoleClaim) {
+		values[value] = true
+	}
+
+	var candidates []string
+	for _, mapping := range providerCfg.RoleMap {
+		if values[mapping.Value] {
+			candidates = append(candidates, mapping.Role)
+		}
+	}
+	if withDefault && providerCfg.DefaultRole != "" {
+		candidates = append(candidates, providerCfg.DefaultRole)
+	}
+
+	return firstActiveRole(candidates, "oidc:"+providerCfg.Name)
+}
+
+// consumeOIDCState busca el login en curso y lo elimina para que el state no se reutilice
+func consumeOIDCState(state string) (*models.OIDCLoginState, error) {
+	db := database.GetDB()
+
+	var loginState models.OIDCLoginState
+	if err := db.Where("state_hash = ?", hashUserToken(state)).First(&loginState).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidOIDCState
+		}
+		return nil, err
+	}
+
+	result := db.Delete(&models.OIDCLoginState{}, loginState.ID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || time.Now().After(loginState.ExpiresAt) {
+		return nil, errInvalidOIDCState
+	}
+	return &loginState, nil
+}
+
+// safeReturnPath solo acepta rutas relativas del front para no convertir el login en un redirect abierto
+func safeReturnPath(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, "\\") {
+		return "/"
+	}
+	if len(returnTo) > 255 {
+		return "/"
+	}
+	return returnTo
+}
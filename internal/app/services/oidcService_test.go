@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/oidc"
+	"megabaseGo/internal/oidc/oidctest"
+	"megabaseGo/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testOIDCProvider = "corp"
+
+// setupOIDCTest levanta el proveedor de prueba y configura el servicio para usarlo
+func setupOIDCTest(t *testing.T, env map[string]string) (*oidctest.Server, *OIDCService) {
+	t.Helper()
+
+	server := oidctest.NewServer("megabase", "secret")
+	t.Cleanup(server.Close)
+
+	settings := map[string]string{
+		"OIDC_PROVIDERS":      testOIDCProvider,
+		"OIDC_CORP_ISSUER":    server.Issuer(),
+		"OIDC_CORP_CLIENT_ID": "megabase",
+		// El secreto se envía en la cabecera Basic, como en producción
+		"OIDC_CORP_CLIENT_SECRET": "secret",
+	}
+	for key, value := range env {
+		settings[key] = value
+	}
+	loadTestConfig(t, settings)
+
+	// Cada prueba usa su propio proveedor: se descarta el cliente cacheado de la anterior
+	oidcProvidersMu.Lock()
+	oidcProviders = make(map[string]*oidc.Provider)
+	oidcProvidersMu.Unlock()
+
+	return server, NewOIDCService()
+}
+
+// oidcLogin recorre el flujo completo: inicio, autenticación en el proveedor y callback
+func oidcLogin(t *testing.T, server *oidctest.Server, service *OIDCService, claims jwt.MapClaims) (*OIDCLoginResult, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	authURL, cookieState, err := service.BeginLogin(ctx, testOIDCProvider, "/dashboard")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, state, err := server.Authorize(authURL, claims)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return service.CompleteLogin(ctx, testOIDCProvider, state, cookieState, code, dto.ClientInfo{IPAddress: "127.0.0.1"})
+}
+
+func TestOIDCCompleteLoginRejectsStateMismatch(t *testing.T) {
+	setupTestDB(t)
+	server, service := setupOIDCTest(t, nil)
+	ctx := context.Background()
+
+	authURL, cookieState, err := service.BeginLogin(ctx, testOIDCProvider, "/")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	code, state, err := server.Authorize(authURL, nil)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		provider    string
+		state       string
+		cookieState string
+	}{
+		{name: "cookie from another browser", provider: testOIDCProvider, state: state, cookieState: "other-state"},
+		{name: "missing cookie", provider: testOIDCProvider, state: state, cookieState: ""},
+		{name: "unknown state", provider: testOIDCProvider, state: "forged", cookieState: "forged"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CompleteLogin(ctx, tt.provider, tt.state, tt.cookieState, code, dto.ClientInfo{})
+			if !errors.Is(err, errInvalidOIDCState) {
+				t.Fatalf("expected errInvalidOIDCState, got %v", err)
+			}
+		})
+	}
+
+	// Los intentos rechazados no consumen el state: el callback legítimo sigue funcionando una sola vez
+	if _, err := service.CompleteLogin(ctx, testOIDCProvider, state, cookieState, code, dto.ClientInfo{}); !isForbidden(err) {
+		t.Fatalf("expected the legitimate callback to reach account resolution, got %v", err)
+	}
+	if _, err := service.CompleteLogin(ctx, testOIDCProvider, state, cookieState, code, dto.ClientInfo{}); !errors.Is(err, errInvalidOIDCState) {
+		t.Fatalf("expected a replayed state to be rejected, got %v", err)
+	}
+}
+
+func TestOIDCCompleteLoginRejectsNonceMismatch(t *testing.T) {
+	setupTestDB(t)
+	server, service := setupOIDCTest(t, map[string]string{"OIDC_CORP_AUTO_PROVISION": "true", "OIDC_CORP_DEFAULT_ROLE": models.RoleUser})
+
+	// El proveedor devuelve un nonce distinto del que se envió en la autorización
+	_, err := oidcLogin(t, server, service, jwt.MapClaims{"nonce": "replayed-nonce", "email": "ana@example.com", "email_verified": true})
+	var appErr *utils.APIError
+	if !errors.As(err, &appErr) || appErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+}
+
+func TestOIDCJITProvisioning(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		claims    jwt.MapClaims
+		wantRole  string
+		wantError bool
+	}{
+		{
+			name: "group mapped to admin",
+			env: map[string]string{
+				"OIDC_CORP_AUTO_PROVISION": "true",
+				"OIDC_CORP_ROLE_MAP":       "megabase-admins=admin,megabase-auditors=auditor",
+				"OIDC_CORP_DEFAULT_ROLE":   models.RoleUser,
+			},
+			claims:   jwt.MapClaims{"groups": []string{"staff", "megabase-admins"}},
+			wantRole: models.RoleAdmin,
+		},
+		{
+			name: "first matching rule wins",
+			env: map[string]string{
+				"OIDC_CORP_AUTO_PROVISION": "true",
+				"OIDC_CORP_ROLE_MAP":       "megabase-auditors=auditor,megabase-admins=admin",
+			},
+			claims:   jwt.MapClaims{"groups": []string{"megabase-admins", "megabase-auditors"}},
+			wantRole: "auditor",
+		},
+		{
+			name: "nested role claim",
+			env: map[string]string{
+				"OIDC_CORP_AUTO_PROVISION": "true",
+				"OIDC_CORP_ROLE_CLAIM":     "realm_access.roles",
+				"OIDC_CORP_ROLE_MAP":       "auditor=auditor",
+			},
+			claims:   jwt.MapClaims{"realm_access": map[string]interface{}{"roles": []string{"auditor"}}},
+			wantRole: "auditor",
+		},
+		{
+			name: "no matching group falls back to default role",
+			env: map[string]string{
+				"OIDC_CORP_AUTO_PROVISION": "true",
+				"OIDC_CORP_ROLE_MAP":       "megabase-admins=admin",
+				"OIDC_CORP_DEFAULT_ROLE":   models.RoleUser,
+			},
+			claims:   jwt.MapClaims{"groups": []string{"staff"}},
+			wantRole: models.RoleUser,
+		},
+		{
+			name: "mapping to a missing role is ignored",
+			env: map[string]string{
+				"OIDC_CORP_AUTO_PROVISION": "true",
+				"OIDC_CORP_ROLE_MAP":       "staff=does-not-exist",
+				"OIDC_CORP_DEFAULT_ROLE":   models.RoleUser,
+			},
+			claims:   jwt.MapClaims{"groups": []string{"staff"}},
+			wantRole: models.RoleUser,
+		},
+		{
+			name: "no matching group and no default role",
+			env: map[string]string{
+				"OIDC_CORP_AUTO_PROVISION": "true",
+				"OIDC_CORP_ROLE_MAP":       "megabase-admins=admin",
+			},
+			claims:    jwt.MapClaims{"groups": []string{"staff"}},
+			wantError: true,
+		},
+		{
+			name:      "auto provisioning disabled",
+			env:       map[string]string{"OIDC_CORP_DEFAULT_ROLE": models.RoleUser},
+			claims:    jwt.MapClaims{},
+			wantError: true,
+		},
+		{
+			name:      "unverified email",
+			env:       map[string]string{"OIDC_CORP_AUTO_PROVISION": "true", "OIDC_CORP_DEFAULT_ROLE": models.RoleUser},
+			claims:    jwt.MapClaims{"email_verified": false},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			createTestRole(t, db, "auditor")
+			server, service := setupOIDCTest(t, tt.env)
+
+			claims := jwt.MapClaims{
+				"sub":                "00u-ana",
+				"email":              "Ana.Perez@Example.com",
+				"email_verified":     true,
+				"name":               "Ana Pérez",
+				"preferred_username": "ana.perez",
+			}
+			for key, value := range tt.claims {
+				claims[key] = value
+			}
+
+			result, err := oidcLogin(t, server, service, claims)
+			if tt.wantError {
+				if !isForbidden(err) {
+					t.Fatalf("expected forbidden error, got %v", err)
+				}
+				var count int64
+				db.Model(&models.User{}).Count(&count)
+				if count != 0 {
+					t.Fatalf("expected no account to be created, found %d", count)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompleteLogin: %v", err)
+			}
+			if result.ReturnTo != "/dashboard" {
+				t.Fatalf("unexpected return path %q", result.ReturnTo)
+			}
+			if result.Auth.AccessToken == "" || result.Auth.RefreshToken == "" {
+				t.Fatal("expected a local session to be issued")
+			}
+
+			var user models.User
+			if err := db.Preload("Role").Where("email = ?", "ana.perez@example.com").First(&user).Error; err != nil {
+				t.Fatalf("provisioned user not found: %v", err)
+			}
+			if user.Role.Name != tt.wantRole {
+				t.Fatalf("provisioned role = %q, want %q", user.Role.Name, tt.wantRole)
+			}
+			if user.UserName != "ana.perez" || user.Name != "Ana Pérez" || user.EmailVerifiedAt == nil {
+				t.Fatalf("unexpected provisioned user %+v", user)
+			}
+
+			var identity models.UserIdentity
+			if err := db.Where("provider = ? AND subject = ?", testOIDCProvider, "00u-ana").First(&identity).Error; err != nil {
+				t.Fatalf("identity link not found: %v", err)
+			}
+			if identity.UserID != user.ID {
+				t.Fatalf("identity linked to user %d, want %d", identity.UserID, user.ID)
+			}
+
+			// El segundo login usa el vínculo y no crea otra cuenta
+			if _, err := oidcLogin(t, server, service, claims); err != nil {
+				t.Fatalf("second login: %v", err)
+			}
+			var count int64
+			db.Model(&models.User{}).Count(&count)
+			if count != 1 {
+				t.Fatalf("expected a single account after two logins, found %d", count)
+			}
+		})
+	}
+}
+
+func TestOIDCSyncRoleOnLogin(t *testing.T) {
+	db := setupTestDB(t)
+	createTestRole(t, db, "auditor")
+	server, service := setupOIDCTest(t, map[string]string{
+		"OIDC_CORP_AUTO_PROVISION": "true",
+		"OIDC_CORP_ROLE_MAP":       "megabase-auditors=auditor",
+		"OIDC_CORP_DEFAULT_ROLE":   models.RoleUser,
+		"OIDC_CORP_SYNC_ROLE":      "true",
+	})
+
+	claims := jwt.MapClaims{"sub": "00u-ana", "email": "ana@example.com", "email_verified": true}
+	if _, err := oidcLogin(t, server, service, claims); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	claims["groups"] = []string{"megabase-auditors"}
+	result, err := oidcLogin(t, server, service, claims)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+
+	var user models.User
+	db.Preload("Role").First(&user, result.Auth.User.ID)
+	if user.Role.Name != "auditor" {
+		t.Fatalf("role after sync = %q, want auditor", user.Role.Name)
+	}
+}
+
+// isForbidden indica si el error es un AppError 403
+func isForbidden(err error) bool {
+	var appErr *utils.APIError
+	return errors.As(err, &appErr) && appErr.StatusCode == http.StatusForbidden
+}
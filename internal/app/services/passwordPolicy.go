@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// commonPasswords contraseñas más usadas; se complementa con PASSWORD_DENYLIST_FILE
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "12345", "1234567", "111111", "000000",
+	"123123", "654321", "987654321", "112233", "121212", "password", "password1", "password123",
+	"passw0rd", "p@ssw0rd", "qwerty", "qwerty123", "qwertyuiop", "asdfghjkl", "zxcvbnm", "1q2w3e4r",
+	"1qaz2wsx", "abc123", "abcd1234", "iloveyou", "admin", "admin123", "administrator", "root",
+	"welcome", "welcome1", "letmein", "monkey", "dragon", "football", "baseball", "sunshine",
+	"princess", "superman", "master", "shadow", "trustno1", "changeme", "secret", "login",
+	"contraseña", "contrasena", "contrasena123", "clave", "clave123", "123456a", "a123456",
+	"teamo", "tequiero", "ecuador", "ecuador123", "quito", "guayaquil", "megabase", "megabase123",
+}
+
+var (
+	passwordDenylist     map[string]struct{}
+	passwordDenylistOnce sync.Once
+)
+
+// loadPasswordDenylist arma la lista de contraseñas prohibidas una sola vez
+func loadPasswordDenylist(file string) map[string]struct{} {
+	passwordDenylistOnce.Do(func() {
+		passwordDenylist = make(map[string]struct{}, len(commonPasswords))
+		for _, p := range commonPasswords {
+			passwordDenylist[p] = struct{}{}
+		}
+
+		if file == "" {
+			return
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			logger.Debug.WithFields(logrus.Fields{"file": file}).WithError(err).Error("No se pudo leer la lista de contraseñas prohibidas")
+			return
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := strings.ToLower(strings.TrimSpace(scanner.Text())); line != "" {
+				passwordDenylist[line] = struct{}{}
+			}
+		}
+	})
+	return passwordDenylist
+}
+
+// PasswordPolicy valida contraseñas nuevas contra la política configurada y el historial del usuario
+type PasswordPolicy struct {
+	cfg      config.PasswordConfig
+	hasher   utils.PasswordHasher
+	denylist map[string]struct{}
+}
+
+// NewPasswordPolicy crea la política a partir de la configuración
+func NewPasswordPolicy() *PasswordPolicy {
+	cfg := config.Get().Password
+	return &PasswordPolicy{
+		cfg:      cfg,
+		hasher:   utils.NewPasswordHasher(),
+		denylist: loadPasswordDenylist(cfg.DenylistFile),
+	}
+}
+
+// Validate aplica longitud, clases de caracteres, lista prohibida y que no sea igual al usuario o email
+func (p *PasswordPolicy) Validate(password, userName, email string) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		violations = append(violations, "mínimo "+strconv.Itoa(p.cfg.MinLength)+" caracteres")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !hasUpper {
+		violations = append(violations, "al menos una mayúscula")
+	}
+	if p.cfg.RequireLower && !hasLower {
+		violations = append(violations, "al menos una minúscula")
+	}
+	if p.cfg.RequireDigit && !hasDigit {
+		violations = append(violations, "al menos un número")
+	}
+	if p.cfg.RequireSymbol && !hasSymbol {
+		violations = append(violations, "al menos un símbolo")
+	}
+
+	lower := strings.ToLower(password)
+	if _, denied := p.denylist[lower]; denied {
+		violations = append(violations, "es una contraseña demasiado común")
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	localPart := email
+	if at := strings.Index(email, "@"); at > 0 {
+		localPart = email[:at]
+	}
+	if userName != "" && lower == strings.ToLower(strings.TrimSpace(userName)) {
+		violations = append(violations, "no puede ser igual al nombre de usuario")
+	}
+	if email != "" && (lower == email || lower == localPart) {
+		violations = append(violations, "no puede ser igual al email")
+	}
+
+	if len(violations) > 0 {
+		return utils.NewBadRequestError("La contraseña no cumple la política: " + strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// CheckHistory rechaza la contraseña si coincide con la actual o con alguna de las últimas N
+func (p *PasswordPolicy) CheckHistory(db *gorm.DB, user *models.User, password string) error {
+	if p.cfg.HistorySize == 0 {
+		return nil
+	}
+
+	hashes := []string{user.Password}
+
+	var history []models.PasswordHistory
+	if err := db.Where("user_id = ?", user.ID).
+		Order("created_at DESC, id DESC").
+		Limit(p.cfg.HistorySize).
+		Find(&history).Error; err != nil {
+		return err
+	}
+	for _, h := range history {
+		hashes = append(hashes, h.PasswordHash)
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && p.hasher.ComparePassword(hash, password) == nil {
+			return utils.NewBadRequestError("La contraseña no puede ser igual a ninguna de las últimas " + strconv.Itoa(p.cfg.HistorySize))
+		}
+	}
+	return nil
+}
+
+// Remember guarda el hash de la contraseña recién establecida y descarta los que exceden el historial
+func (p *PasswordPolicy) Remember(db *gorm.DB, userID uint, hash string) error {
+	if p.cfg.HistorySize == 0 {
+		return nil
+	}
+
+	if err := db.Create(&models.PasswordHistory{UserID: userID, PasswordHash: hash}).Error; err != nil {
+		return err
+	}
+
+	var keep []uint
+	if err := db.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(p.cfg.HistorySize).
+		Pluck("id", &keep).Error; err != nil {
+		return err
+	}
+	return db.Where("user_id = ? AND id NOT IN ?", userID, keep).Delete(&models.PasswordHistory{}).Error
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// permissionCacheTTL tiempo máximo que otra instancia puede tardar en ver un cambio de permisos
+const permissionCacheTTL = 30 * time.Second
+
+// maxRoleDepth niveles máximos de la jerarquía de roles
+const maxRoleDepth = 10
+
+// PermissionService maneja el catálogo de permisos y su asignación a roles
+type PermissionService struct{}
+
+// NewPermissionService crea una nueva instancia del servicio de permisos
+func NewPermissionService() *PermissionService {
+	return &PermissionService{}
+}
+
+// GetPermissions lista el catálogo de permisos
+func (s *PermissionService) GetPermissions() ([]dto.PermissionResponse, error) {
+	var permissions []models.Permission
+	if err := database.GetDB().Order("name").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return toPermissionResponses(permissions), nil
+}
+
+// GetRolePermissions lista los permisos asignados al rol
+func (s *PermissionService) GetRolePermissions(roleID uint) ([]dto.PermissionResponse, error) {
+	role, err := s.findRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+	return toPermissionResponses(role.Permissions), nil
+}
+
+// AddRolePermissions agrega permisos al rol sin quitar los existentes.
+// El actor solo puede agregar permisos que él mismo tiene.
+func (s *PermissionService) AddRolePermissions(actor *utils.JWTClaims, roleID uint, names []string) ([]dto.PermissionResponse, error) {
+	if len(names) == 0 {
+		return nil, utils.NewBadRequestError("Debe indicar al menos un permiso")
+	}
+	return s.changeRolePermissions(actor, roleID, names, func(assoc *gorm.Association, permissions []models.Permission) error {
+		return assoc.Append(permissions)
+	})
+}
+
+// SetRolePermissions reemplaza todos los permisos del rol.
+// Los permisos que el rol aún no tenía deben estar entre los del actor.
+func (s *PermissionService) SetRolePermissions(actor *utils.JWTClaims, roleID uint, names []string) ([]dto.PermissionResponse, error) {
+	return s.changeRolePermissions(actor, roleID, names, func(assoc *gorm.Association, permissions []models.Permission) error {
+		if len(permissions) == 0 {
+			return assoc.Clear()
+		}
+		return assoc.Replace(permissions)
+	})
+}
+
+// RemoveRolePermission quita un permiso del rol
+func (s *PermissionService) RemoveRolePermission(roleID uint, name string) ([]dto.PermissionResponse, error) {
+	// Quitar permisos no concede nada: no hace falta comparar con los del actor
+	return s.changeRolePermissions(nil, roleID, []string{name}, func(assoc *gorm.Association, permissions []models.Permission) error {
+		return assoc.Delete(permissions)
+	})
+}
+
+// changeRolePermissions resuelve los nombres, aplica el cambio y limpia el caché.
+// Con actor, los permisos que el rol no tenía deben estar entre los del actor.
+func (s *PermissionService) changeRolePermissions(actor *utils.JWTClaims, roleID uint, names []string, apply func(*gorm.Association, []models.Permission) error) ([]dto.PermissionResponse, error) {
+	role, err := s.findRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.findPermissionsByName(names)
+	if err != nil {
+		return nil, err
+	}
+
+	if actor != nil {
+		current := make(map[string]bool, len(role.Permissions))
+		for _, p := range role.Permissions {
+			current[p.Name] = true
+		}
+		added := make(map[string]bool)
+		for _, p := range permissions {
+			if !current[p.Name] {
+				added[p.Name] = true
+			}
+		}
+		missing, err := missingPermissions(actor, added)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			return nil, utils.NewForbiddenError("No puede conceder permisos que usted no tiene: " + strings.Join(missing, ", "))
+		}
+	}
+
+	if err := apply(database.GetDB().Model(role).Association("Permissions"), permissions); err != nil {
+		return nil, err
+	}
+	GetPermissionCache().Invalidate(roleID)
+
+	logger.Debug.WithFields(logrus.Fields{"role_id": roleID, "permissions": names}).Info("Permisos del rol modificados")
+
+	return s.GetRolePermissions(roleID)
+}
+
+func (s *PermissionService) findRole(roleID uint) (*models.Role, error) {
+	var role models.Role
+	if err := database.GetDB().Preload("Permissions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("name")
+	}).First(&role, roleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("role not found")
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// findPermissionsByName busca los permisos y falla si alguno no existe en el catálogo
+func (s *PermissionService) findPermissionsByName(names []string) ([]models.Permission, error) {
+	unique := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			unique[name] = true
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil
+	}
+
+	list := make([]string, 0, len(unique))
+	for name := range unique {
+		list = append(list, name)
+	}
+
+	var permissions []models.Permission
+	if err := database.GetDB().Where("name IN ?", list).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	if len(permissions) != len(list) {
+		for _, p := range permissions {
+			delete(unique, p.Name)
+		}
+		var missing []string
+		for name := range unique {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, utils.NewBadRequestError("Permisos inexistentes: " + strings.Join(missing, ", "))
+	}
+
+	return permissions, nil
+}
+
+func toPermissionResponses(permissions []models.Permission) []dto.PermissionResponse {
+	responses := make([]dto.PermissionResponse, 0, len(permissions))
+	for _, p := range permissions {
+		responses = append(responses, dto.PermissionResponse{ID: p.ID, Name: p.Name, Description: p.Description})
+	}
+	return responses
+}
+
+// permissionCacheEntry permisos de un rol con su momento de carga. chain son los roles
+// de los que se tomaron (el rol y sus ancestros), para invalidar también a los descendientes.
+type permissionCacheEntry struct {
+	permissions map[string]bool
+	chain       []uint
+	loadedAt    time.Time
+}
+
+// PermissionCache resuelve los permisos de un rol con un caché corto en memoria,
+// para no consultar la base en cada request autenticado
+type PermissionCache struct {
+	mu      sync.RWMutex
+	entries map[uint]permissionCacheEntry
+}
+
+var (
+	permissionCache     *PermissionCache
+	permissionCacheOnce sync.Once
+)
+
+// GetPermissionCache devuelve la instancia compartida del caché de permisos
+func GetPermissionCache() *PermissionCache {
+	permissionCacheOnce.Do(func() {
+		permissionCache = &PermissionCache{entries: make(map[uint]permissionCacheEntry)}
+	})
+	return permissionCache
+}
+
+// RolePermissions devuelve el conjunto de permisos efectivos del rol: los propios y los heredados
+func (c *PermissionCache) RolePermissions(roleID uint) (map[string]bool, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[roleID]
+	c.mu.RUnlock()
+	if ok && time.Since(entry.loadedAt) < permissionCacheTTL {
+		return entry.permissions, nil
+	}
+
+	db := database.GetDB()
+	chain, visited, err := activeRoleChain(db, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if len(chain) > 0 {
+		err := db.Model(&models.Permission{}).
+			Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+			Where("role_permissions.role_id IN ?", chain).
+			Distinct().
+			Pluck("permissions.name", &names).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+
+	c.mu.Lock()
+	c.entries[roleID] = permissionCacheEntry{permissions: permissions, chain: visited, loadedAt: time.Now()}
+	c.mu.Unlock()
+
+	return permissions, nil
+}
+
+// HasPermissions indica si el rol tiene todos los permisos pedidos
+func (c *PermissionCache) HasPermissions(roleID uint, required ...string) (bool, error) {
+	permissions, err := c.RolePermissions(roleID)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range required {
+		if !permissions[p] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Invalidate descarta los permisos en caché del rol y de los roles que heredan de él
+func (c *PermissionCache) Invalidate(roleID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, roleID)
+	for id, entry := range c.entries {
+		for _, ancestor := range entry.chain {
+			if ancestor == roleID {
+				delete(c.entries, id)
+				break
+			}
+		}
+	}
+}
+
+// activeRoleChain devuelve el rol y los ancestros de los que hereda permisos, y todos los roles
+// consultados para resolverlos. La herencia se corta en el primer rol inactivo o eliminado;
+// un rol inactivo no concede nada.
+func activeRoleChain(db *gorm.DB, roleID uint) (chain []uint, visited []uint, err error) {
+	seen := make(map[uint]bool)
+
+	id := roleID
+	for depth := 0; depth <= maxRoleDepth && !seen[id]; depth++ {
+		seen[id] = true
+		visited = append(visited, id)
+
+		var role models.Role
+		if err := db.Select("id", "parent_id", "is_active").First(&role, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, nil, err
+		}
+		if !role.IsActive {
+			break
+		}
+
+		chain = append(chain, role.ID)
+		if role.ParentID == nil {
+			break
+		}
+		id = *role.ParentID
+	}
+
+	return chain, visited, nil
+}
+
+// missingPermissions devuelve, ordenados, los permisos de granted que el actor no tiene.
+// Nadie puede conceder (a un rol, a otro usuario o a sí mismo) más de lo que ya tiene.
+func missingPermissions(actor *utils.JWTClaims, granted map[string]bool) ([]string, error) {
+	own, err := GetPermissionCache().RolePermissions(actor.RoleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for permission := range granted {
+		if !own[permission] {
+			missing = append(missing, permission)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// ensureRoleGrantable verifica que el actor tenga todos los permisos efectivos del rol
+func ensureRoleGrantable(actor *utils.JWTClaims, roleID uint) error {
+	granted, err := GetPermissionCache().RolePermissions(roleID)
+	if err != nil {
+		return err
+	}
+	missing, err := missingPermissions(actor, granted)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return utils.NewForbiddenError("El rol otorga permisos que usted no tiene: " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// PermissionsForClaims resuelve los permisos del usuario autenticado a partir del rol de sus claims.
+// No se embeben en el token para que un cambio de permisos aplique sin esperar a que expire.
+func PermissionsForClaims(claims *utils.JWTClaims) []string {
+	permissions, err := GetPermissionCache().RolePermissions(claims.RoleID)
+	if err != nil {
+		return []string{}
+	}
+	names := make([]string, 0, len(permissions))
+	for name := range permissions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
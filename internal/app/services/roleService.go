@@ -2,13 +2,19 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"megabaseGo/internal/app/dto"
 	"megabaseGo/internal/database"
 	"megabaseGo/internal/models"
+	"megabaseGo/internal/pagination"
+	"megabaseGo/internal/utils"
+	"sort"
 
 	"gorm.io/gorm"
 )
 
+var errSystemRole = utils.NewForbiddenError("Los roles del sistema no se pueden renombrar, desactivar ni eliminar")
+
 type RoleService struct{}
 
 // NewRoleService crea una nueva instancia del servicio de roles
@@ -39,6 +45,15 @@ func (s *RoleService) CreateRole(req *dto.CreateRoleRequest) (*dto.RoleResponse,
 		Description: req.Description,
 		IsActive:    isActive,
 	}
+	if req.RequireMFA != nil {
+		role.RequireMFA = *req.RequireMFA
+	}
+	if req.ParentID != nil && *req.ParentID != 0 {
+		if err := validateRoleParent(db, 0, *req.ParentID); err != nil {
+			return nil, err
+		}
+		role.ParentID = req.ParentID
+	}
 
 	// Guardar en BD
 	if err := db.Create(&role).Error; err != nil {
@@ -48,26 +63,40 @@ func (s *RoleService) CreateRole(req *dto.CreateRoleRequest) (*dto.RoleResponse,
 	return s.toRoleResponse(&role), nil
 }
 
-// GetRoles obtiene todos los roles con filtros opcionales
-func (s *RoleService) GetRoles(includeInactive bool) ([]dto.RoleResponse, error) {
+// RoleSorting campos por los que se puede ordenar el listado de roles (?sort=)
+var RoleSorting = pagination.Sortable{
+	Fields: map[string]string{
+		"id":           "id",
+		"name":         "name",
+		"display_name": "display_name",
+		"is_active":    "is_active",
+		"created_at":   "created_at",
+		"updated_at":   "updated_at",
+	},
+	Default: "id",
+}
+
+// GetRoles obtiene los roles con filtros opcionales, paginados y ordenados
+func (s *RoleService) GetRoles(includeInactive bool, page *pagination.Params) ([]dto.RoleResponse, *pagination.Meta, error) {
 	db := database.GetDB()
 	var roles []models.Role
 
-	query := db
+	query := db.Model(&models.Role{})
 	if !includeInactive {
 		query = query.Where("is_active = ?", true)
 	}
 
-	if err := query.Find(&roles).Error; err != nil {
-		return nil, err
+	meta, err := pagination.Find(query, page, &roles, "Permissions")
+	if err != nil {
+		return nil, nil, err
 	}
 
-	var responses []dto.RoleResponse
+	responses := make([]dto.RoleResponse, 0, len(roles))
 	for _, role := range roles {
 		responses = append(responses, *s.toRoleResponse(&role))
 	}
 
-	return responses, nil
+	return responses, meta, nil
 }
 
 // GetRoleByID obtiene un rol por ID
@@ -75,7 +104,7 @@ func (s *RoleService) GetRoleByID(id uint) (*dto.RoleResponse, error) {
 	db := database.GetDB()
 	var role models.Role
 
-	if err := db.First(&role, id).Error; err != nil {
+	if err := db.Preload("Permissions").First(&role, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("role not found")
 		}
@@ -98,6 +127,13 @@ func (s *RoleService) UpdateRole(id uint, req *dto.UpdateRoleRequest) (*dto.Role
 		return nil, err
 	}
 
+	// Los roles sembrados no cambian de nombre ni se desactivan: el código y la configuración los referencian
+	if role.IsSystem {
+		if (req.Name != "" && req.Name != role.Name) || (req.IsActive != nil && !*req.IsActive) {
+			return nil, errSystemRole
+		}
+	}
+
 	// Verificar nombre único si se está cambiando
 	if req.Name != "" && req.Name != role.Name {
 		var existing models.Role
@@ -119,11 +155,30 @@ func (s *RoleService) UpdateRole(id uint, req *dto.UpdateRoleRequest) (*dto.Role
 	if req.IsActive != nil {
 		role.IsActive = *req.IsActive
 	}
+	if req.RequireMFA != nil {
+		role.RequireMFA = *req.RequireMFA
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			role.ParentID = nil
+		} else {
+			if err := validateRoleParent(db, role.ID, *req.ParentID); err != nil {
+				return nil, err
+			}
+			role.ParentID = req.ParentID
+		}
+	}
 
 	// Guardar cambios
 	if err := db.Save(&role).Error; err != nil {
 		return nil, err
 	}
+	// Un rol desactivado o con otro padre cambia los permisos propios y los de sus descendientes
+	GetPermissionCache().Invalidate(role.ID)
+
+	if err := db.Preload("Permissions").First(&role, role.ID).Error; err != nil {
+		return nil, err
+	}
 
 	return s.toRoleResponse(&role), nil
 }
@@ -141,6 +196,10 @@ func (s *RoleService) DeleteRole(id uint) error {
 		return err
 	}
 
+	if role.IsSystem {
+		return errSystemRole
+	}
+
 	// Verificar que no hay usuarios usando este rol
 	var userCount int64
 	if err := db.Model(&models.User{}).Where("role_id = ?", id).Count(&userCount).Error; err != nil {
@@ -151,19 +210,118 @@ func (s *RoleService) DeleteRole(id uint) error {
 		return errors.New("cannot delete role: it is assigned to users")
 	}
 
+	// Ni roles que hereden de él
+	var childCount int64
+	if err := db.Model(&models.Role{}).Where("parent_id = ?", id).Count(&childCount).Error; err != nil {
+		return err
+	}
+	if childCount > 0 {
+		return errors.New("cannot delete role: other roles inherit from it")
+	}
+
 	// Soft delete
-	return db.Delete(&role).Error
+	if err := db.Delete(&role).Error; err != nil {
+		return err
+	}
+	GetPermissionCache().Invalidate(role.ID)
+	return nil
 }
 
 // toRoleResponse convierte un modelo Role a RoleResponse
 func (s *RoleService) toRoleResponse(role *models.Role) *dto.RoleResponse {
+	permissions := make([]string, 0, len(role.Permissions))
+	direct := make(map[string]bool, len(role.Permissions))
+	for _, p := range role.Permissions {
+		permissions = append(permissions, p.Name)
+		direct[p.Name] = true
+	}
+
+	// Heredados: los efectivos que no están asignados directamente
+	inherited := []string{}
+	if role.ParentID != nil {
+		if effective, err := GetPermissionCache().RolePermissions(role.ID); err == nil {
+			for name := range effective {
+				if !direct[name] {
+					inherited = append(inherited, name)
+				}
+			}
+			sort.Strings(inherited)
+		}
+	}
+
 	return &dto.RoleResponse{
 		ID:          role.ID,
 		Name:        role.Name,
 		DisplayName: role.DisplayName,
 		Description: role.Description,
 		IsActive:    role.IsActive,
-		CreatedAt:   role.CreatedAt,
-		UpdatedAt:   role.UpdatedAt,
+		RequireMFA:  role.RequireMFA,
+		IsSystem:    role.IsSystem,
+		ParentID:    role.ParentID,
+		Permissions: permissions,
+
+		InheritedPermissions: inherited,
+		CreatedAt:            role.CreatedAt,
+		UpdatedAt:            role.UpdatedAt,
+	}
+}
+
+// validateRoleParent verifica que el padre exista y que asignarlo a roleID (0 si el rol es nuevo)
+// no forme un ciclo ni supere maxRoleDepth niveles
+func validateRoleParent(db *gorm.DB, roleID, parentID uint) error {
+	if parentID == roleID {
+		return utils.NewBadRequestError("Un rol no puede heredar de sí mismo")
+	}
+
+	id := parentID
+	for depth := 1; ; depth++ {
+		if depth >= maxRoleDepth {
+			return utils.NewBadRequestError(fmt.Sprintf("La jerarquía de roles no puede superar %d niveles", maxRoleDepth))
+		}
+
+		var ancestor models.Role
+		if err := db.Select("id", "parent_id").First(&ancestor, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.NewBadRequestError("Rol padre inexistente")
+			}
+			return err
+		}
+		if ancestor.ParentID == nil {
+			return nil
+		}
+		if *ancestor.ParentID == roleID && roleID != 0 {
+			return utils.NewBadRequestError("El rol padre hereda de este rol: se formaría un ciclo")
+		}
+		id = *ancestor.ParentID
 	}
-}
\ No newline at end of file
+}
+
+// ensureNotLastActiveAdmin impide dejar el sistema sin ningún usuario administrador activo.
+// Se llama antes de eliminar, desactivar o cambiar de rol a un usuario.
+func ensureNotLastActiveAdmin(db *gorm.DB, user *models.User) error {
+	if !user.IsActive {
+		return nil
+	}
+
+	var adminRole models.Role
+	if err := db.Where("name = ?", models.RoleAdmin).First(&adminRole).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.RoleID != adminRole.ID {
+		return nil
+	}
+
+	var others int64
+	if err := db.Model(&models.User{}).
+		Where("role_id = ? AND is_active = ? AND id != ?", adminRole.ID, true, user.ID).
+		Count(&others).Error; err != nil {
+		return err
+	}
+	if others == 0 {
+		return utils.NewConflictError("No se puede eliminar, desactivar ni quitar el rol al último administrador activo")
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// SessionService persiste las sesiones de refresh token (implementa utils.RefreshTokenStore)
+type SessionService struct{}
+
+// NewSessionService crea una nueva instancia del servicio de sesiones
+func NewSessionService() *SessionService {
+	return &SessionService{}
+}
+
+// CreateSession guarda una nueva sesión
+func (s *SessionService) CreateSession(session *models.Session) error {
+	session.UserAgent = truncateRunes(session.UserAgent, 255)
+	return database.GetDB().Create(session).Error
+}
+
+// truncateRunes recorta s a max caracteres sin partir un carácter multibyte;
+// las columnas varchar de PostgreSQL miden caracteres y rechazan UTF-8 inválido
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	for i := range s {
+		if max == 0 {
+			return s[:i]
+		}
+		max--
+	}
+	return s
+}
+
+// FindSession obtiene una sesión por su familia
+func (s *SessionService) FindSession(familyID string) (*models.Session, error) {
+	db := database.GetDB()
+	var session models.Session
+
+	if err := db.Where("family_id = ?", familyID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("session not found")
+		}
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// RotateSession reemplaza el jti vigente de forma atómica
+func (s *SessionService) RotateSession(familyID, currentJTI, newJTI string, expiresAt time.Time) (bool, error) {
+	db := database.GetDB()
+
+	result := db.Model(&models.Session{}).
+		Where("family_id = ? AND current_jti = ? AND revoked_at IS NULL", familyID, currentJTI).
+		Updates(map[string]interface{}{
+			"current_jti":  newJTI,
+			"expires_at":   expiresAt,
+			"last_used_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeSession revoca una sesión: todos sus refresh tokens y los access tokens que emitió.
+// Lo usan el logout, el cierre de una sesión y la detección de reutilización de refresh tokens.
+func (s *SessionService) RevokeSession(familyID, reason string) error {
+	db := database.GetDB()
+
+	var session models.Session
+	if err := db.Select("id", "user_id").Where("family_id = ? AND revoked_at IS NULL", familyID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	result := db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", session.ID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Otro request la revocó en paralelo y ya revocó sus access tokens
+		return nil
+	}
+
+	entry := logger.Debug.WithFields(logrus.Fields{"family_id": familyID, "reason": reason})
+	if reason == "reuse" {
+		entry.Warn("Reutilización de refresh token detectada, sesión revocada")
+	} else {
+		entry.Info("Sesión revocada")
+	}
+	return GetTokenDenylist().RevokeSessionTokens(session.UserID, familyID, reason)
+}
+
+// RevokeUserSessions revoca todas las sesiones activas de un usuario
+func (s *SessionService) RevokeUserSessions(userID uint, reason string) error {
+	db := database.GetDB()
+
+	result := db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		logger.Debug.WithFields(logrus.Fields{"user_id": userID, "reason": reason, "sessions": result.RowsAffected}).Info("Sesiones del usuario revocadas")
+	}
+	return nil
+}
+
+// GetUserSessions lista las sesiones activas del usuario, la más reciente primero.
+// currentSessionID marca la sesión desde la que se hace la petición.
+func (s *SessionService) GetUserSessions(userID uint, currentSessionID string) ([]dto.SessionResponse, error) {
+	var sessions []models.Session
+	if err := database.GetDB().
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responses = append(responses, dto.SessionResponse{
+			ID:          session.ID,
+			IPAddress:   session.IPAddress,
+			UserAgent:   session.UserAgent,
+			AuthMethods: splitAuthMethods(session.AuthMethods),
+			CreatedAt:   session.CreatedAt,
+			LastUsedAt:  session.LastUsedAt,
+			ExpiresAt:   session.ExpiresAt,
+			Current:     currentSessionID != "" && session.FamilyID == currentSessionID,
+		})
+	}
+	return responses, nil
+}
+
+// RevokeUserSession cierra una sesión del usuario: su refresh token y los access tokens que emitió
+func (s *SessionService) RevokeUserSession(userID, sessionID uint, reason string) error {
+	var session models.Session
+	if err := database.GetDB().Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewNotFoundError("Session")
+		}
+		return err
+	}
+	if !session.IsActive() {
+		return nil
+	}
+
+	return s.RevokeSession(session.FamilyID, reason)
+}
+
+// SignOutEverywhere cierra todas las sesiones del usuario y revoca sus access tokens
+func (s *SessionService) SignOutEverywhere(userID uint, reason string) error {
+	return revokeUserAccess(userID, reason, true)
+}
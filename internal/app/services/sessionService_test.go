@@ -0,0 +1,130 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/models"
+)
+
+func TestRevokeSessionRevokesIssuedAccessTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(t *testing.T, service *AuthService, first, second *dto.AuthResponse)
+	}{
+		{
+			// Presentar otra vez un refresh token ya rotado compromete a toda la familia
+			name: "refresh token reuse",
+			revoke: func(t *testing.T, service *AuthService, first, second *dto.AuthResponse) {
+				if _, err := service.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: first.RefreshToken}); err == nil {
+					t.Fatal("expected the reused refresh token to be rejected")
+				}
+			},
+		},
+		{
+			name: "logout with refresh and access token",
+			revoke: func(t *testing.T, service *AuthService, first, second *dto.AuthResponse) {
+				if err := service.Logout(second.RefreshToken, second.AccessToken); err != nil {
+					t.Fatalf("Logout: %v", err)
+				}
+			},
+		},
+		{
+			name: "logout with the access token only",
+			revoke: func(t *testing.T, service *AuthService, first, second *dto.AuthResponse) {
+				if err := service.Logout("", second.AccessToken); err != nil {
+					t.Fatalf("Logout: %v", err)
+				}
+			},
+		},
+		{
+			name: "sign out of the session",
+			revoke: func(t *testing.T, service *AuthService, first, second *dto.AuthResponse) {
+				claims, err := service.jwtManager.ValidateToken(second.AccessToken)
+				if err != nil {
+					t.Fatalf("ValidateToken: %v", err)
+				}
+				session, err := service.sessionService.FindSession(claims.SessionID)
+				if err != nil {
+					t.Fatalf("FindSession: %v", err)
+				}
+				if err := service.sessionService.RevokeUserSession(claims.UserID, session.ID, "user_signout"); err != nil {
+					t.Fatalf("RevokeUserSession: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			loadTestConfig(t, nil)
+			user := createTestUser(t, db, "sessions", "Secret123!", models.RoleUser)
+			service := NewAuthService()
+
+			first, err := service.issueTokens(user, dto.ClientInfo{}, []string{authMethodPassword})
+			if err != nil {
+				t.Fatalf("issueTokens: %v", err)
+			}
+			second, err := service.RefreshToken(&dto.RefreshTokenRequest{RefreshToken: first.RefreshToken})
+			if err != nil {
+				t.Fatalf("RefreshToken: %v", err)
+			}
+			// Otra sesión del mismo usuario no se ve afectada
+			other, err := service.issueTokens(user, dto.ClientInfo{}, []string{authMethodPassword})
+			if err != nil {
+				t.Fatalf("issueTokens: %v", err)
+			}
+
+			tt.revoke(t, service, first, second)
+
+			for name, token := range map[string]string{"first access token": first.AccessToken, "second access token": second.AccessToken} {
+				if !accessTokenRevoked(t, service, token) {
+					t.Errorf("%s still valid after revoking its session", name)
+				}
+			}
+			if accessTokenRevoked(t, service, other.AccessToken) {
+				t.Error("access token of another session was revoked")
+			}
+		})
+	}
+}
+
+// accessTokenRevoked indica si el access token está en la lista de revocación
+func accessTokenRevoked(t *testing.T, service *AuthService, token string) bool {
+	t.Helper()
+	claims, err := service.jwtManager.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	return GetTokenDenylist().IsRevoked(claims)
+}
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "short ascii", input: "curl/8.0", want: "curl/8.0"},
+		{name: "long ascii", input: strings.Repeat("a", 300), want: strings.Repeat("a", 255)},
+		// 254 bytes ASCII + "ñ" de dos bytes: cortar en el byte 255 partiría la ñ
+		{name: "multibyte at the byte limit", input: strings.Repeat("a", 254) + "ñandú", want: strings.Repeat("a", 254) + "ñ"},
+		{name: "all multibyte", input: strings.Repeat("日", 300), want: strings.Repeat("日", 255)},
+		{name: "exactly the limit", input: strings.Repeat("é", 255), want: strings.Repeat("é", 255)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateRunes(tt.input, 255)
+			if got != tt.want {
+				t.Fatalf("truncateRunes = %q (%d runes), want %q", got, utf8.RuneCountInString(got), tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Fatalf("truncateRunes returned invalid UTF-8: %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"megabaseGo/internal/database"
+	"megabaseGo/internal/logger"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// denylistSyncInterval cada cuánto se recarga la lista desde la BD para ver
+// revocaciones hechas por otras instancias y purgar entradas expiradas
+const denylistSyncInterval = 30 * time.Second
+
+// TokenDenylist lista de revocación de access tokens.
+// Se mantiene en memoria para no consultar la BD en cada request y se respalda en la tabla revoked_tokens.
+type TokenDenylist struct {
+	mu       sync.RWMutex
+	tokens   map[string]time.Time // jti -> expiración del token
+	sessions map[string]time.Time // familia de sesión -> expiración de la entrada
+	users    map[uint]time.Time   // userID -> tokens emitidos antes de esta fecha están revocados
+	lastSync time.Time
+}
+
+var (
+	tokenDenylist     *TokenDenylist
+	tokenDenylistOnce sync.Once
+)
+
+// GetTokenDenylist devuelve la instancia compartida de la lista de revocación
+func GetTokenDenylist() *TokenDenylist {
+	tokenDenylistOnce.Do(func() {
+		tokenDenylist = &TokenDenylist{
+			tokens:   make(map[string]time.Time),
+			sessions: make(map[string]time.Time),
+			users:    make(map[uint]time.Time),
+		}
+	})
+	return tokenDenylist
+}
+
+// IsRevoked indica si el access token fue revocado individualmente, con su sesión o por revocación de su usuario
+func (d *TokenDenylist) IsRevoked(claims *utils.JWTClaims) bool {
+	d.syncIfStale()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if claims.ID != "" {
+		if _, ok := d.tokens[claims.ID]; ok {
+			return true
+		}
+	}
+
+	if claims.SessionID != "" {
+		if _, ok := d.sessions[claims.SessionID]; ok {
+			return true
+		}
+	}
+
+	if cutoff, ok := d.users[claims.UserID]; ok {
+		// Tokens sin iat no se pueden ubicar en el tiempo, se tratan como revocados
+		issuedAt, ok := claims.IssuedAtMillis()
+		if !ok || issuedAt < cutoff.UnixMilli() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RevokeToken revoca un access token concreto hasta su expiración
+func (d *TokenDenylist) RevokeToken(claims *utils.JWTClaims, reason string) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	entry := models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		Reason:    reason,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if err := database.GetDB().Create(&entry).Error; err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.tokens[entry.JTI] = entry.ExpiresAt
+	d.mu.Unlock()
+
+	return nil
+}
+
+// RevokeSessionTokens revoca los access tokens emitidos por una sesión de refresh
+func (d *TokenDenylist) RevokeSessionTokens(userID uint, familyID, reason string) error {
+	entry := models.RevokedToken{
+		SessionID: familyID,
+		UserID:    userID,
+		Reason:    reason,
+		// La sesión ya no emite tokens: basta con cubrir la vigencia de los que emitió
+		ExpiresAt: time.Now().Add(utils.AccessTokenDuration()),
+	}
+	if err := database.GetDB().Create(&entry).Error; err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.sessions[familyID] = entry.ExpiresAt
+	d.mu.Unlock()
+
+	return nil
+}
+
+// RevokeUserTokens revoca todos los access tokens emitidos hasta ahora para el usuario
+func (d *TokenDenylist) RevokeUserTokens(userID uint, reason string) error {
+	// El corte se redondea al milisegundo siguiente, la precisión de iat_ms: todo token emitido
+	// hasta ahora queda antes del corte, y un re-login en el mismo segundo (p. ej. después de
+	// cambiar la contraseña) queda después y sigue siendo válido
+	cutoff := time.Now().Truncate(time.Millisecond).Add(time.Millisecond)
+	entry := models.RevokedToken{
+		UserID:       userID,
+		IssuedBefore: &cutoff,
+		Reason:       reason,
+		// Pasada la vigencia máxima de un access token ya no queda ninguno anterior al corte
+		ExpiresAt: cutoff.Add(utils.AccessTokenDuration()),
+	}
+	if err := database.GetDB().Create(&entry).Error; err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	if current, ok := d.users[userID]; !ok || cutoff.After(current) {
+		d.users[userID] = cutoff
+	}
+	d.mu.Unlock()
+
+	logger.Debug.WithFields(logrus.Fields{"user_id": userID, "reason": reason}).Info("Tokens del usuario revocados")
+	return nil
+}
+
+// syncIfStale recarga la lista desde la BD si pasó el intervalo de sincronización
+func (d *TokenDenylist) syncIfStale() {
+	d.mu.RLock()
+	stale := time.Since(d.lastSync) > denylistSyncInterval
+	d.mu.RUnlock()
+
+	if stale {
+		d.sync()
+	}
+}
+
+// sync purga las entradas expiradas y reconstruye la lista en memoria desde la BD
+func (d *TokenDenylist) sync() {
+	db := database.GetDB()
+	now := time.Now()
+
+	if err := db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		logger.Debug.WithError(err).Error("Error purgando tokens revocados expirados")
+	}
+
+	var entries []models.RevokedToken
+	if err := db.Where("expires_at >= ?", now).Find(&entries).Error; err != nil {
+		// Se conserva la lista actual; se reintentará en el próximo intervalo
+		logger.Debug.WithError(err).Error("Error cargando la lista de tokens revocados")
+		d.mu.Lock()
+		d.lastSync = now
+		d.mu.Unlock()
+		return
+	}
+
+	tokens := make(map[string]time.Time)
+	sessions := make(map[string]time.Time)
+	users := make(map[uint]time.Time)
+	for _, entry := range entries {
+		if entry.JTI != "" {
+			tokens[entry.JTI] = entry.ExpiresAt
+			continue
+		}
+		if entry.SessionID != "" {
+			sessions[entry.SessionID] = entry.ExpiresAt
+			continue
+		}
+		if entry.IssuedBefore != nil {
+			if current, ok := users[entry.UserID]; !ok || entry.IssuedBefore.After(current) {
+				users[entry.UserID] = *entry.IssuedBefore
+			}
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Las revocaciones nunca se deshacen, así que se conservan las hechas en memoria
+	// mientras se leía la BD
+	for jti, expiresAt := range d.tokens {
+		if expiresAt.After(now) {
+			tokens[jti] = expiresAt
+		}
+	}
+	for familyID, expiresAt := range d.sessions {
+		if expiresAt.After(now) {
+			sessions[familyID] = expiresAt
+		}
+	}
+	oldestValidIssue := now.Add(-utils.AccessTokenDuration())
+	for userID, cutoff := range d.users {
+		if cutoff.After(oldestValidIssue) && cutoff.After(users[userID]) {
+			users[userID] = cutoff
+		}
+	}
+
+	d.tokens = tokens
+	d.sessions = sessions
+	d.users = users
+	d.lastSync = now
+}
+
+// revokeUserAccess revoca los access tokens del usuario y, si se indica, también sus sesiones de refresh
+func revokeUserAccess(userID uint, reason string, includeSessions bool) error {
+	if err := GetTokenDenylist().RevokeUserTokens(userID, reason); err != nil {
+		return err
+	}
+	if includeSessions {
+		return NewSessionService().RevokeUserSessions(userID, reason)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRevokeUserTokensCutoff(t *testing.T) {
+	db := setupTestDB(t)
+	loadTestConfig(t, nil)
+	user := createTestUser(t, db, "revoked", "Secret123!", models.RoleUser)
+	manager := utils.NewJWTManager(NewSessionService())
+
+	issue := func() *utils.JWTClaims {
+		token, err := manager.GenerateToken(utils.JWTClaims{UserID: user.ID, UserName: user.UserName})
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		claims, err := manager.ValidateToken(token)
+		if err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		return claims
+	}
+
+	// Instancia propia para no depender de revocaciones de otras pruebas
+	denylist := &TokenDenylist{
+		tokens:   make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		users:    make(map[uint]time.Time),
+		lastSync: time.Now(),
+	}
+
+	before := issue()
+	if err := denylist.RevokeUserTokens(user.ID, "password_changed"); err != nil {
+		t.Fatalf("RevokeUserTokens: %v", err)
+	}
+	cutoff := denylist.users[user.ID]
+	time.Sleep(2 * time.Millisecond)
+	after := issue()
+
+	cutoffSecond := time.Unix(cutoff.Unix(), 0)
+	tests := []struct {
+		name    string
+		claims  *utils.JWTClaims
+		revoked bool
+	}{
+		{name: "token issued before the revocation", claims: before, revoked: true},
+		{name: "token issued after the revocation", claims: after, revoked: false},
+		// Mismo segundo que el corte: solo decide iat_ms
+		{name: "same second, one millisecond before", claims: &utils.JWTClaims{UserID: user.ID, IssuedAtMs: cutoff.UnixMilli() - 1, RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(cutoffSecond)}}, revoked: true},
+		{name: "same second, at the cutoff", claims: &utils.JWTClaims{UserID: user.ID, IssuedAtMs: cutoff.UnixMilli(), RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(cutoffSecond)}}, revoked: false},
+		// Sin iat_ms el token se ubica al inicio de su segundo: ante la duda queda revocado
+		{name: "without iat_ms in the cutoff second", claims: &utils.JWTClaims{UserID: user.ID, RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(cutoffSecond)}}, revoked: true},
+		{name: "without iat_ms in the next second", claims: &utils.JWTClaims{UserID: user.ID, RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(cutoffSecond.Add(time.Second))}}, revoked: false},
+		{name: "without any issue time", claims: &utils.JWTClaims{UserID: user.ID}, revoked: true},
+		{name: "another user", claims: &utils.JWTClaims{UserID: user.ID + 1, IssuedAtMs: cutoff.UnixMilli() - 1}, revoked: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := denylist.IsRevoked(tt.claims); got != tt.revoked {
+				t.Fatalf("IsRevoked = %v, want %v", got, tt.revoked)
+			}
+		})
+	}
+}
@@ -4,22 +4,30 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"time"
+
 	"megabaseGo/internal/app/dto"
 	"megabaseGo/internal/database"
+	"megabaseGo/internal/filtering"
+	"megabaseGo/internal/logger"
 	"megabaseGo/internal/models"
+	"megabaseGo/internal/pagination"
 	"megabaseGo/internal/utils"
 
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 type UserService struct {
 	hasher utils.PasswordHasher
+	policy *PasswordPolicy
 }
 
 // NewUserService crea una nueva instancia del servicio de usuarios
 func NewUserService() *UserService {
 	return &UserService{
-		hasher: utils.NewBcryptHasher(),
+		hasher: utils.NewPasswordHasher(),
+		policy: NewPasswordPolicy(),
 	}
 }
 
@@ -31,8 +39,10 @@ func generateSecureToken(length int) (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-// CreateUser crea un nuevo usuario
-func (s *UserService) CreateUser(req *dto.CreateUserRequest) (*dto.UserResponse, error) {
+// CreateUser crea un nuevo usuario. actor es quien lo crea desde la API: no puede asignar un rol
+// con permisos que él no tiene. Es nil en los flujos sin actor (registro, invitación aceptada),
+// donde el rol ya lo fijó la configuración o quien invitó.
+func (s *UserService) CreateUser(actor *utils.JWTClaims, req *dto.CreateUserRequest) (*dto.UserResponse, error) {
 	db := database.GetDB()
 
 	// Verificar que el rol existe
@@ -43,6 +53,18 @@ func (s *UserService) CreateUser(req *dto.CreateUserRequest) (*dto.UserResponse,
 		}
 		return nil, err
 	}
+	if actor != nil {
+		if err := ensureRoleGrantable(actor, role.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Verificar que la compañía existe
+	if req.CompanyID != nil {
+		if err := validateCompanyExists(db, *req.CompanyID); err != nil {
+			return nil, err
+		}
+	}
 
 	// Verificar username único
 	var existingUser models.User
@@ -55,6 +77,11 @@ func (s *UserService) CreateUser(req *dto.CreateUserRequest) (*dto.UserResponse,
 		return nil, errors.New("email already exists")
 	}
 
+	// Política de contraseñas
+	if err := s.policy.Validate(req.Password, req.UserName, req.Email); err != nil {
+		return nil, err
+	}
+
 	// Hash de la contraseña
 	hashedPassword, err := s.hasher.HashPassword(req.Password)
 	if err != nil {
@@ -80,6 +107,7 @@ func (s *UserService) CreateUser(req *dto.CreateUserRequest) (*dto.UserResponse,
 		Email:    req.Email,
 		Password: hashedPassword,
 		RoleID:   req.RoleID,
+		CompanyID: req.CompanyID,
 		IsActive: isActive,
 		RememberToken: rememberToken,
 	}
@@ -88,21 +116,62 @@ func (s *UserService) CreateUser(req *dto.CreateUserRequest) (*dto.UserResponse,
 	if err := db.Create(&user).Error; err != nil {
 		return nil, err
 	}
+	if err := s.policy.Remember(db, user.ID, user.Password); err != nil {
+		return nil, err
+	}
 
 	// Cargar relación y devolver
 	if err := db.Preload("Role").First(&user, user.ID).Error; err != nil {
 		return nil, err
 	}
 
+	// El alta no falla si no se pudo emitir la verificación; puede reenviarse después
+	if err := NewAccountService().SendVerificationEmail(&user); err != nil {
+		logger.Debug.WithFields(logrus.Fields{"user_id": user.ID}).WithError(err).Error("No se pudo enviar la verificación de email")
+	}
+
 	return s.toUserResponse(&user), nil
 }
 
-// GetUsers obtiene todos los usuarios con filtros opcionales
-func (s *UserService) GetUsers(includeInactive bool, roleID *uint) ([]dto.UserResponse, error) {
+// UserSorting campos por los que se puede ordenar el listado de usuarios (?sort=)
+var UserSorting = pagination.Sortable{
+	Fields: map[string]string{
+		"id":            "id",
+		"name":          "name",
+		"user_name":     "user_name",
+		"email":         "email",
+		"role_id":       "role_id",
+		"is_active":     "is_active",
+		"last_login_at": "last_login_at",
+		"created_at":    "created_at",
+		"updated_at":    "updated_at",
+	},
+	Default: "id",
+}
+
+// UserFilterFields campos admitidos en filter[campo][operador]= del listado de usuarios
+var UserFilterFields = filtering.Fields{
+	"id":                {Column: "id", Type: filtering.Number},
+	"name":              {Column: "name", Type: filtering.String},
+	"user_name":         {Column: "user_name", Type: filtering.String},
+	"email":             {Column: "email", Type: filtering.String},
+	"role_id":           {Column: "role_id", Type: filtering.Number},
+	"company_id":        {Column: "company_id", Type: filtering.Number},
+	"is_active":         {Column: "is_active", Type: filtering.Bool},
+	"totp_enabled":      {Column: "totp_enabled", Type: filtering.Bool},
+	"email_verified_at": {Column: "email_verified_at", Type: filtering.Time},
+	"last_login_at":     {Column: "last_login_at", Type: filtering.Time},
+	"locked_until":      {Column: "locked_until", Type: filtering.Time},
+	"created_at":        {Column: "created_at", Type: filtering.Time},
+	"updated_at":        {Column: "updated_at", Type: filtering.Time},
+}
+
+// GetUsers obtiene los usuarios con filtros opcionales, paginados y ordenados
+func (s *UserService) GetUsers(includeInactive bool, roleID *uint, conditions filtering.Conditions, page *pagination.Params) ([]dto.UserResponse, *pagination.Meta, error) {
 	db := database.GetDB()
 	var users []models.User
 
-	query := db.Preload("Role")
+	query := db.Model(&models.User{})
 
 	if !includeInactive {
 		query = query.Where("is_active = ?", true)
@@ -112,16 +181,17 @@ func (s *UserService) GetUsers(includeInactive bool, roleID *uint) ([]dto.UserRe
 		query = query.Where("role_id = ?", *roleID)
 	}
 
-	if err := query.Find(&users).Error; err != nil {
-		return nil, err
+	meta, err := pagination.Find(conditions.Apply(query), page, &users, "Role")
+	if err != nil {
+		return nil, nil, err
 	}
 
-	var responses []dto.UserResponse
+	responses := make([]dto.UserResponse, 0, len(users))
 	for _, user := range users {
 		responses = append(responses, *s.toUserResponse(&user))
 	}
 
-	return responses, nil
+	return responses, meta, nil
 }
 
 // GetUserByID obtiene un usuario por ID
@@ -139,8 +209,9 @@ func (s *UserService) GetUserByID(id uint) (*dto.UserResponse, error) {
 	return s.toUserResponse(&user), nil
 }
 
-// UpdateUser actualiza un usuario existente
-func (s *UserService) UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.UserResponse, error) {
+// UpdateUser actualiza un usuario existente. El actor no puede asignar un rol con permisos que
+// él no tiene (tampoco a sí mismo) ni modificar a un usuario cuyo rol los tenga.
+func (s *UserService) UpdateUser(actor *utils.JWTClaims, id uint, req *dto.UpdateUserRequest) (*dto.UserResponse, error) {
 	db := database.GetDB()
 	var user models.User
 
@@ -152,6 +223,11 @@ func (s *UserService) UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.User
 		return nil, err
 	}
 
+	// Cambiar la contraseña o el email de una cuenta con más permisos equivale a tomarla
+	if err := ensureRoleGrantable(actor, user.RoleID); err != nil {
+		return nil, err
+	}
+
 	// Verificar rol si se está cambiando
 	if req.RoleID != 0 && req.RoleID != user.RoleID {
 		var role models.Role
@@ -161,6 +237,16 @@ func (s *UserService) UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.User
 			}
 			return nil, err
 		}
+		if err := ensureRoleGrantable(actor, role.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Verificar compañía si se está asignando
+	if req.CompanyID != nil && *req.CompanyID != 0 {
+		if err := validateCompanyExists(db, *req.CompanyID); err != nil {
+			return nil, err
+		}
 	}
 
 	// Verificar username único si se está cambiando
@@ -179,6 +265,18 @@ func (s *UserService) UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.User
 		}
 	}
 
+	// Cambios que invalidan los tokens ya emitidos para el usuario
+	statusChanged := req.IsActive != nil && *req.IsActive != user.IsActive
+	roleChanged := req.RoleID != 0 && req.RoleID != user.RoleID
+	passwordChanged := req.Password != ""
+
+	// No dejar el sistema sin administradores activos
+	if (statusChanged && !*req.IsActive) || roleChanged {
+		if err := ensureNotLastActiveAdmin(db, &user); err != nil {
+			return nil, err
+		}
+	}
+
 	// Actualizar campos
 	if req.Name != "" {
 		user.Name = req.Name
@@ -195,9 +293,23 @@ func (s *UserService) UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.User
 	if req.IsActive != nil {
 		user.IsActive = *req.IsActive
 	}
+	if req.CompanyID != nil {
+		if *req.CompanyID == 0 {
+			user.CompanyID = nil
+		} else {
+			user.CompanyID = req.CompanyID
+		}
+	}
 
 	// Hash nueva contraseña si se proporciona
 	if req.Password != "" {
+		if err := s.policy.Validate(req.Password, user.UserName, user.Email); err != nil {
+			return nil, err
+		}
+		if err := s.policy.CheckHistory(db, &user, req.Password); err != nil {
+			return nil, err
+		}
+
 		hashedPassword, err := s.hasher.HashPassword(req.Password)
 		if err != nil {
 			return nil, errors.New("failed to hash password")
@@ -210,6 +322,19 @@ func (s *UserService) UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.User
 		return nil, err
 	}
 
+	if passwordChanged {
+		if err := s.policy.Remember(db, user.ID, user.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	// Revocar tokens emitidos con el estado, rol o contraseña anteriores
+	if statusChanged || roleChanged || passwordChanged {
+		if err := revokeUserAccess(user.ID, "user_updated", passwordChanged); err != nil {
+			return nil, err
+		}
+	}
+
 	// Cargar relación actualizada
 	if err := db.Preload("Role").First(&user, user.ID).Error; err != nil {
 		return nil, err
@@ -231,8 +356,48 @@ func (s *UserService) DeleteUser(id uint) error {
 		return err
 	}
 
+	if err := ensureNotLastActiveAdmin(db, &user); err != nil {
+		return err
+	}
+
 	// Soft delete
-	return db.Delete(&user).Error
+	if err := db.Delete(&user).Error; err != nil {
+		return err
+	}
+
+	// Invalidar tokens, sesiones y API keys del usuario eliminado
+	if err := revokeUserAccess(user.ID, "user_deleted", true); err != nil {
+		return err
+	}
+	return NewAPIKeyService().RevokeUserAPIKeys(user.ID)
+}
+
+// UnlockUser quita el bloqueo por intentos de login fallidos
+func (s *UserService) UnlockUser(id uint) (*dto.UserResponse, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.Preload("Role").First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}).Error; err != nil {
+		return nil, err
+	}
+	user.FailedLoginAttempts = 0
+	user.LockedUntil = nil
+
+	// También el contador en memoria, si no el backoff seguiría vigente
+	GetLoginThrottle().Reset(usernameThrottleKey(user.UserName))
+
+	logger.Debug.WithFields(logrus.Fields{"user_id": user.ID, "user_name": user.UserName}).Info("Cuenta desbloqueada manualmente")
+	return s.toUserResponse(&user), nil
 }
 
 // CheckUsernameAvailability verifica si un username está disponible
@@ -274,15 +439,33 @@ func (s *UserService) CheckEmailAvailability(email string) (bool, error) {
 // toUserResponse convierte un modelo User a UserResponse
 func (s *UserService) toUserResponse(user *models.User) *dto.UserResponse {
 	return &dto.UserResponse{
-		ID:          user.ID,
-		Name:        user.Name,
-		UserName:    user.UserName,
-		Email:       user.Email,
-		RoleID:      user.RoleID,
-		Role:        user.Role,
-		IsActive:    user.IsActive,
-		LastLoginAt: user.LastLoginAt,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-	}
-}
\ No newline at end of file
+		ID:              user.ID,
+		Name:            user.Name,
+		UserName:        user.UserName,
+		Email:           user.Email,
+		RoleID:          user.RoleID,
+		Role:            user.Role,
+		CompanyID:       user.CompanyID,
+		IsActive:        user.IsActive,
+		MFAEnabled:      user.TOTPEnabled,
+		EmailVerified:   user.EmailVerifiedAt != nil,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		Locked:          user.LockedUntil != nil && time.Now().Before(*user.LockedUntil),
+		LockedUntil:     user.LockedUntil,
+		LastLoginAt:     user.LastLoginAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+// validateCompanyExists verifica que la compañía a asignar exista
+func validateCompanyExists(db *gorm.DB, companyID uint) error {
+	var count int64
+	if err := db.Model(&models.Company{}).Where("id = ?", companyID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("company not found")
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"megabaseGo/internal/app/dto"
+	"megabaseGo/internal/models"
+	"megabaseGo/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// grantTestPermissions crea los permisos que falten y los asigna al rol
+func grantTestPermissions(t *testing.T, db *gorm.DB, roleName string, names ...string) {
+	t.Helper()
+	var role models.Role
+	if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		t.Fatalf("finding role %s: %v", roleName, err)
+	}
+	for _, name := range names {
+		permission := models.Permission{Name: name}
+		if err := db.Where(models.Permission{Name: name}).FirstOrCreate(&permission).Error; err != nil {
+			t.Fatalf("creating permission %s: %v", name, err)
+		}
+		if err := db.Model(&role).Association("Permissions").Append(&permission); err != nil {
+			t.Fatalf("granting %s to %s: %v", name, roleName, err)
+		}
+	}
+}
+
+func TestUserRoleAssignmentCannotEscalate(t *testing.T) {
+	db := setupTestDB(t)
+	loadTestConfig(t, nil)
+	createTestRole(t, db, "operator")
+	grantTestPermissions(t, db, models.RoleAdmin, "users:create", "users:update", "roles:permissions")
+	grantTestPermissions(t, db, "operator", "users:create", "users:update")
+
+	admin := createTestUser(t, db, "root", "Secret123!", models.RoleAdmin)
+	operator := createTestUser(t, db, "operator", "Secret123!", "operator")
+	plain := createTestUser(t, db, "plain", "Secret123!", models.RoleUser)
+
+	claimsFor := func(user *models.User) *utils.JWTClaims {
+		return &utils.JWTClaims{UserID: user.ID, UserName: user.UserName, RoleID: user.RoleID, RoleName: user.Role.Name}
+	}
+	service := NewUserService()
+
+	tests := []struct {
+		name       string
+		run        func() error
+		wantStatus int // 0: sin error
+	}{
+		{
+			name: "create user with a role granting more permissions",
+			run: func() error {
+				_, err := service.CreateUser(claimsFor(operator), &dto.CreateUserRequest{Name: "Eve", UserName: "eve", Email: "eve@example.com", Password: "Registro2024", RoleID: admin.RoleID})
+				return err
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "create user with a role within own permissions",
+			run: func() error {
+				_, err := service.CreateUser(claimsFor(operator), &dto.CreateUserRequest{Name: "Bob", UserName: "bob", Email: "bob@example.com", Password: "Registro2024", RoleID: plain.RoleID})
+				return err
+			},
+		},
+		{
+			name: "promote own account",
+			run: func() error {
+				_, err := service.UpdateUser(claimsFor(operator), operator.ID, &dto.UpdateUserRequest{RoleID: admin.RoleID})
+				return err
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "change the password of a more privileged account",
+			run: func() error {
+				_, err := service.UpdateUser(claimsFor(operator), admin.ID, &dto.UpdateUserRequest{Password: "Tomada20245"})
+				return err
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "update a less privileged account",
+			run: func() error {
+				_, err := service.UpdateUser(claimsFor(operator), plain.ID, &dto.UpdateUserRequest{Name: "Plain User"})
+				return err
+			},
+		},
+		{
+			name: "admin assigns any role",
+			run: func() error {
+				_, err := service.UpdateUser(claimsFor(admin), plain.ID, &dto.UpdateUserRequest{RoleID: operator.RoleID})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !hasStatus(err, tt.wantStatus) {
+				t.Fatalf("expected status %d, got %v", tt.wantStatus, err)
+			}
+		})
+	}
+
+	var promoted models.User
+	db.First(&promoted, operator.ID)
+	if promoted.RoleID != operator.RoleID {
+		t.Fatal("operator role changed despite the rejection")
+	}
+}
+
+func TestRolePermissionChangesCannotEscalate(t *testing.T) {
+	db := setupTestDB(t)
+	loadTestConfig(t, nil)
+	createTestRole(t, db, "operator")
+	grantTestPermissions(t, db, models.RoleAdmin, "users:read", "users:update", "users:delete", "roles:permissions")
+	grantTestPermissions(t, db, "operator", "users:read", "roles:permissions")
+	// El rol user ya tiene un permiso que el operador no tiene
+	grantTestPermissions(t, db, models.RoleUser, "users:delete")
+
+	operator := createTestUser(t, db, "operator", "Secret123!", "operator")
+	actor := &utils.JWTClaims{UserID: operator.ID, RoleID: operator.RoleID}
+	var userRole models.Role
+	db.Where("name = ?", models.RoleUser).First(&userRole)
+	service := NewPermissionService()
+
+	tests := []struct {
+		name       string
+		run        func() error
+		wantStatus int
+	}{
+		{
+			name: "add a missing permission to own role",
+			run: func() error {
+				_, err := service.AddRolePermissions(actor, operator.RoleID, []string{"users:update"})
+				return err
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "replace with a permission the actor lacks",
+			run: func() error {
+				_, err := service.SetRolePermissions(actor, operator.RoleID, []string{"users:read", "roles:permissions", "users:delete"})
+				return err
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "add an owned permission to another role",
+			run: func() error {
+				_, err := service.AddRolePermissions(actor, userRole.ID, []string{"users:read"})
+				return err
+			},
+		},
+		{
+			// Conservar lo que el rol ya tenía no concede nada nuevo
+			name: "replace keeping a permission the role already had",
+			run: func() error {
+				_, err := service.SetRolePermissions(actor, userRole.ID, []string{"users:delete", "users:read"})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !hasStatus(err, tt.wantStatus) {
+				t.Fatalf("expected status %d, got %v", tt.wantStatus, err)
+			}
+		})
+	}
+}
@@ -3,8 +3,12 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,8 +21,176 @@ type Config struct {
 	DBName     string
 	ServerPort string
 	SSLMode    string
+
+	// URLs públicas usadas para armar los enlaces de los correos
+	AppURL   string
+	FrontURL string
+
+	// RequireEmailVerification bloquea el login de usuarios con email sin verificar
+	RequireEmailVerification bool
+
+	// Protección contra fuerza bruta en el login
+	LoginMaxAttempts   int           // fallos por usuario antes del bloqueo temporal
+	LoginIPMaxAttempts int           // fallos por IP antes del bloqueo temporal
+	LoginLockout       time.Duration // duración del bloqueo y tope del backoff
+
+	// Registro público e invitaciones. Con el registro deshabilitado solo se crean usuarios
+	// desde la administración o aceptando una invitación.
+	RegistrationEnabled bool
+	RegistrationRole    string        // rol asignado a los usuarios que se registran solos
+	InvitationTTL       time.Duration // vigencia del enlace de invitación
+
+	// ImpersonationTTL vigencia del access token emitido al suplantar a un usuario
+	ImpersonationTTL time.Duration
+
+	// AuthTokenSources orden en que el middleware busca el access token: header, cookie, query.
+	// query solo aplica a GET (descargas y SSE donde no se pueden enviar cabeceras).
+	AuthTokenSources []string
+
+	// CursorSecret firma los cursores de paginación (?cursor=) para que el cliente no pueda
+	// alterarlos. Sin CURSOR_SECRET se usa JWT_SECRET; sin ninguno, una clave aleatoria por proceso.
+	CursorSecret string
+
+	Mail     MailConfig
+	Password PasswordConfig
+	Cookie   CookieConfig
+
+	// OIDCProviders proveedores de identidad externos para SSO, en el orden de OIDC_PROVIDERS
+	OIDCProviders []OIDCProviderConfig
+
+	LDAP LDAPConfig
+}
+
+// LDAPConfig autenticación contra LDAP / Active Directory. Con Enabled, el login con contraseña
+// se valida con un bind al directorio, salvo para las cuentas locales de LocalUsers.
+type LDAPConfig struct {
+	Enabled            bool
+	URL                string // ldap://host:389 o ldaps://host:636
+	StartTLS           bool
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+
+	// Cuenta de servicio para buscar al usuario; vacía usa bind anónimo
+	BindDN       string
+	BindPassword string
+
+	BaseDN string
+	// UserFilter filtro de búsqueda; {username} se reemplaza por el usuario escapado
+	UserFilter     string
+	EmailAttribute string
+	NameAttribute  string
+	GroupAttribute string
+
+	// RoleMap grupo (DN completo o CN) -> rol local; gana la primera regla que coincida
+	RoleMap []RoleMapping
+	// DefaultRole rol de las cuentas creadas sin grupo coincidente; vacío rechaza el login
+	DefaultRole string
+	// SyncRole vuelve a aplicar RoleMap en cada login
+	SyncRole bool
+	// LocalUsers usernames que siempre se autentican contra la base (ej. el admin sembrado),
+	// para no perder el acceso si el directorio no está disponible
+	LocalUsers []string
+	// LinkLocalAccounts vincula en el primer login del directorio la cuenta local con el mismo
+	// username. Nunca se vinculan cuentas con rol admin o de sistema.
+	LinkLocalAccounts bool
+}
+
+// OIDCProviderConfig proveedor OpenID Connect. Se configura con OIDC_<NOMBRE>_*, ej. OIDC_CORP_ISSUER.
+type OIDCProviderConfig struct {
+	Name         string // identificador en la URL: /auth/oidc/:provider/login
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	// RoleClaim claim con los grupos o roles del usuario (admite rutas con punto, ej. realm_access.roles)
+	RoleClaim string
+	// RoleMap valor del claim -> rol local; gana la primera regla que coincida
+	RoleMap []RoleMapping
+	// DefaultRole rol de las cuentas creadas sin regla coincidente; vacío rechaza el alta
+	DefaultRole string
+	// AutoProvision crea la cuenta en el primer login si no existe (JIT)
+	AutoProvision bool
+	// SyncRole vuelve a aplicar RoleMap en cada login
+	SyncRole bool
+	// TrustEmail considera verificado el email aunque el proveedor no envíe email_verified
+	TrustEmail bool
+}
+
+// RoleMapping regla del mapeo de claims o grupos externos a roles locales
+type RoleMapping struct {
+	Value string // valor del claim o grupo del directorio
+	Role  string
+}
+
+// OIDCProvider busca un proveedor configurado por nombre
+func (c *Config) OIDCProvider(name string) (*OIDCProviderConfig, bool) {
+	for i := range c.OIDCProviders {
+		if c.OIDCProviders[i].Name == name {
+			return &c.OIDCProviders[i], true
+		}
+	}
+	return nil, false
 }
 
+// CookieConfig atributos de las cookies de autenticación y CSRF
+type CookieConfig struct {
+	AccessName  string
+	RefreshName string
+	CSRFName    string
+	CSRFHeader  string
+	CSRFEnabled bool
+	Domain      string
+	Path        string
+	Secure      bool
+	SameSite    http.SameSite
+	// HostPrefix antepone "__Host-" a los nombres; exige Secure, Path=/ y sin Domain
+	HostPrefix bool
+}
+
+// Name devuelve el nombre efectivo de la cookie, con el prefijo __Host- si corresponde
+func (c CookieConfig) Name(base string) string {
+	if c.HostPrefix {
+		return "__Host-" + base
+	}
+	return base
+}
+
+// PasswordConfig algoritmo de hash y política de contraseñas
+type PasswordConfig struct {
+	Hasher        string // argon2id o bcrypt; los hashes de otro algoritmo se rehacen al iniciar sesión
+	BcryptCost    int
+	Argon2Memory  uint32 // KiB
+	Argon2Time    uint32
+	Argon2Threads uint8
+
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	HistorySize   int    // cantidad de contraseñas anteriores que no pueden reutilizarse
+	DenylistFile  string // archivo opcional con contraseñas prohibidas adicionales, una por línea
+}
+
+// MailConfig configuración del envío de correos
+type MailConfig struct {
+	Driver       string // smtp, file o console
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	FileDir      string // directorio donde el driver file guarda los .eml
+}
+
+var (
+	current   *Config
+	currentMu sync.Mutex
+)
+
 func LoadConfig() *Config {
 	// Cargar variables de entorno desde .env
 	err := godotenv.Load()
@@ -32,7 +204,12 @@ func LoadConfig() *Config {
 		log.Fatalf("Error al convertir el puerto de la base de datos: %v", err)
 	}
 
-	return &Config{
+	smtpPort, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil {
+		log.Fatalf("Error al convertir el puerto SMTP: %v", err)
+	}
+
+	cfg := &Config{
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     dbPort,
 		DBUser:     getEnv("DB_USER", "postgres"),
@@ -40,7 +217,179 @@ func LoadConfig() *Config {
 		DBName:     getEnv("DB_NAME", "megabase_go"),
 		ServerPort: getEnv("SERVER_PORT", "8080"),
 		SSLMode:    getEnv("DB_SSLMODE", "disable"),
+
+		AppURL:   getEnv("APP_URL", "http://localhost:8080"),
+		FrontURL: getEnv("FRONT_URL", "http://localhost:3000"),
+
+		RequireEmailVerification: getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+
+		LoginMaxAttempts:   getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginIPMaxAttempts: getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		LoginLockout:       time.Duration(getEnvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+
+		RegistrationEnabled: getEnvBool("REGISTRATION_ENABLED", true),
+		RegistrationRole:    getEnv("REGISTRATION_DEFAULT_ROLE", "user"),
+		InvitationTTL:       time.Duration(getEnvInt("INVITATION_TTL_HOURS", 72)) * time.Hour,
+
+		ImpersonationTTL: time.Duration(getEnvInt("IMPERSONATION_TTL_MINUTES", 15)) * time.Minute,
+
+		AuthTokenSources: getEnvList("AUTH_TOKEN_SOURCES", []string{"header", "cookie"}),
+
+		CursorSecret: getEnv("CURSOR_SECRET", os.Getenv("JWT_SECRET")),
+
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "console"),
+			From:         getEnv("MAIL_FROM", "no-reply@megabase.local"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     smtpPort,
+			SMTPUser:     getEnv("SMTP_USER", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			FileDir:      getEnv("MAIL_FILE_DIR", "storage/mail"),
+		},
+
+		Cookie: loadCookieConfig(),
+
+		Password: PasswordConfig{
+			Hasher:        getEnv("PASSWORD_HASHER", "argon2id"),
+			BcryptCost:    getEnvInt("BCRYPT_COST", 12),
+			Argon2Memory:  uint32(getEnvInt("ARGON2_MEMORY_KIB", 64*1024)),
+			Argon2Time:    uint32(getEnvInt("ARGON2_TIME", 3)),
+			Argon2Threads: uint8(getEnvInt("ARGON2_THREADS", 2)),
+
+			MinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 10),
+			RequireUpper:  getEnvBool("PASSWORD_REQUIRE_UPPER", true),
+			RequireLower:  getEnvBool("PASSWORD_REQUIRE_LOWER", true),
+			RequireDigit:  getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol: getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			HistorySize:   getEnvInt("PASSWORD_HISTORY", 5),
+			DenylistFile:  getEnv("PASSWORD_DENYLIST_FILE", ""),
+		},
+	}
+
+	cfg.OIDCProviders = loadOIDCProviders(cfg.AppURL)
+	cfg.LDAP = loadLDAPConfig()
+
+	currentMu.Lock()
+	current = cfg
+	currentMu.Unlock()
+
+	return cfg
+}
+
+// loadOIDCProviders lee los proveedores listados en OIDC_PROVIDERS; se omiten los incompletos
+func loadOIDCProviders(appURL string) []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range getEnvList("OIDC_PROVIDERS", nil) {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		provider := OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL: getEnv(prefix+"REDIRECT_URL",
+				strings.TrimRight(appURL, "/")+"/api/v1/auth/oidc/"+name+"/callback"),
+			Scopes:        getEnvList(prefix+"SCOPES", []string{"openid", "email", "profile"}),
+			RoleClaim:     getEnv(prefix+"ROLE_CLAIM", "groups"),
+			RoleMap:       parseRoleMap(os.Getenv(prefix + "ROLE_MAP")),
+			DefaultRole:   getEnv(prefix+"DEFAULT_ROLE", ""),
+			AutoProvision: getEnvBool(prefix+"AUTO_PROVISION", false),
+			SyncRole:      getEnvBool(prefix+"SYNC_ROLE", false),
+			TrustEmail:    getEnvBool(prefix+"TRUST_EMAIL", false),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Printf("Proveedor OIDC %q omitido: faltan %sISSUER o %sCLIENT_ID", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, provider)
 	}
+	return providers
+}
+
+// loadLDAPConfig lee LDAP_*; los valores por defecto corresponden a Active Directory
+func loadLDAPConfig() LDAPConfig {
+	return LDAPConfig{
+		Enabled:            getEnvBool("LDAP_ENABLED", false),
+		URL:                getEnv("LDAP_URL", "ldap://localhost:389"),
+		StartTLS:           getEnvBool("LDAP_START_TLS", false),
+		InsecureSkipVerify: getEnvBool("LDAP_INSECURE_SKIP_VERIFY", false),
+		Timeout:            time.Duration(getEnvInt("LDAP_TIMEOUT_SECONDS", 10)) * time.Second,
+		BindDN:             getEnv("LDAP_BIND_DN", ""),
+		BindPassword:       getEnv("LDAP_BIND_PASSWORD", ""),
+		BaseDN:             getEnv("LDAP_BASE_DN", ""),
+		UserFilter:         getEnv("LDAP_USER_FILTER", "(&(objectClass=user)(sAMAccountName={username}))"),
+		EmailAttribute:     getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		NameAttribute:      getEnv("LDAP_NAME_ATTRIBUTE", "displayName"),
+		GroupAttribute:     getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		RoleMap:            parseRoleMap(os.Getenv("LDAP_ROLE_MAP")),
+		DefaultRole:        getEnv("LDAP_DEFAULT_ROLE", ""),
+		SyncRole:           getEnvBool("LDAP_SYNC_ROLE", true),
+		LocalUsers:         getEnvList("LDAP_LOCAL_USERS", []string{"admin"}),
+		LinkLocalAccounts:  getEnvBool("LDAP_LINK_LOCAL_ACCOUNTS", false),
+	}
+}
+
+// parseRoleMap lee "grupo=rol,otro-grupo=otro-rol"; los valores de claims OIDC distinguen mayúsculas
+func parseRoleMap(value string) []RoleMapping {
+	var mappings []RoleMapping
+	for _, pair := range strings.Split(value, ",") {
+		value, role, ok := strings.Cut(pair, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !ok || value == "" || role == "" {
+			continue
+		}
+		mappings = append(mappings, RoleMapping{Value: value, Role: role})
+	}
+	return mappings
+}
+
+// loadCookieConfig lee la configuración de cookies y ajusta las combinaciones que el navegador rechazaría
+func loadCookieConfig() CookieConfig {
+	cfg := CookieConfig{
+		AccessName:  getEnv("COOKIE_ACCESS_NAME", "access_token"),
+		RefreshName: getEnv("COOKIE_REFRESH_NAME", "refresh_token"),
+		CSRFName:    getEnv("CSRF_COOKIE_NAME", "csrf_token"),
+		CSRFHeader:  getEnv("CSRF_HEADER_NAME", "X-CSRF-Token"),
+		CSRFEnabled: getEnvBool("CSRF_ENABLED", true),
+		Domain:      getEnv("COOKIE_DOMAIN", ""),
+		Path:        getEnv("COOKIE_PATH", "/"),
+		Secure:      getEnvBool("COOKIE_SECURE", true),
+		HostPrefix:  getEnvBool("COOKIE_HOST_PREFIX", false),
+	}
+
+	switch strings.ToLower(getEnv("COOKIE_SAMESITE", "lax")) {
+	case "strict":
+		cfg.SameSite = http.SameSiteStrictMode
+	case "none":
+		cfg.SameSite = http.SameSiteNoneMode
+	default:
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+
+	// SameSite=None solo se acepta con Secure
+	if cfg.SameSite == http.SameSiteNoneMode {
+		cfg.Secure = true
+	}
+	if cfg.HostPrefix {
+		cfg.Secure = true
+		cfg.Path = "/"
+		cfg.Domain = ""
+	}
+
+	return cfg
+}
+
+// Get devuelve la configuración cargada; si aún no se cargó, la carga desde el entorno
+func Get() *Config {
+	currentMu.Lock()
+	cfg := current
+	currentMu.Unlock()
+
+	if cfg == nil {
+		return LoadConfig()
+	}
+	return cfg
 }
 
 // GetDBConnectionString devuelve la cadena de conexión para PostgreSQL
@@ -62,3 +411,32 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
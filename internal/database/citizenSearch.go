@@ -0,0 +1,59 @@
+package database
+
+import "gorm.io/gorm"
+
+// CitizenSearchConfig configuración de búsqueda de texto: español sin acentos ("jose" encuentra "José")
+const CitizenSearchConfig = "spanish_unaccent"
+
+// citizenSearchMigrations prepara la búsqueda de ciudadanos (GET /citizens/search). AutoMigrate no
+// sabe declarar columnas generadas ni índices GIN, así que se crean aquí; todas las sentencias son
+// idempotentes y se ejecutan en cada migrate.
+//   - search_vector: tsvector ponderado (nombre y razón social A, nombre comercial B, actividad C)
+//   - search_text: nombres en minúsculas y sin acentos para la similitud por trigramas (pg_trgm)
+//   - numero_identificacion con text_pattern_ops para la búsqueda por prefijo (LIKE '1790%')
+var citizenSearchMigrations = []string{
+	`CREATE EXTENSION IF NOT EXISTS unaccent`,
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+
+	// unaccent() es STABLE; las columnas generadas e índices exigen una función IMMUTABLE
+	`CREATE OR REPLACE FUNCTION f_unaccent(text) RETURNS text
+		LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+		AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$`,
+
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'spanish_unaccent') THEN
+			CREATE TEXT SEARCH CONFIGURATION spanish_unaccent (COPY = spanish);
+			ALTER TEXT SEARCH CONFIGURATION spanish_unaccent
+				ALTER MAPPING FOR hword, hword_part, word WITH unaccent, spanish_stem;
+		END IF;
+	END $$`,
+
+	`ALTER TABLE citizens ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('spanish_unaccent', coalesce(nombre, '')), 'A') ||
+		setweight(to_tsvector('spanish_unaccent', coalesce(razon_social, '')), 'A') ||
+		setweight(to_tsvector('spanish_unaccent', coalesce(nombre_comercial, '')), 'B') ||
+		setweight(to_tsvector('spanish_unaccent', coalesce(actividad_economica_principal, '')), 'C')
+	) STORED`,
+
+	`ALTER TABLE citizens ADD COLUMN IF NOT EXISTS search_text text GENERATED ALWAYS AS (
+		f_unaccent(lower(coalesce(nombre, '') || ' ' || coalesce(razon_social, '') || ' ' || coalesce(nombre_comercial, '')))
+	) STORED`,
+
+	`CREATE INDEX IF NOT EXISTS idx_citizens_search_vector ON citizens USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_citizens_search_text_trgm ON citizens USING GIN (search_text gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_citizens_numero_identificacion_pattern ON citizens (numero_identificacion text_pattern_ops)`,
+}
+
+// MigrateCitizenSearch crea extensiones, columnas generadas e índices de la búsqueda de ciudadanos.
+// Debe ejecutarse después de AutoMigrate (la tabla citizens ya tiene que existir).
+func MigrateCitizenSearch(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range citizenSearchMigrations {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
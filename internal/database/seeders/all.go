@@ -13,7 +13,8 @@ type Seeder interface {
 
 // AllSeeders contiene todos los seeders para ejecución dinámica
 var AllSeeders = []Seeder{
+    &PermissionSeeder{},
     &RoleSeeder{},
-    NewUserSeeder(utils.NewBcryptHasher()),
+    NewUserSeeder(utils.NewPasswordHasher()),
     // Añade aquí tus nuevos seeders, e.g.: &ProductSeeder{},
 }
\ No newline at end of file
@@ -0,0 +1,54 @@
+package seeders
+
+import (
+	"log"
+
+	"megabaseGo/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PermissionCatalog permisos que conoce la aplicación; los nuevos se agregan aquí y en las rutas
+var PermissionCatalog = []models.Permission{
+	{Name: "users:read", Description: "Ver usuarios"},
+	{Name: "users:create", Description: "Crear usuarios"},
+	{Name: "users:update", Description: "Modificar usuarios"},
+	{Name: "users:delete", Description: "Eliminar usuarios"},
+	{Name: "users:unlock", Description: "Desbloquear cuentas bloqueadas por intentos fallidos"},
+	{Name: "users:impersonate", Description: "Suplantar a otros usuarios para soporte"},
+	{Name: "users:invite", Description: "Invitar usuarios y administrar las invitaciones pendientes"},
+	{Name: "users:sessions", Description: "Ver el historial de acceso y cerrar sesiones de otros usuarios"},
+	{Name: "roles:read", Description: "Ver roles y sus permisos"},
+	{Name: "roles:create", Description: "Crear roles"},
+	{Name: "roles:update", Description: "Modificar roles"},
+	{Name: "roles:delete", Description: "Eliminar roles"},
+	{Name: "roles:permissions", Description: "Asignar y quitar permisos de roles"},
+	{Name: "citizens:read", Description: "Ver ciudadanos"},
+	{Name: "citizens:create", Description: "Crear ciudadanos"},
+	{Name: "citizens:update", Description: "Modificar ciudadanos"},
+	{Name: "citizens:delete", Description: "Eliminar ciudadanos"},
+	{Name: "citizens:all", Description: "Ver y modificar ciudadanos de todos los usuarios y compañías"},
+	{Name: "companies:read", Description: "Ver compañías"},
+	{Name: "companies:create", Description: "Crear compañías"},
+	{Name: "companies:update", Description: "Modificar compañías"},
+	{Name: "companies:delete", Description: "Eliminar compañías"},
+	{Name: "api_keys:manage", Description: "Ver y revocar API keys de todos los usuarios"},
+}
+
+type PermissionSeeder struct{}
+
+// Run crea los permisos del catálogo que no existan y actualiza su descripción
+func (s *PermissionSeeder) Run(db *gorm.DB) error {
+	for _, permission := range PermissionCatalog {
+		p := permission
+		if err := db.Where(models.Permission{Name: p.Name}).
+			Assign(models.Permission{Description: p.Description}).
+			FirstOrCreate(&p).Error; err != nil {
+			log.Printf("Error creando permiso %s: %v", p.Name, err)
+			return err
+		}
+	}
+
+	log.Printf("Permisos sincronizados (%d)", len(PermissionCatalog))
+	return nil
+}
@@ -11,18 +11,47 @@ type RoleSeeder struct{}
 
 func (s *RoleSeeder) Run(db *gorm.DB) error {
 	adminRole := models.Role{
-		Name:        "admin",
+		Name:        models.RoleAdmin,
 		DisplayName: "Administrator",
 		Description: "Administrador con acceso completo al sistema",
 		IsActive:    true,
 	}
 
-	// Create admin role with ID 1
-	if err := db.FirstOrCreate(&adminRole, models.Role{Name: "admin"}).Error; err != nil {
+	// Create admin role with ID 1; los roles sembrados se marcan de sistema aunque ya existan
+	if err := db.Where(models.Role{Name: models.RoleAdmin}).
+		Assign(map[string]interface{}{"is_system": true}).
+		FirstOrCreate(&adminRole).Error; err != nil {
 		log.Printf("Error creando rol admin: %v", err)
 		return err
 	}
 
+	// El admin recibe todos los permisos existentes, incluidos los agregados después
+	var permissions []models.Permission
+	if err := db.Find(&permissions).Error; err != nil {
+		log.Printf("Error obteniendo permisos: %v", err)
+		return err
+	}
+	if err := db.Model(&adminRole).Association("Permissions").Replace(permissions); err != nil {
+		log.Printf("Error asignando permisos al rol admin: %v", err)
+		return err
+	}
+
 	log.Println("Creacion de rol admin exitosa")
+
+	// Rol sin permisos asignado en el registro público (REGISTRATION_DEFAULT_ROLE)
+	userRole := models.Role{
+		Name:        models.RoleUser,
+		DisplayName: "User",
+		Description: "Usuario registrado sin permisos administrativos",
+		IsActive:    true,
+	}
+	if err := db.Where(models.Role{Name: models.RoleUser}).
+		Assign(map[string]interface{}{"is_system": true}).
+		FirstOrCreate(&userRole).Error; err != nil {
+		log.Printf("Error creando rol user: %v", err)
+		return err
+	}
+
+	log.Println("Creacion de rol user exitosa")
 	return nil
 }
@@ -3,6 +3,7 @@ package seeders
 
 import (
     "log"
+    "time"
 
     "megabaseGo/internal/models"
     "megabaseGo/internal/utils"
@@ -37,6 +38,7 @@ func (s *UserSeeder) Run(db *gorm.DB) error {
 
     // 3) Si no existe, lo crea (GORM setea CreatedAt/UpdatedAt automáticamente)
     if res.Error == gorm.ErrRecordNotFound {
+        verifiedAt := time.Now()
         admin := models.User{
             Name:     "Admin",
             UserName: "admin",
@@ -44,6 +46,8 @@ func (s *UserSeeder) Run(db *gorm.DB) error {
             Password: hashedPassword,
             RoleID:   1,
             IsActive: true,
+            // El admin sembrado no tiene buzón real: se da por verificado
+            EmailVerifiedAt: &verifiedAt,
             // LastLoginAt queda en cero, GORM lo manejará si tienes hooks
         }
         if err := db.Create(&admin).Error; err != nil {
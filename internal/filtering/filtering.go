@@ -0,0 +1,376 @@
+// Package filtering interpreta los filtros genéricos de los listados con la forma
+// filter[campo][operador]=valor (ej. filter[created_at][gte]=2024-01-01, filter[regimen][in]=RIMPE,GENERAL)
+// y los traduce a cláusulas de GORM. Cada recurso declara una lista blanca de campos con su tipo;
+// los nombres de columna salen de esa lista y los valores viajan siempre como parámetros.
+package filtering
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Type tipo de dato de un campo filtrable; define los operadores permitidos y cómo se lee el valor
+type Type int
+
+const (
+	String Type = iota
+	Number
+	Bool
+	Time
+	// JSON columnas jsonb; se filtran por ruta: filter[sucursales.estado][eq]=ABIERTO
+	JSON
+)
+
+// Operadores disponibles por tipo. Sin operador en la clave se asume eq.
+var operators = map[Type][]string{
+	String: {"eq", "ne", "like", "in", "nin", "null"},
+	Number: {"eq", "ne", "gt", "gte", "lt", "lte", "in", "nin", "between", "null"},
+	Bool:   {"eq", "ne", "null"},
+	Time:   {"eq", "gt", "gte", "lt", "lte", "between", "null"},
+	JSON:   {"eq", "like", "in", "null"},
+}
+
+const (
+	maxConditions = 20
+	maxValues     = 100
+	dateLayout    = "2006-01-02"
+)
+
+var (
+	filterKey = regexp.MustCompile(`^filter\[([A-Za-z0-9_.]+)\](?:\[([a-z]+)\])?$`)
+	jsonKey   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Field campo filtrable: columna (puede ir calificada con la tabla) y tipo
+type Field struct {
+	Column string
+	Type   Type
+}
+
+// Fields lista blanca de campos filtrables de un recurso: nombre en filter[...] -> campo
+type Fields map[string]Field
+
+// Conditions condiciones ya validadas, listas para aplicar a la query
+type Conditions []clause.Expression
+
+// Apply agrega las condiciones a la query (todas deben cumplirse)
+func (c Conditions) Apply(query *gorm.DB) *gorm.DB {
+	for _, condition := range c {
+		query = query.Where(condition)
+	}
+	return query
+}
+
+// Parse lee los parámetros filter[...] de la query; los demás parámetros se ignoran.
+// Un campo o un operador fuera de la lista blanca es un error, no se descarta en silencio.
+func Parse(query url.Values, fields Fields) (Conditions, error) {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if strings.HasPrefix(key, "filter[") {
+			keys = append(keys, key)
+		}
+	}
+	// Orden fijo para que la SQL generada (y los errores) no dependan del orden del mapa
+	sort.Strings(keys)
+
+	var conditions Conditions
+	for _, key := range keys {
+		match := filterKey.FindStringSubmatch(key)
+		if match == nil {
+			return nil, fmt.Errorf("filtro inválido %q; use filter[campo][operador]=valor", key)
+		}
+		name, operator := match[1], match[2]
+		if operator == "" {
+			operator = "eq"
+		}
+
+		for _, value := range query[key] {
+			if len(conditions) == maxConditions {
+				return nil, fmt.Errorf("se admiten como máximo %d filtros", maxConditions)
+			}
+			condition, err := fields.condition(name, operator, value)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions, nil
+}
+
+// condition valida campo y operador y arma la cláusula
+func (f Fields) condition(name, operator, value string) (clause.Expression, error) {
+	base, path, _ := strings.Cut(name, ".")
+	field, ok := f[base]
+	if !ok || (path != "" && field.Type != JSON) {
+		return nil, fmt.Errorf("no se puede filtrar por %q; campos permitidos: %s", name, strings.Join(f.names(), ", "))
+	}
+	if !allowed(field.Type, operator) {
+		return nil, fmt.Errorf("operador %q no válido para %s; operadores permitidos: %s", operator, name, strings.Join(operators[field.Type], ", "))
+	}
+
+	column := clause.Column{Name: field.Column}
+	if operator == "null" {
+		isNull, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("filter[%s][null] debe ser true o false", name)
+		}
+		if field.Type == JSON {
+			return jsonNullCondition(column, name, path, isNull)
+		}
+		if isNull {
+			return clause.Eq{Column: column, Value: nil}, nil
+		}
+		return clause.Neq{Column: column, Value: nil}, nil
+	}
+
+	switch field.Type {
+	case JSON:
+		return jsonCondition(column, name, path, operator, value)
+	case Time:
+		return timeCondition(column, name, operator, value)
+	}
+
+	parse := func(raw string) (interface{}, error) { return parseValue(field.Type, name, raw) }
+	switch operator {
+	case "like":
+		return clause.Expr{SQL: "? ILIKE ?", Vars: []interface{}{column, "%" + escapeLike(value) + "%"}}, nil
+	case "in", "nin":
+		values, err := parseList(value, name, parse)
+		if err != nil {
+			return nil, err
+		}
+		if operator == "nin" {
+			return clause.Not(clause.IN{Column: column, Values: values}), nil
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case "between":
+		bounds, err := parseList(value, name, parse)
+		if err != nil {
+			return nil, err
+		}
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("filter[%s][between] espera dos valores separados por coma", name)
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{column, bounds[0], bounds[1]}}, nil
+	}
+
+	parsed, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return compare(column, operator, parsed), nil
+}
+
+// compare operadores de comparación simples
+func compare(column clause.Column, operator string, value interface{}) clause.Expression {
+	switch operator {
+	case "ne":
+		return clause.Neq{Column: column, Value: value}
+	case "gt":
+		return clause.Gt{Column: column, Value: value}
+	case "gte":
+		return clause.Gte{Column: column, Value: value}
+	case "lt":
+		return clause.Lt{Column: column, Value: value}
+	case "lte":
+		return clause.Lte{Column: column, Value: value}
+	default:
+		return clause.Eq{Column: column, Value: value}
+	}
+}
+
+// timeCondition fechas en RFC 3339 o solo día (AAAA-MM-DD). Un día completo se toma como el rango
+// [00:00, 00:00 del día siguiente): lte=2024-01-31 incluye todo el 31 y eq=2024-01-31 todo ese día.
+func timeCondition(column clause.Column, name, operator, value string) (clause.Expression, error) {
+	if operator == "between" {
+		parts := strings.Split(value, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("filter[%s][between] espera dos fechas separadas por coma", name)
+		}
+		from, _, err := parseTime(name, parts[0])
+		if err != nil {
+			return nil, err
+		}
+		to, toEnd, err := parseTime(name, parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if toEnd.IsZero() {
+			return clause.And(clause.Gte{Column: column, Value: from}, clause.Lte{Column: column, Value: to}), nil
+		}
+		return clause.And(clause.Gte{Column: column, Value: from}, clause.Lt{Column: column, Value: toEnd}), nil
+	}
+
+	start, end, err := parseTime(name, value)
+	if err != nil {
+		return nil, err
+	}
+	if end.IsZero() {
+		return compare(column, operator, start), nil
+	}
+
+	switch operator {
+	case "gt":
+		return clause.Gte{Column: column, Value: end}, nil
+	case "gte":
+		return clause.Gte{Column: column, Value: start}, nil
+	case "lt":
+		return clause.Lt{Column: column, Value: start}, nil
+	case "lte":
+		return clause.Lt{Column: column, Value: end}, nil
+	default:
+		return clause.And(clause.Gte{Column: column, Value: start}, clause.Lt{Column: column, Value: end}), nil
+	}
+}
+
+// parseTime devuelve el instante y, si el valor es solo un día, el inicio del día siguiente
+func parseTime(name, value string) (time.Time, time.Time, error) {
+	value = strings.TrimSpace(value)
+	if day, err := time.ParseInLocation(dateLayout, value, time.Local); err == nil {
+		return day, day.AddDate(0, 0, 1), nil
+	}
+	instant, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("filter[%s] espera una fecha AAAA-MM-DD o RFC 3339", name)
+	}
+	return instant, time.Time{}, nil
+}
+
+// jsonCondition compara los valores que hay en la ruta dentro del jsonb. La ruta se evalúa en modo
+// lax, así que sirve tanto para un objeto como para un arreglo de objetos (cualquiera que coincida).
+func jsonCondition(column clause.Column, name, path, operator, value string) (clause.Expression, error) {
+	jsonPath, err := jsonPathOf(name, path)
+	if err != nil {
+		return nil, err
+	}
+
+	const values = "EXISTS (SELECT 1 FROM jsonb_path_query(?, ?::jsonpath) AS j(value) WHERE j.value #>> '{}' "
+	switch operator {
+	case "like":
+		return clause.Expr{SQL: values + "ILIKE ?)", Vars: []interface{}{column, jsonPath, "%" + escapeLike(value) + "%"}}, nil
+	case "in":
+		list, err := parseList(value, name, func(raw string) (interface{}, error) { return raw, nil })
+		if err != nil {
+			return nil, err
+		}
+		return clause.Expr{SQL: values + "IN ?)", Vars: []interface{}{column, jsonPath, list}}, nil
+	default:
+		return clause.Expr{SQL: values + "= ?)", Vars: []interface{}{column, jsonPath, value}}, nil
+	}
+}
+
+// jsonNullCondition sin ruta: columna vacía (NULL, null o []); con ruta: ningún valor no nulo en ella
+func jsonNullCondition(column clause.Column, name, path string, isNull bool) (clause.Expression, error) {
+	var condition clause.Expression
+	if path == "" {
+		condition = clause.Expr{
+			SQL:  "(? IS NULL OR jsonb_typeof(?) = 'null' OR ? = '[]'::jsonb)",
+			Vars: []interface{}{column, column, column},
+		}
+	} else {
+		jsonPath, err := jsonPathOf(name, path)
+		if err != nil {
+			return nil, err
+		}
+		condition = clause.Expr{
+			SQL:  "NOT EXISTS (SELECT 1 FROM jsonb_path_query(?, ?::jsonpath) AS j(value) WHERE j.value <> 'null'::jsonb)",
+			Vars: []interface{}{column, jsonPath},
+		}
+	}
+	if isNull {
+		return condition, nil
+	}
+	return clause.Not(condition), nil
+}
+
+// jsonPathOf arma la ruta jsonpath; las claves se validan y se citan, nunca se copia texto libre
+func jsonPathOf(name, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("filter[%s] necesita una ruta, ej. filter[%s.campo]", name, name)
+	}
+	var builder strings.Builder
+	builder.WriteString("$[*]")
+	for _, key := range strings.Split(path, ".") {
+		if !jsonKey.MatchString(key) {
+			return "", fmt.Errorf("ruta inválida en filter[%s]", name)
+		}
+		builder.WriteString(`."` + key + `"`)
+	}
+	return builder.String(), nil
+}
+
+// parseValue convierte el valor al tipo del campo
+func parseValue(fieldType Type, name, raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+	switch fieldType {
+	case Number:
+		if integer, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return integer, nil
+		}
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter[%s] espera un número", name)
+		}
+		return number, nil
+	case Bool:
+		boolean, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("filter[%s] espera true o false", name)
+		}
+		return boolean, nil
+	default:
+		return raw, nil
+	}
+}
+
+// parseList valores separados por coma (in, nin, between)
+func parseList(value, name string, parse func(string) (interface{}, error)) ([]interface{}, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > maxValues {
+		return nil, fmt.Errorf("filter[%s] admite como máximo %d valores", name, maxValues)
+	}
+	values := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		parsed, err := parse(part)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, parsed)
+	}
+	return values, nil
+}
+
+// escapeLike escapa los comodines para que like busque el texto tal cual
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func allowed(fieldType Type, operator string) bool {
+	for _, candidate := range operators[fieldType] {
+		if candidate == operator {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Fields) names() []string {
+	names := make([]string, 0, len(f))
+	for name, field := range f {
+		if field.Type == JSON {
+			name += ".<ruta>"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
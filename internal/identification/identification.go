@@ -0,0 +1,223 @@
+// Package identification valida los números de identificación ecuatorianos según las reglas
+// del Registro Civil y del SRI: cédula (módulo 10), RUC de persona natural, sociedad privada
+// (módulo 11) y sociedad pública, consumidor final, pasaporte e identificación del exterior.
+package identification
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Tipos de identificación del catálogo del SRI (tipo_identificacion)
+const (
+	TypeRUC         = "04"
+	TypeCedula      = "05"
+	TypePasaporte   = "06"
+	TypeExterior    = "07" // consumidor final o identificación del exterior
+	ConsumidorFinal = "9999999999999"
+)
+
+// Kind clase concreta de identificación que resulta de validar el número
+type Kind string
+
+const (
+	KindCedula          Kind = "cedula"
+	KindRUCNatural      Kind = "ruc_natural"
+	KindRUCPrivada      Kind = "ruc_privada"
+	KindRUCPublica      Kind = "ruc_publica"
+	KindConsumidorFinal Kind = "consumidor_final"
+	KindPasaporte       Kind = "pasaporte"
+	KindExterior        Kind = "exterior"
+)
+
+// IsRUC indica si la identificación es un RUC (natural, privada o pública)
+func (k Kind) IsRUC() bool {
+	return k == KindRUCNatural || k == KindRUCPrivada || k == KindRUCPublica
+}
+
+var (
+	ErrUnknownType     = errors.New("Tipo de identificación desconocido")
+	ErrNotNumeric      = errors.New("La identificación solo debe contener dígitos")
+	ErrLength          = errors.New("El número debe tener 10 o 13 dígitos")
+	ErrCedulaLength    = errors.New("La cédula debe tener 10 dígitos")
+	ErrRUCLength       = errors.New("El RUC debe tener 13 dígitos")
+	ErrProvince        = errors.New("El código de provincia es inválido")
+	ErrThirdDigit      = errors.New("El tercer dígito es inválido")
+	ErrCheckDigit      = errors.New("El dígito verificador es inválido")
+	ErrEstablishment   = errors.New("El código de establecimiento del RUC es inválido")
+	ErrPasaporte       = errors.New("El pasaporte debe tener entre 5 y 20 letras o dígitos")
+	ErrExterior        = errors.New("La identificación del exterior debe tener entre 5 y 25 letras, dígitos o guiones")
+	ErrConsumidorFinal = errors.New("El consumidor final solo se admite como tipo 07")
+)
+
+var (
+	pasaportePattern = regexp.MustCompile(`^[A-Za-z0-9]{5,20}$`)
+	exteriorPattern  = regexp.MustCompile(`^[A-Za-z0-9-]{5,25}$`)
+)
+
+// Coeficientes del dígito verificador
+var (
+	cedulaCoefficients  = []int{2, 1, 2, 1, 2, 1, 2, 1, 2}
+	privadaCoefficients = []int{4, 3, 2, 7, 6, 5, 4, 3, 2}
+	publicaCoefficients = []int{3, 2, 7, 6, 5, 4, 3, 2}
+)
+
+// Validate valida el número para el tipo de identificación indicado y devuelve su clase
+func Validate(tipo, numero string) (Kind, error) {
+	switch tipo {
+	case TypeCedula:
+		if err := ValidateCedula(numero); err != nil {
+			return "", err
+		}
+		return KindCedula, nil
+	case TypeRUC:
+		if numero == ConsumidorFinal {
+			return "", ErrConsumidorFinal
+		}
+		return ValidateRUC(numero)
+	case TypePasaporte:
+		if !pasaportePattern.MatchString(numero) {
+			return "", ErrPasaporte
+		}
+		return KindPasaporte, nil
+	case TypeExterior:
+		if numero == ConsumidorFinal {
+			return KindConsumidorFinal, nil
+		}
+		if !exteriorPattern.MatchString(numero) {
+			return "", ErrExterior
+		}
+		return KindExterior, nil
+	default:
+		return "", ErrUnknownType
+	}
+}
+
+// Detect deduce la clase de un número ecuatoriano por su longitud: 10 dígitos es cédula,
+// 13 dígitos es RUC o consumidor final
+func Detect(numero string) (Kind, error) {
+	if !isDigits(numero) {
+		return "", ErrNotNumeric
+	}
+	switch len(numero) {
+	case 10:
+		if err := ValidateCedula(numero); err != nil {
+			return "", err
+		}
+		return KindCedula, nil
+	case 13:
+		if numero == ConsumidorFinal {
+			return KindConsumidorFinal, nil
+		}
+		return ValidateRUC(numero)
+	default:
+		return "", ErrLength
+	}
+}
+
+// ValidateCedula valida provincia (01-24, 30 para ecuatorianos en el exterior), tercer dígito
+// menor a 6 y el dígito verificador módulo 10
+func ValidateCedula(numero string) error {
+	if !isDigits(numero) {
+		return ErrNotNumeric
+	}
+	if len(numero) != 10 {
+		return ErrCedulaLength
+	}
+	if !validProvince(numero) {
+		return ErrProvince
+	}
+	if numero[2] >= '6' {
+		return ErrThirdDigit
+	}
+
+	sum := 0
+	for i, coefficient := range cedulaCoefficients {
+		product := digit(numero, i) * coefficient
+		if product > 9 {
+			product -= 9
+		}
+		sum += product
+	}
+	if (10-sum%10)%10 != digit(numero, 9) {
+		return ErrCheckDigit
+	}
+	return nil
+}
+
+// ValidateRUC valida un RUC de 13 dígitos según el tercer dígito:
+// 0-5 persona natural (cédula + establecimiento), 6 sociedad pública, 9 sociedad privada
+func ValidateRUC(numero string) (Kind, error) {
+	if !isDigits(numero) {
+		return "", ErrNotNumeric
+	}
+	if len(numero) != 13 {
+		return "", ErrRUCLength
+	}
+	if !validProvince(numero) {
+		return "", ErrProvince
+	}
+
+	switch third := numero[2]; {
+	case third < '6':
+		if err := ValidateCedula(numero[:10]); err != nil {
+			return "", err
+		}
+		if numero[10:] == "000" {
+			return "", ErrEstablishment
+		}
+		return KindRUCNatural, nil
+
+	case third == '6':
+		if !validModulo11(numero, publicaCoefficients) {
+			return "", ErrCheckDigit
+		}
+		if numero[9:] == "0000" {
+			return "", ErrEstablishment
+		}
+		return KindRUCPublica, nil
+
+	case third == '9':
+		if !validModulo11(numero, privadaCoefficients) {
+			return "", ErrCheckDigit
+		}
+		if numero[10:] == "000" {
+			return "", ErrEstablishment
+		}
+		return KindRUCPrivada, nil
+
+	default:
+		return "", ErrThirdDigit
+	}
+}
+
+// validModulo11 el verificador sigue a los dígitos ponderados: 11 - (suma % 11), donde 11 vale 0
+// y 10 no es un resultado válido
+func validModulo11(numero string, coefficients []int) bool {
+	sum := 0
+	for i, coefficient := range coefficients {
+		sum += digit(numero, i) * coefficient
+	}
+	check := 11 - sum%11
+	if check == 11 {
+		check = 0
+	}
+	return check != 10 && check == digit(numero, len(coefficients))
+}
+
+func validProvince(numero string) bool {
+	province := digit(numero, 0)*10 + digit(numero, 1)
+	return (province >= 1 && province <= 24) || province == 30
+}
+
+func digit(numero string, i int) int {
+	return int(numero[i] - '0')
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	return strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' }) == -1
+}
@@ -0,0 +1,113 @@
+package identification
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	// Con tipo vacío se usa Detect, que deduce la clase por la longitud del número
+	tests := []struct {
+		name    string
+		tipo    string
+		numero  string
+		want    Kind
+		wantErr error
+	}{
+		// Cédula (módulo 10)
+		{name: "cedula valid", tipo: TypeCedula, numero: "1710034065", want: KindCedula},
+		{name: "cedula valid check digit zero", tipo: TypeCedula, numero: "0912345675", want: KindCedula},
+		{name: "cedula province 24", tipo: TypeCedula, numero: "2412345676", want: KindCedula},
+		{name: "cedula province 30 (abroad)", tipo: TypeCedula, numero: "3012345678", want: KindCedula},
+		{name: "cedula invalid check digit", tipo: TypeCedula, numero: "1710034066", wantErr: ErrCheckDigit},
+		{name: "cedula province 00", tipo: TypeCedula, numero: "0012345678", wantErr: ErrProvince},
+		{name: "cedula province 25", tipo: TypeCedula, numero: "2512345678", wantErr: ErrProvince},
+		{name: "cedula province 29", tipo: TypeCedula, numero: "2912345678", wantErr: ErrProvince},
+		{name: "cedula third digit 6", tipo: TypeCedula, numero: "1760012320", wantErr: ErrThirdDigit},
+		{name: "cedula too short", tipo: TypeCedula, numero: "171003406", wantErr: ErrCedulaLength},
+		{name: "cedula too long", tipo: TypeCedula, numero: "17100340651", wantErr: ErrCedulaLength},
+		{name: "cedula with letters", tipo: TypeCedula, numero: "17100340A5", wantErr: ErrNotNumeric},
+		{name: "cedula with dash", tipo: TypeCedula, numero: "171003406-5", wantErr: ErrNotNumeric},
+		{name: "cedula empty", tipo: TypeCedula, numero: "", wantErr: ErrNotNumeric},
+
+		// RUC de persona natural: cédula + establecimiento
+		{name: "ruc natural valid", tipo: TypeRUC, numero: "1710034065001", want: KindRUCNatural},
+		{name: "ruc natural second establishment", tipo: TypeRUC, numero: "1710034065002", want: KindRUCNatural},
+		{name: "ruc natural invalid cedula", tipo: TypeRUC, numero: "1710034066001", wantErr: ErrCheckDigit},
+		{name: "ruc natural establishment 000", tipo: TypeRUC, numero: "1710034065000", wantErr: ErrEstablishment},
+
+		// RUC de sociedad privada: tercer dígito 9, módulo 11
+		{name: "ruc private valid", tipo: TypeRUC, numero: "1790012344001", want: KindRUCPrivada},
+		{name: "ruc private valid Guayas", tipo: TypeRUC, numero: "0990123454001", want: KindRUCPrivada},
+		{name: "ruc private invalid check digit", tipo: TypeRUC, numero: "1790012345001", wantErr: ErrCheckDigit},
+		{name: "ruc private establishment 000", tipo: TypeRUC, numero: "1790012344000", wantErr: ErrEstablishment},
+
+		// RUC de sociedad pública: tercer dígito 6, módulo 11 sobre 8 dígitos
+		{name: "ruc public valid", tipo: TypeRUC, numero: "1760012320001", want: KindRUCPublica},
+		{name: "ruc public invalid check digit", tipo: TypeRUC, numero: "1760012330001", wantErr: ErrCheckDigit},
+		{name: "ruc public establishment 0000", tipo: TypeRUC, numero: "1760012320000", wantErr: ErrEstablishment},
+
+		// Reglas comunes del RUC
+		{name: "ruc third digit 7", tipo: TypeRUC, numero: "1770012344001", wantErr: ErrThirdDigit},
+		{name: "ruc third digit 8", tipo: TypeRUC, numero: "1780012344001", wantErr: ErrThirdDigit},
+		{name: "ruc province 00", tipo: TypeRUC, numero: "0090012344001", wantErr: ErrProvince},
+		{name: "ruc too short", tipo: TypeRUC, numero: "1710034065", wantErr: ErrRUCLength},
+		{name: "ruc with letters", tipo: TypeRUC, numero: "17100340650O1", wantErr: ErrNotNumeric},
+
+		// Consumidor final
+		{name: "final consumer as type 07", tipo: TypeExterior, numero: ConsumidorFinal, want: KindConsumidorFinal},
+		{name: "final consumer as RUC", tipo: TypeRUC, numero: ConsumidorFinal, wantErr: ErrConsumidorFinal},
+		{name: "final consumer as cedula", tipo: TypeCedula, numero: ConsumidorFinal, wantErr: ErrCedulaLength},
+
+		// Pasaporte (06) e identificación del exterior (07)
+		{name: "passport valid", tipo: TypePasaporte, numero: "A1234567", want: KindPasaporte},
+		{name: "passport minimum length", tipo: TypePasaporte, numero: "AB123", want: KindPasaporte},
+		{name: "passport maximum length", tipo: TypePasaporte, numero: "ABCDEFGHIJ1234567890", want: KindPasaporte},
+		{name: "passport too short", tipo: TypePasaporte, numero: "A123", wantErr: ErrPasaporte},
+		{name: "passport too long", tipo: TypePasaporte, numero: "ABCDEFGHIJ12345678901", wantErr: ErrPasaporte},
+		{name: "passport with dash", tipo: TypePasaporte, numero: "AB-12345", wantErr: ErrPasaporte},
+		{name: "passport with space", tipo: TypePasaporte, numero: "AB 12345", wantErr: ErrPasaporte},
+		{name: "foreign id valid", tipo: TypeExterior, numero: "X-12345-Z", want: KindExterior},
+		{name: "foreign id maximum length", tipo: TypeExterior, numero: "ABCDEFGHIJ-1234567890-XYZ", want: KindExterior},
+		{name: "foreign id too short", tipo: TypeExterior, numero: "X-12", wantErr: ErrExterior},
+		{name: "foreign id too long", tipo: TypeExterior, numero: "ABCDEFGHIJ-1234567890-XYZW", wantErr: ErrExterior},
+		{name: "foreign id with symbols", tipo: TypeExterior, numero: "X/12345", wantErr: ErrExterior},
+
+		// Tipo desconocido
+		{name: "unknown type", tipo: "99", numero: "1710034065", wantErr: ErrUnknownType},
+
+		// Detección por longitud
+		{name: "detect cedula", numero: "1710034065", want: KindCedula},
+		{name: "detect ruc", numero: "1790012344001", want: KindRUCPrivada},
+		{name: "detect final consumer", numero: ConsumidorFinal, want: KindConsumidorFinal},
+		{name: "detect invalid cedula", numero: "1710034066", wantErr: ErrCheckDigit},
+		{name: "detect wrong length", numero: "17100340651", wantErr: ErrLength},
+		{name: "detect non-numeric", numero: "A1234567", wantErr: ErrNotNumeric},
+		{name: "detect empty", numero: "", wantErr: ErrNotNumeric},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Kind
+			var err error
+			if tt.tipo == "" {
+				got, err = Detect(tt.numero)
+			} else {
+				got, err = Validate(tt.tipo, tt.numero)
+			}
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("kind = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
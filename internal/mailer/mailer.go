@@ -0,0 +1,157 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"megabaseGo/internal/config"
+)
+
+// Message correo a enviar; Body es texto plano
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer abstrae el envío de correos para poder cambiar SMTP por archivo o consola
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New construye el Mailer indicado por MAIL_DRIVER
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "smtp":
+		return NewSMTPMailer(cfg), nil
+	case "file":
+		return NewFileMailer(cfg.FileDir, cfg.From), nil
+	case "console", "":
+		return NewConsoleMailer(os.Stdout, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("mail driver desconocido: %s", cfg.Driver)
+	}
+}
+
+var (
+	defaultMailer Mailer
+	defaultOnce   sync.Once
+)
+
+// Default devuelve el Mailer de la configuración actual; si el driver es inválido usa consola
+func Default() Mailer {
+	defaultOnce.Do(func() {
+		cfg := config.Get().Mail
+		m, err := New(cfg)
+		if err != nil {
+			m = NewConsoleMailer(os.Stdout, cfg.From)
+		}
+		defaultMailer = m
+	})
+	return defaultMailer
+}
+
+// SMTPMailer envía correos por SMTP con autenticación PLAIN si hay usuario
+type SMTPMailer struct {
+	addr     string
+	host     string
+	from     string
+	user     string
+	password string
+}
+
+// NewSMTPMailer crea un SMTPMailer
+func NewSMTPMailer(cfg config.MailConfig) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+		host:     cfg.SMTPHost,
+		from:     cfg.From,
+		user:     cfg.SMTPUser,
+		password: cfg.SMTPPassword,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.user != "" {
+		auth = smtp.PlainAuth("", m.user, m.password, m.host)
+	}
+	return smtp.SendMail(m.addr, auth, m.from, []string{msg.To}, buildMessage(m.from, msg))
+}
+
+// FileMailer guarda cada correo como un archivo .eml; útil en desarrollo y pruebas
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer crea un FileMailer que escribe en dir
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), sanitizeFileName(msg.To))
+	return os.WriteFile(filepath.Join(m.dir, name), buildMessage(m.from, msg), 0o600)
+}
+
+// ConsoleMailer escribe los correos en un io.Writer (por defecto stdout)
+type ConsoleMailer struct {
+	mu   sync.Mutex
+	out  io.Writer
+	from string
+}
+
+// NewConsoleMailer crea un ConsoleMailer
+func NewConsoleMailer(out io.Writer, from string) *ConsoleMailer {
+	return &ConsoleMailer{out: out, from: from}
+}
+
+func (m *ConsoleMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.out, "----- MAIL -----\n%s\n----------------\n", buildMessage(m.from, msg))
+	return err
+}
+
+// buildMessage arma el correo en formato RFC 5322
+func buildMessage(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+// headerValue quita saltos de línea para evitar inyección de cabeceras
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+func sanitizeFileName(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+}
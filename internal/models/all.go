@@ -2,8 +2,20 @@ package models
 
 // AllModels contiene todos los modelos para migración dinámica
 var AllModels = []interface{}{        
+    &Permission{},
     &Role{},
     &User{},
     &Citizen{},
     &Company{},
+    &Session{},
+    &RevokedToken{},
+    &RecoveryCode{},
+    &UserToken{},
+    &PasswordHistory{},
+    &APIKey{},
+    &AuditLog{},
+    &Invitation{},
+    &UserIdentity{},
+    &OIDCLoginState{},
+    &LoginEvent{},
 }
\ No newline at end of file
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AuditLog registro de auditoría de acciones sensibles.
+// En suplantación ActorID es quien actúa realmente y UserID el usuario suplantado.
+type AuditLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ActorID    uint      `gorm:"not null;index" json:"actor_id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Action     string    `gorm:"size:50;not null;index" json:"action"`
+	Method     string    `gorm:"size:10" json:"method,omitempty"`
+	Path       string    `gorm:"size:255" json:"path,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"`
+	TokenID    string    `gorm:"size:64;index" json:"token_id,omitempty"` // jti del token de suplantación
+	IPAddress  string    `gorm:"size:45" json:"ip_address"`
+	UserAgent  string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt  time.Time `gorm:"not null;index" json:"created_at"`
+}
@@ -11,7 +11,12 @@ import (
 // registrada en el sistema, principalmente para fines fiscales y de facturación.
 type Citizen struct {
 	// --- CAMPOS DE AUDITORÍA (GORM) ---
-	gorm.Model // Incluye ID, CreatedAt, UpdatedAt, DeletedAt
+	// Equivalentes a gorm.Model, declarados a mano para indexarlos: (created_at, id) es la clave
+	// estable de la paginación por cursor y el índice parcial excluye los registros borrados.
+	ID        uint           `gorm:"primarykey;index:idx_citizens_created_at_id,priority:2,where:deleted_at IS NULL"`
+	CreatedAt time.Time      `gorm:"index:idx_citizens_created_at_id,priority:1,where:deleted_at IS NULL"`
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 
 	// --- 1. IDENTIFICACIÓN PRINCIPAL (Ambos tipos) ---
 	// Es el campo más importante. Se recomienda un tamaño más ajustado.
@@ -64,4 +69,15 @@ type Citizen struct {
 	// --- 6. METADATOS ADICIONALES ---
 	// Corregido typo: "suspencion" a "suspension"
 	MotivoCancelacionSuspension string `gorm:"size:250" json:"motivo_cancelacion_suspension,omitempty"`
+
+	// --- 7. PROPIEDAD Y ALCANCE ---
+	// Usuarios que crearon y modificaron por última vez el registro (nil si vino de la consulta pública)
+	CreatedBy *uint `gorm:"index" json:"created_by,omitempty"`
+	UpdatedBy *uint `json:"updated_by,omitempty"`
+	// Compañía dueña del registro; sus usuarios lo ven aunque no lo hayan creado
+	CompanyID *uint    `gorm:"index" json:"company_id,omitempty"`
+	Company   *Company `gorm:"foreignKey:CompanyID" json:"-"`
+
+	// La tabla tiene además las columnas generadas search_vector y search_text para la búsqueda
+	// de texto; no se mapean aquí porque las crea database.MigrateCitizenSearch, no AutoMigrate.
 }
\ No newline at end of file
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Invitation invitación para crear una cuenta con un rol asignado por un administrador.
+// El enlace enviado por correo lleva un token firmado del que solo se guarda el SHA-256;
+// al reenviar se rota y el enlace anterior deja de servir.
+type Invitation struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	Email          string     `gorm:"size:255;not null;index" json:"email"`
+	RoleID         uint       `gorm:"not null;index" json:"role_id"`
+	Role           Role       `gorm:"foreignKey:RoleID" json:"-"`
+	CompanyID      *uint      `gorm:"index" json:"company_id"`
+	InvitedBy      uint       `gorm:"not null;index" json:"invited_by"`
+	Inviter        User       `gorm:"foreignKey:InvitedBy" json:"-"`
+	TokenHash      string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt      time.Time  `gorm:"not null;index" json:"expires_at"`
+	SentCount      int        `gorm:"not null;default:0" json:"sent_count"`
+	LastSentAt     *time.Time `json:"last_sent_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+	AcceptedUserID *uint      `json:"accepted_user_id"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"not null" json:"updated_at"`
+}
+
+// Estados de una invitación
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Status estado actual de la invitación
+func (i *Invitation) Status() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationAccepted
+	case i.RevokedAt != nil:
+		return InvitationRevoked
+	case time.Now().After(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Motivos de los intentos de login fallidos
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureThrottled          = "throttled"
+	LoginFailureLocked             = "locked"
+	LoginFailureDisabled           = "account_disabled"
+	LoginFailureEmailUnverified    = "email_unverified"
+	LoginFailureMFA                = "mfa_invalid"
+	LoginFailureExternal           = "external_rejected"
+)
+
+// LoginEvent intento de inicio de sesión, exitoso o fallido. UserID es nulo cuando el
+// username no corresponde a ninguna cuenta; UserName guarda lo que se intentó.
+type LoginEvent struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	UserID        *uint     `gorm:"index" json:"user_id"`
+	UserName      string    `gorm:"size:255" json:"user_name"`
+	Success       bool      `gorm:"not null" json:"success"`
+	Method        string    `gorm:"size:100" json:"method"` // métodos usados, ej. "pwd,otp" u "oidc"
+	FailureReason string    `gorm:"size:50" json:"failure_reason,omitempty"`
+	IPAddress     string    `gorm:"size:45;index" json:"ip_address"`
+	UserAgent     string    `gorm:"size:255" json:"user_agent"`
+	SessionID     string    `gorm:"size:64" json:"-"` // familia de la sesión abierta por el login
+	CreatedAt     time.Time `gorm:"not null;index" json:"created_at"`
+}
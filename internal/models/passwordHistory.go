@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// PasswordHistory hashes de contraseñas anteriores del usuario, para impedir reutilizarlas
+type PasswordHistory struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	PasswordHash string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt    time.Time `gorm:"not null" json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Permission permiso granular con formato "<recurso>:<acción>", ej. "citizens:read", "users:delete"
+type Permission struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"not null" json:"updated_at"`
+	Roles       []Role    `gorm:"many2many:role_permissions;" json:"-"`
+}
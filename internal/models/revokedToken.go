@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// RevokedToken entrada de la lista de revocación de access tokens.
+// Con SessionID revoca los tokens emitidos por esa sesión; si JTI y SessionID están vacíos revoca
+// todos los tokens del usuario emitidos antes de IssuedBefore.
+// ExpiresAt marca cuándo la entrada deja de ser necesaria y puede purgarse.
+type RevokedToken struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	JTI          string     `gorm:"size:64;index" json:"jti,omitempty"`
+	SessionID    string     `gorm:"size:64;index" json:"session_id,omitempty"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	IssuedBefore *time.Time `json:"issued_before,omitempty"`
+	Reason       string     `gorm:"size:100" json:"reason"`
+	ExpiresAt    time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time  `gorm:"not null" json:"created_at"`
+}
@@ -6,14 +6,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// Roles creados por el seeder; son de sistema (IsSystem) y no se pueden renombrar ni eliminar
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Role rol de usuario. Hereda los permisos de su rol padre (ParentID) y de los ancestros de éste.
 type Role struct {
 	gorm.Model
 	Name          string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
 	DisplayName   string    `gorm:"size:100;not null" json:"display_name"`
 	Description   string    `gorm:"type:text" json:"description"`
 	IsActive      bool      `gorm:"not null;default:true" json:"is_active"`
+	RequireMFA    bool      `gorm:"not null;default:false" json:"require_mfa"`
+	IsSystem      bool      `gorm:"not null;default:false" json:"is_system"`
+	ParentID      *uint     `gorm:"index" json:"parent_id"`
+	Parent        *Role     `gorm:"foreignKey:ParentID" json:"-"`
 	CreatedAt     time.Time `gorm:"not null" json:"created_at"`
 	UpdatedAt     time.Time `gorm:"not null" json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 	Users         []User    `gorm:"foreignKey:RoleID" json:"-"`
+	Permissions   []Permission `gorm:"many2many:role_permissions;" json:"permissions,omitempty"`
 }
\ No newline at end of file
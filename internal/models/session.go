@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session representa una sesión de refresh token por dispositivo.
+// Cada login abre una familia (FamilyID); cada refresh rota CurrentJTI dentro de la misma familia.
+// Si se presenta un JTI que ya fue rotado, la familia completa se revoca.
+type Session struct {
+	gorm.Model
+	UserID        uint       `gorm:"not null;index" json:"user_id"`
+	User          User       `gorm:"foreignKey:UserID" json:"-"`
+	FamilyID      string     `gorm:"size:64;not null;uniqueIndex" json:"family_id"`
+	CurrentJTI    string     `gorm:"size:64;not null" json:"-"`
+	IPAddress     string     `gorm:"size:45" json:"ip_address"`
+	UserAgent     string     `gorm:"size:255" json:"user_agent"`
+	AuthMethods   string     `gorm:"size:100" json:"auth_methods"` // métodos usados al iniciar sesión, ej. "pwd,otp"
+	ExpiresAt     time.Time  `gorm:"not null;index" json:"expires_at"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `gorm:"size:100" json:"revoked_reason,omitempty"`
+}
+
+// IsActive indica si la sesión sigue vigente (no revocada ni expirada)
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
@@ -14,9 +14,17 @@ type User struct {
 	Password      string    `gorm:"size:255;not null" json:"-"`
 	RoleID        uint      `gorm:"not null" json:"role_id"`
 	Role          Role      `gorm:"foreignKey:RoleID" json:"role"`
+	CompanyID     *uint     `gorm:"index" json:"company_id"` // compañía a la que pertenece; define el alcance de sus datos
+	Company       *Company  `gorm:"foreignKey:CompanyID" json:"company,omitempty"`
 	RememberToken string    `gorm:"size:100;uniqueIndex" json:"-"`
 	IsActive      bool      `gorm:"not null;default:true" json:"is_active"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 	LastLoginAt   time.Time `json:"last_login_at"`
+	FailedLoginAttempts int        `gorm:"not null;default:0" json:"failed_login_attempts"`
+	LockedUntil         *time.Time `json:"locked_until"`
+	TOTPSecret    string    `gorm:"size:64" json:"-"`
+	TOTPEnabled   bool      `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastStep  int64     `gorm:"not null;default:0" json:"-"` // último paso TOTP aceptado, evita reutilizar un código
 	CreatedAt     time.Time `gorm:"not null" json:"created_at"`
 	UpdatedAt     time.Time `gorm:"not null" json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// UserIdentity vínculo entre un usuario local y su identidad en un proveedor externo (OIDC o LDAP).
+// Subject es el claim sub del proveedor OIDC o el username del directorio, estable aunque cambie el email.
+type UserIdentity struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID" json:"-"`
+	Provider    string     `gorm:"size:50;not null;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_user_identities_provider_subject" json:"subject"`
+	Email       string     `gorm:"size:255" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"not null" json:"updated_at"`
+}
+
+// OIDCLoginState login OIDC en curso, entre la redirección al proveedor y el callback.
+// Se consume en el callback; solo se guarda el hash del state, que además viaja en una cookie.
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primarykey"`
+	StateHash    string    `gorm:"size:64;not null;uniqueIndex"`
+	Provider     string    `gorm:"size:50;not null"`
+	Nonce        string    `gorm:"size:100;not null"`
+	CodeVerifier string    `gorm:"size:100;not null"`
+	ReturnTo     string    `gorm:"size:255"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time `gorm:"not null"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Propósitos de UserToken
+const (
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenEmailVerification = "email_verification"
+)
+
+// UserToken token de un solo uso enviado por correo (recuperación de contraseña, verificación de email).
+// Solo se guarda el hash SHA-256; Stamp es el RememberToken del usuario al emitirlo y,
+// al rotarlo, todos los tokens pendientes quedan invalidados.
+type UserToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"size:30;not null;index" json:"purpose"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Stamp     string     `gorm:"size:100;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+}
@@ -0,0 +1,236 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"megabaseGo/internal/oidc"
+	"megabaseGo/internal/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	t.Helper()
+
+	server := oidctest.NewServer("megabase", "secret")
+	t.Cleanup(server.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       server.Issuer(),
+		ClientID:     "megabase",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+	}, server.Client())
+	return server, provider
+}
+
+func TestExchangeRequiresPKCEVerifier(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		verifier func(original string) string
+		wantErr  bool
+	}{
+		{name: "matching verifier", verifier: func(v string) string { return v }},
+		{name: "different verifier", verifier: func(string) string { return "another-verifier" }, wantErr: true},
+		{name: "missing verifier", verifier: func(string) string { return "" }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, provider := newTestProvider(t)
+
+			verifier, err := oidc.NewRandomToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+			if err != nil {
+				t.Fatalf("AuthCodeURL: %v", err)
+			}
+			if !strings.Contains(authURL, "code_challenge="+oidc.CodeChallengeS256(verifier)) {
+				t.Fatalf("authorization URL has no S256 challenge: %s", authURL)
+			}
+			code, _, err := server.Authorize(authURL, nil)
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+
+			tokens, err := provider.Exchange(ctx, code, tt.verifier(verifier))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+					t.Fatalf("expected invalid_grant, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if _, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce"); err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+		})
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	server, provider := newTestProvider(t)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := server.Authorize(authURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.Exchange(ctx, code, "verifier"); err != nil {
+		t.Fatalf("first exchange: %v", err)
+	}
+	if _, err := provider.Exchange(ctx, code, "verifier"); err == nil {
+		t.Fatal("expected the second exchange of the same code to fail")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		nonce   string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			claims: jwt.MapClaims{"nonce": "n-1", "email": "ana@example.com", "email_verified": true},
+			nonce:  "n-1",
+		},
+		{
+			name:    "nonce mismatch",
+			claims:  jwt.MapClaims{"nonce": "n-1"},
+			nonce:   "n-2",
+			wantErr: true,
+		},
+		{
+			name:    "missing nonce",
+			claims:  jwt.MapClaims{},
+			nonce:   "n-1",
+			wantErr: true,
+		},
+		{
+			name:    "wrong issuer",
+			claims:  jwt.MapClaims{"nonce": "n-1", "iss": "https://evil.example.com"},
+			nonce:   "n-1",
+			wantErr: true,
+		},
+		{
+			name:    "wrong audience",
+			claims:  jwt.MapClaims{"nonce": "n-1", "aud": "other-client"},
+			nonce:   "n-1",
+			wantErr: true,
+		},
+		{
+			name:    "several audiences without azp",
+			claims:  jwt.MapClaims{"nonce": "n-1", "aud": []string{"megabase", "other-client"}},
+			nonce:   "n-1",
+			wantErr: true,
+		},
+		{
+			name:   "several audiences with azp",
+			claims: jwt.MapClaims{"nonce": "n-1", "aud": []string{"megabase", "other-client"}, "azp": "megabase"},
+			nonce:  "n-1",
+		},
+		{
+			name:   "expired within clock skew",
+			claims: jwt.MapClaims{"nonce": "n-1", "exp": now.Add(-30 * time.Second).Unix()},
+			nonce:  "n-1",
+		},
+		{
+			name:    "expired",
+			claims:  jwt.MapClaims{"nonce": "n-1", "iat": now.Add(-time.Hour).Unix(), "exp": now.Add(-10 * time.Minute).Unix()},
+			nonce:   "n-1",
+			wantErr: true,
+		},
+		{
+			name:    "missing expiry",
+			claims:  jwt.MapClaims{"nonce": "n-1", "exp": nil},
+			nonce:   "n-1",
+			wantErr: true,
+		},
+		{
+			name:    "missing subject",
+			claims:  jwt.MapClaims{"nonce": "n-1", "sub": nil},
+			nonce:   "n-1",
+			wantErr: true,
+		},
+	}
+
+	server, provider := newTestProvider(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idToken, err := provider.VerifyIDToken(ctx, server.SignIDToken(tt.claims), tt.nonce)
+			if tt.wantErr {
+				if !errors.Is(err, oidc.ErrInvalidIDToken) {
+					t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected token to be accepted, got %v", err)
+			}
+			if idToken.Subject != "oidctest-subject" {
+				t.Fatalf("unexpected subject %q", idToken.Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsForeignSignature(t *testing.T) {
+	ctx := context.Background()
+	_, provider := newTestProvider(t)
+
+	// Mismo kid pero firmado por otro proveedor: la firma no corresponde a la clave publicada
+	other := oidctest.NewServer("megabase", "secret")
+	defer other.Close()
+
+	raw := other.SignIDToken(jwt.MapClaims{"nonce": "n-1"})
+	if _, err := provider.VerifyIDToken(ctx, raw, "n-1"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+	}
+}
+
+func TestIDTokenValues(t *testing.T) {
+	idToken := &oidc.IDToken{Claims: map[string]interface{}{
+		"groups":       []interface{}{"admins", "staff"},
+		"department":   "it",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"auditor"}},
+	}}
+
+	tests := []struct {
+		claim string
+		want  []string
+	}{
+		{claim: "groups", want: []string{"admins", "staff"}},
+		{claim: "department", want: []string{"it"}},
+		{claim: "realm_access.roles", want: []string{"auditor"}},
+		{claim: "missing", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.claim, func(t *testing.T) {
+			got := idToken.Values(tt.claim)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("Values(%q) = %v, want %v", tt.claim, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"megabaseGo/internal/config"
+	"megabaseGo/internal/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInvalidCursor = errors.New("cursor inválido o de otro recurso u orden; vuelva a pedir la primera página")
+
+// Keyset orden estable de un recurso para la paginación por cursor (?cursor=&limit=). A diferencia
+// de page/page_size no usa OFFSET: cada página continúa desde la última fila de la anterior
+// (WHERE (columna, id) > (valor, id)), así el costo no crece con la profundidad y las filas
+// insertadas durante el recorrido no desplazan a las demás: ninguna fila confirmada antes de empezar
+// el recorrido se salta ni se repite. Una fila confirmada después aparece solo si su clave cae más
+// allá de la última página entregada; una transacción lenta que confirma un ID serial o created_at
+// menor que esa posición queda fuera del recorrido en curso. Las columnas deben ser inmutables y
+// tener un índice compuesto con el ID.
+type Keyset[T any] struct {
+	Resource string // un cursor emitido para un recurso no sirve en otro
+	Fields   map[string]KeysetField[T]
+	Default  string
+	IDColumn string
+	ID       func(*T) uint
+}
+
+// KeysetField columna por la que se puede recorrer con cursor. Value es nil cuando la columna es el propio ID.
+type KeysetField[T any] struct {
+	Column string
+	Value  func(*T) time.Time
+}
+
+// CursorParams límite y posición pedidos en modo cursor
+type CursorParams struct {
+	Limit int
+	Sort  string // campo del keyset, con "-" para descendente
+	field string
+	desc  bool
+	after *cursorPosition
+}
+
+// cursorPosition última fila entregada; viaja firmada dentro del cursor
+type cursorPosition struct {
+	Resource string     `json:"r"`
+	Sort     string     `json:"s"`
+	Time     *time.Time `json:"t,omitempty"`
+	ID       uint       `json:"id"`
+}
+
+// CursorMeta metadatos de una respuesta paginada por cursor
+type CursorMeta struct {
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	HasNext    bool   `json:"has_next"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// IsCursorRequest indica si la petición pide el modo cursor (?cursor= o ?limit=)
+func IsCursorRequest(query url.Values) bool {
+	return query.Has("cursor") || query.Has("limit")
+}
+
+// ParseCursor lee cursor, limit y sort de la query. Con cursor el orden es el del cursor;
+// un sort distinto al de la primera página se rechaza.
+func ParseCursor[T any](query url.Values, keyset Keyset[T]) (*CursorParams, error) {
+	if query.Has("page") || query.Has("page_size") {
+		return nil, errors.New("cursor y limit no se combinan con page ni page_size")
+	}
+
+	params := &CursorParams{Limit: DefaultPageSize}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxPageSize {
+			return nil, fmt.Errorf("limit debe ser un entero entre 1 y %d", MaxPageSize)
+		}
+		params.Limit = limit
+	}
+
+	sortParam := query.Get("sort")
+	if token := query.Get("cursor"); token != "" {
+		position, err := decodeCursor(token)
+		if err != nil || position.Resource != keyset.Resource {
+			return nil, errInvalidCursor
+		}
+		if sortParam != "" && sortParam != position.Sort {
+			return nil, errors.New("sort no puede cambiar mientras se recorre con cursor")
+		}
+		sortParam = position.Sort
+		params.after = position
+	}
+	if sortParam == "" {
+		sortParam = keyset.Default
+	}
+
+	params.desc = strings.HasPrefix(sortParam, "-")
+	params.field = strings.TrimPrefix(sortParam, "-")
+	field, ok := keyset.Fields[params.field]
+	if !ok {
+		return nil, fmt.Errorf("con cursor solo se puede ordenar por uno de: %s", strings.Join(keyset.names(), ", "))
+	}
+	if params.after != nil && (field.Value != nil) != (params.after.Time != nil) {
+		return nil, errInvalidCursor
+	}
+	params.Sort = sortParam
+	return params, nil
+}
+
+// FindAfter carga en dest las filas siguientes a la posición del cursor. Pide una fila de más
+// para saber si hay otra página sin contar la tabla.
+func FindAfter[T any](query *gorm.DB, keyset Keyset[T], params *CursorParams, dest *[]T, preloads ...string) (*CursorMeta, error) {
+	field := keyset.Fields[params.field]
+	idColumn := clause.Column{Name: keyset.IDColumn}
+
+	operator := ">"
+	if params.desc {
+		operator = "<"
+	}
+
+	pageQuery := query.Session(&gorm.Session{})
+	if params.after != nil {
+		if field.Value != nil {
+			pageQuery = pageQuery.Where(clause.Expr{
+				SQL:  "(?, ?) " + operator + " (?, ?)",
+				Vars: []interface{}{clause.Column{Name: field.Column}, idColumn, *params.after.Time, params.after.ID},
+			})
+		} else {
+			pageQuery = pageQuery.Where(clause.Expr{
+				SQL:  "? " + operator + " ?",
+				Vars: []interface{}{idColumn, params.after.ID},
+			})
+		}
+	}
+	if field.Value != nil {
+		pageQuery = pageQuery.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Column}, Desc: params.desc})
+	}
+	pageQuery = pageQuery.Order(clause.OrderByColumn{Column: idColumn, Desc: params.desc}).Limit(params.Limit + 1)
+	for _, preload := range preloads {
+		pageQuery = pageQuery.Preload(preload)
+	}
+	if err := pageQuery.Find(dest).Error; err != nil {
+		return nil, err
+	}
+
+	meta := &CursorMeta{Limit: params.Limit, Sort: params.Sort}
+	if len(*dest) > params.Limit {
+		*dest = (*dest)[:params.Limit]
+		last := &(*dest)[params.Limit-1]
+
+		position := &cursorPosition{Resource: keyset.Resource, Sort: params.Sort, ID: keyset.ID(last)}
+		if field.Value != nil {
+			value := field.Value(last)
+			position.Time = &value
+		}
+		next, err := encodeCursor(position)
+		if err != nil {
+			return nil, err
+		}
+		meta.HasNext = true
+		meta.NextCursor = next
+	}
+	return meta, nil
+}
+
+// LinkHeader cabecera Link (RFC 8288) con first y, si hay más filas, next
+func (m *CursorMeta) LinkHeader(requestURL *url.URL) string {
+	link := func(cursor, rel string) string {
+		query := requestURL.Query()
+		query.Del("cursor")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		} else {
+			query.Set("sort", m.Sort)
+		}
+		query.Set("limit", strconv.Itoa(m.Limit))
+		target := url.URL{Path: requestURL.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel)
+	}
+
+	links := []string{link("", "first")}
+	if m.HasNext {
+		links = append(links, link(m.NextCursor, "next"))
+	}
+	return strings.Join(links, ", ")
+}
+
+func (k Keyset[T]) names() []string {
+	names := make([]string, 0, len(k.Fields))
+	for name := range k.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// encodeCursor serializa la posición y la firma con HMAC-SHA256: payload.firma en base64url
+func encodeCursor(position *cursorPosition) (string, error) {
+	payload, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signCursor(encoded)), nil
+}
+
+// decodeCursor verifica la firma antes de confiar en el contenido
+func decodeCursor(token string) (*cursorPosition, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	expected, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, signCursor(encoded)) {
+		return nil, errInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var position cursorPosition
+	if err := json.Unmarshal(payload, &position); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &position, nil
+}
+
+var (
+	cursorKeyOnce sync.Once
+	cursorKey     []byte
+)
+
+func signCursor(encoded string) []byte {
+	cursorKeyOnce.Do(func() {
+		if secret := config.Get().CursorSecret; secret != "" {
+			cursorKey = []byte(secret)
+			return
+		}
+		// Sin clave configurada los cursores solo valen en este proceso y hasta que se reinicie
+		cursorKey = make([]byte, 32)
+		if _, err := rand.Read(cursorKey); err != nil {
+			panic(err)
+		}
+		logger.Debug.Warn("CURSOR_SECRET y JWT_SECRET vacíos: los cursores de paginación se firman con una clave temporal")
+	})
+
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write([]byte("pagination-cursor:"))
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,275 @@
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("CURSOR_SECRET", "test-cursor-secret")
+	os.Exit(m.Run())
+}
+
+type testItem struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+var testKeyset = Keyset[testItem]{
+	Resource: "items",
+	Fields: map[string]KeysetField[testItem]{
+		"id":         {Column: "id"},
+		"created_at": {Column: "created_at", Value: func(i *testItem) time.Time { return i.CreatedAt }},
+	},
+	Default:  "id",
+	IDColumn: "id",
+	ID:       func(i *testItem) uint { return i.ID },
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		name     string
+		position cursorPosition
+	}{
+		{name: "by id", position: cursorPosition{Resource: "items", Sort: "id", ID: 42}},
+		{name: "by time descending", position: cursorPosition{Resource: "items", Sort: "-created_at", Time: &at, ID: 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := encodeCursor(&tt.position)
+			if err != nil {
+				t.Fatalf("encodeCursor: %v", err)
+			}
+			got, err := decodeCursor(token)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if got.Resource != tt.position.Resource || got.Sort != tt.position.Sort || got.ID != tt.position.ID {
+				t.Fatalf("decoded %+v, want %+v", got, tt.position)
+			}
+			if (got.Time == nil) != (tt.position.Time == nil) || (got.Time != nil && !got.Time.Equal(*tt.position.Time)) {
+				t.Fatalf("decoded time %v, want %v", got.Time, tt.position.Time)
+			}
+
+			// El cursor decodificado vuelve a ser aceptado por ParseCursor con el mismo orden
+			params, err := ParseCursor(url.Values{"cursor": {token}}, testKeyset)
+			if err != nil {
+				t.Fatalf("ParseCursor: %v", err)
+			}
+			if params.Sort != tt.position.Sort {
+				t.Fatalf("sort = %q, want %q", params.Sort, tt.position.Sort)
+			}
+		})
+	}
+}
+
+func TestParseCursorRejectsInvalidCursor(t *testing.T) {
+	at := time.Now().UTC()
+	sign := func(position cursorPosition) string {
+		token, err := encodeCursor(&position)
+		if err != nil {
+			t.Fatalf("encodeCursor: %v", err)
+		}
+		return token
+	}
+	valid := sign(cursorPosition{Resource: "items", Sort: "created_at", Time: &at, ID: 5})
+	payload, signature, _ := strings.Cut(valid, ".")
+	otherPayload, _, _ := strings.Cut(sign(cursorPosition{Resource: "items", Sort: "created_at", Time: &at, ID: 500}), ".")
+
+	tests := []struct {
+		name    string
+		query   url.Values
+		wantErr error // nil: basta con que falle
+	}{
+		{name: "payload swapped under the same signature", query: url.Values{"cursor": {otherPayload + "." + signature}}, wantErr: errInvalidCursor},
+		{name: "signature altered", query: url.Values{"cursor": {payload + "." + strings.Repeat("A", len(signature))}}, wantErr: errInvalidCursor},
+		{name: "signature missing", query: url.Values{"cursor": {payload}}, wantErr: errInvalidCursor},
+		{name: "not base64", query: url.Values{"cursor": {"%%%." + signature}}, wantErr: errInvalidCursor},
+		{name: "cursor from another resource", query: url.Values{"cursor": {sign(cursorPosition{Resource: "users", Sort: "created_at", Time: &at, ID: 5})}}, wantErr: errInvalidCursor},
+		{name: "cursor from another sort", query: url.Values{"cursor": {valid}, "sort": {"-created_at"}}},
+		{name: "id cursor carrying a time", query: url.Values{"cursor": {sign(cursorPosition{Resource: "items", Sort: "id", Time: &at, ID: 5})}}, wantErr: errInvalidCursor},
+		{name: "time cursor without a time", query: url.Values{"cursor": {sign(cursorPosition{Resource: "items", Sort: "created_at", ID: 5})}}, wantErr: errInvalidCursor},
+		{name: "cursor combined with page", query: url.Values{"cursor": {valid}, "page": {"2"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCursor(tt.query, testKeyset)
+			if err == nil {
+				t.Fatal("expected the cursor to be rejected")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFindAfterWithInsertsBetweenPages(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, sortParam := range []string{"id", "-id", "created_at", "-created_at"} {
+		t.Run(sortParam, func(t *testing.T) {
+			db := setupCursorTestDB(t)
+			existing := seedTestItems(t, db, base, 10)
+
+			seen := walkCursor(t, db, sortParam, 3, func(page int) {
+				// Inserción entre páginas: no debe desplazar a las filas que ya existían
+				if err := db.Create(&testItem{CreatedAt: base.Add(time.Hour + time.Duration(page)*time.Minute)}).Error; err != nil {
+					t.Fatalf("inserting between pages: %v", err)
+				}
+			})
+			assertWalk(t, existing, seen)
+		})
+	}
+}
+
+func TestFindAfterWithConcurrentInserts(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	const writers, insertsPerWriter = 4, 25
+
+	for _, sortParam := range []string{"id", "-id", "created_at", "-created_at"} {
+		t.Run(sortParam, func(t *testing.T) {
+			db := setupCursorTestDB(t)
+			// SQLite en memoria admite un solo escritor: las altas se serializan en la conexión,
+			// pero se intercalan libremente con las páginas del recorrido
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatalf("getting sql.DB: %v", err)
+			}
+			sqlDB.SetMaxOpenConns(1)
+
+			existing := seedTestItems(t, db, base, 40)
+
+			var inserted atomic.Int64
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					<-start
+					for i := 0; i < insertsPerWriter; i++ {
+						// La mitad llega con created_at posterior; la otra mitad con uno anterior,
+						// como una transacción lenta que confirma tarde
+						createdAt := base.Add(time.Hour + time.Duration(i)*time.Second)
+						if i%2 == 1 {
+							createdAt = base.Add(time.Duration(i%20) * time.Minute)
+						}
+						if err := db.Create(&testItem{CreatedAt: createdAt}).Error; err != nil {
+							t.Errorf("writer %d: %v", w, err)
+							return
+						}
+						inserted.Add(1)
+						runtime.Gosched()
+					}
+				}(w)
+			}
+
+			close(start)
+			var duringWalk int64
+			seen := walkCursor(t, db, sortParam, 4, func(page int) {
+				time.Sleep(time.Millisecond)
+				duringWalk = inserted.Load()
+			})
+			wg.Wait()
+
+			if duringWalk == 0 {
+				t.Fatal("no rows were inserted while walking; the test did not exercise concurrency")
+			}
+			assertWalk(t, existing, seen)
+		})
+	}
+}
+
+// seedTestItems crea n filas; cada dos comparten created_at para que el desempate por ID entre en juego
+func seedTestItems(t *testing.T, db *gorm.DB, base time.Time, n int) []uint {
+	t.Helper()
+	ids := make([]uint, 0, n)
+	for i := 0; i < n; i++ {
+		item := testItem{CreatedAt: base.Add(time.Duration(i/2) * time.Minute)}
+		if err := db.Create(&item).Error; err != nil {
+			t.Fatalf("creating item: %v", err)
+		}
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+// walkCursor recorre todas las páginas con el orden indicado y cuenta cuántas veces sale cada fila.
+// betweenPages se llama después de cada página que tiene siguiente.
+func walkCursor(t *testing.T, db *gorm.DB, sortParam string, limit int, betweenPages func(page int)) map[uint]int {
+	t.Helper()
+	seen := make(map[uint]int)
+	query := url.Values{"limit": {strconv.Itoa(limit)}, "sort": {sortParam}}
+	for page := 0; ; page++ {
+		if page > 200 {
+			t.Fatal("the walk did not finish")
+		}
+		params, err := ParseCursor(query, testKeyset)
+		if err != nil {
+			t.Fatalf("ParseCursor: %v", err)
+		}
+		var items []testItem
+		meta, err := FindAfter(db.Model(&testItem{}), testKeyset, params, &items)
+		if err != nil {
+			t.Fatalf("FindAfter: %v", err)
+		}
+		for _, item := range items {
+			seen[item.ID]++
+		}
+		if !meta.HasNext {
+			return seen
+		}
+		betweenPages(page)
+		query = url.Values{"cursor": {meta.NextCursor}, "limit": {strconv.Itoa(limit)}}
+	}
+}
+
+// assertWalk exige que cada fila previa al recorrido salga una vez y que ninguna se repita
+func assertWalk(t *testing.T, existing []uint, seen map[uint]int) {
+	t.Helper()
+	for _, id := range existing {
+		if seen[id] != 1 {
+			t.Errorf("row %d seen %d times, want 1", id, seen[id])
+		}
+	}
+	for id, count := range seen {
+		if count > 1 {
+			t.Errorf("row %d repeated %d times", id, count)
+		}
+	}
+}
+
+func setupCursorTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.NewReplacer("/", "_", "-", "_").Replace(t.Name()))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	if err := db.AutoMigrate(&testItem{}); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -0,0 +1,69 @@
+package pagination
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Linker metadatos de paginación que saben armar su cabecera Link (Meta y CursorMeta)
+type Linker interface {
+	LinkHeader(requestURL *url.URL) string
+}
+
+// Meta metadatos de una respuesta paginada
+type Meta struct {
+	Page       int    `json:"page"`
+	PageSize   int    `json:"page_size"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+	Sort       string `json:"sort,omitempty"`
+}
+
+// NewMeta calcula los metadatos para la página pedida y el total de registros
+func NewMeta(params *Params, total int64) *Meta {
+	totalPages := int((total + int64(params.PageSize) - 1) / int64(params.PageSize))
+	return &Meta{
+		Page:       params.Page,
+		PageSize:   params.PageSize,
+		Total:      total,
+		TotalPages: totalPages,
+		HasNext:    params.Page < totalPages,
+		HasPrev:    params.Page > 1,
+		Sort:       params.SortString(),
+	}
+}
+
+// LinkHeader cabecera Link (RFC 8288) con first, prev, next y last. Las URLs son relativas a la
+// petición y conservan sus filtros; solo cambia page.
+func (m *Meta) LinkHeader(requestURL *url.URL) string {
+	var links []string
+	add := func(page int, rel string) {
+		query := requestURL.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("page_size", strconv.Itoa(m.PageSize))
+		target := url.URL{Path: requestURL.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, target.String(), rel))
+	}
+
+	lastPage := m.TotalPages
+	if lastPage < 1 {
+		lastPage = 1
+	}
+	add(1, "first")
+	if m.HasPrev {
+		prev := m.Page - 1
+		if prev > lastPage {
+			prev = lastPage
+		}
+		add(prev, "prev")
+	}
+	if m.HasNext {
+		add(m.Page+1, "next")
+	}
+	add(lastPage, "last")
+	return strings.Join(links, ", ")
+}
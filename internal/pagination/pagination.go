@@ -0,0 +1,155 @@
+// Package pagination interpreta los parámetros page, page_size y sort de los listados y
+// arma los metadatos de la respuesta paginada (total, total_pages, has_next) y la cabecera
+// Link (RFC 8288) con las páginas vecinas. Para tablas grandes ofrece además un modo por
+// cursor firmado (cursor.go) que no usa OFFSET.
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Sortable campos por los que se puede ordenar un recurso: nombre en ?sort= -> columna.
+// Default se usa sin ?sort=; el desempate final siempre es por el campo TieBreaker (por
+// defecto "id") para que el orden sea estable entre páginas.
+type Sortable struct {
+	Fields     map[string]string
+	Default    string
+	TieBreaker string
+}
+
+// SortField un criterio de orden ya validado
+type SortField struct {
+	Name   string
+	Column string
+	Desc   bool
+}
+
+// Params página pedida y orden validado contra la lista blanca del recurso
+type Params struct {
+	Page     int
+	PageSize int
+	Sort     []SortField
+}
+
+// Parse lee page, page_size y sort (ej. "sort=razon_social,-created_at") de la query
+func Parse(query url.Values, sortable Sortable) (*Params, error) {
+	params := &Params{Page: 1, PageSize: DefaultPageSize}
+
+	if value := query.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return nil, errors.New("page debe ser un entero mayor o igual a 1")
+		}
+		params.Page = page
+	}
+	if value := query.Get("page_size"); value != "" {
+		pageSize, err := strconv.Atoi(value)
+		if err != nil || pageSize < 1 || pageSize > MaxPageSize {
+			return nil, fmt.Errorf("page_size debe ser un entero entre 1 y %d", MaxPageSize)
+		}
+		params.PageSize = pageSize
+	}
+
+	sortParam := query.Get("sort")
+	if sortParam == "" {
+		sortParam = sortable.Default
+	}
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(sortParam, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		desc := strings.HasPrefix(item, "-")
+		name := strings.TrimPrefix(item, "-")
+		column, ok := sortable.Fields[name]
+		if !ok {
+			return nil, fmt.Errorf("no se puede ordenar por %q; campos permitidos: %s", name, strings.Join(sortable.names(), ", "))
+		}
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		params.Sort = append(params.Sort, SortField{Name: name, Column: column, Desc: desc})
+	}
+
+	tieBreaker := sortable.TieBreaker
+	if tieBreaker == "" {
+		tieBreaker = "id"
+	}
+	column, ok := sortable.Fields[tieBreaker]
+	if !ok {
+		column = tieBreaker
+	}
+	if !seen[column] {
+		params.Sort = append(params.Sort, SortField{Name: tieBreaker, Column: column})
+	}
+	return params, nil
+}
+
+// Offset filas que se saltan hasta la página pedida
+func (p *Params) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Order aplica el orden a la query; las columnas vienen de la lista blanca, nunca del cliente
+func (p *Params) Order(query *gorm.DB) *gorm.DB {
+	for _, field := range p.Sort {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Column}, Desc: field.Desc})
+	}
+	return query
+}
+
+// SortString orden aplicado, en el mismo formato que ?sort=
+func (p *Params) SortString() string {
+	parts := make([]string, 0, len(p.Sort))
+	for _, field := range p.Sort {
+		if field.Desc {
+			parts = append(parts, "-"+field.Name)
+		} else {
+			parts = append(parts, field.Name)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Find cuenta el total de la query filtrada y carga en dest la página pedida. Las relaciones
+// se pasan en preloads y no en la query para que no se apliquen al conteo.
+func Find[T any](query *gorm.DB, params *Params, dest *[]T, preloads ...string) (*Meta, error) {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	pageQuery := params.Order(query.Session(&gorm.Session{})).Offset(params.Offset()).Limit(params.PageSize)
+	for _, preload := range preloads {
+		pageQuery = pageQuery.Preload(preload)
+	}
+	if err := pageQuery.Find(dest).Error; err != nil {
+		return nil, err
+	}
+	return NewMeta(params, total), nil
+}
+
+func (s Sortable) names() []string {
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	// Orden alfabético para que el mensaje de error sea estable
+	sort.Strings(names)
+	return names
+}
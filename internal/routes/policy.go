@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"megabaseGo/internal/app/middleware"
+	"megabaseGo/internal/database/seeders"
+)
+
+// routePolicies autorización de cada ruta registrada en Setup. Es la única fuente de verdad:
+// al arrancar se valida que toda ruta tenga exactamente una política y que los permisos existan
+// en el catálogo (seeders.PermissionCatalog). `console routes` imprime la matriz resultante.
+var routePolicies = []middleware.RoutePolicy{
+	// Públicas
+	{Method: "GET", Path: "/health", Public: true},
+	{Method: "GET", Path: "/.well-known/jwks.json", Public: true},
+	{Method: "GET", Path: "/api/v1/info", Public: true},
+	{Method: "POST", Path: "/api/v1/consult", Public: true},
+
+	// Autenticación
+	{Method: "POST", Path: "/api/v1/auth/login", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/register", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/refresh", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/token", Public: true},
+	{Method: "GET", Path: "/api/v1/auth/csrf", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/logout", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/mfa/verify", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/forgot-password", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/reset-password", Public: true},
+	{Method: "GET", Path: "/api/v1/auth/verify-email", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/resend-verification", Public: true},
+	{Method: "GET", Path: "/api/v1/auth/invitations", Public: true},
+	{Method: "POST", Path: "/api/v1/auth/invitations/accept", Public: true},
+	{Method: "GET", Path: "/api/v1/auth/oidc/providers", Public: true},
+	{Method: "GET", Path: "/api/v1/auth/oidc/:provider/login", Public: true},
+	{Method: "GET", Path: "/api/v1/auth/oidc/:provider/callback", Public: true},
+
+	// Enrolamiento MFA: accesible aunque el rol exija MFA y el usuario aún no lo tenga.
+	// Las credenciales del usuario no se pueden tocar mientras se lo suplanta.
+	{Method: "POST", Path: "/api/v1/profile/mfa/totp", AllowMFAPending: true, DenyImpersonation: true},
+	{Method: "POST", Path: "/api/v1/profile/mfa/totp/confirm", AllowMFAPending: true, DenyImpersonation: true},
+	{Method: "DELETE", Path: "/api/v1/profile/mfa/totp", AllowMFAPending: true, DenyImpersonation: true},
+	{Method: "POST", Path: "/api/v1/profile/mfa/recovery-codes", AllowMFAPending: true, DenyImpersonation: true},
+
+	// Perfil
+	{Method: "GET", Path: "/api/v1/profile"},
+	{Method: "POST", Path: "/api/v1/change-password", DenyImpersonation: true},
+	{Method: "GET", Path: "/api/v1/check-auth"},
+	{Method: "POST", Path: "/api/v1/stop-impersonation"},
+	{Method: "GET", Path: "/api/v1/profile/api-keys"},
+	{Method: "POST", Path: "/api/v1/profile/api-keys", DenyImpersonation: true},
+	{Method: "DELETE", Path: "/api/v1/profile/api-keys/:id", DenyImpersonation: true},
+	{Method: "GET", Path: "/api/v1/profile/sessions"},
+	{Method: "DELETE", Path: "/api/v1/profile/sessions", DenyImpersonation: true},
+	{Method: "DELETE", Path: "/api/v1/profile/sessions/:id", DenyImpersonation: true},
+	{Method: "GET", Path: "/api/v1/profile/login-history"},
+
+	// Administración de API keys
+	{Method: "GET", Path: "/api/v1/api-keys", Permissions: []string{"api_keys:manage"}},
+	{Method: "DELETE", Path: "/api/v1/api-keys/:id", Permissions: []string{"api_keys:manage"}},
+
+	// Roles y permisos
+	{Method: "GET", Path: "/api/v1/permissions", Permissions: []string{"roles:read"}},
+	{Method: "POST", Path: "/api/v1/roles", Permissions: []string{"roles:create"}},
+	{Method: "GET", Path: "/api/v1/roles", Permissions: []string{"roles:read"}},
+	{Method: "GET", Path: "/api/v1/roles/:id", Permissions: []string{"roles:read"}},
+	{Method: "PUT", Path: "/api/v1/roles/:id", Permissions: []string{"roles:update"}},
+	{Method: "DELETE", Path: "/api/v1/roles/:id", Permissions: []string{"roles:delete"}},
+	{Method: "GET", Path: "/api/v1/roles/:id/permissions", Permissions: []string{"roles:read"}},
+	{Method: "PUT", Path: "/api/v1/roles/:id/permissions", Permissions: []string{"roles:permissions"}},
+	{Method: "POST", Path: "/api/v1/roles/:id/permissions", Permissions: []string{"roles:permissions"}},
+	{Method: "DELETE", Path: "/api/v1/roles/:id/permissions/:permission", Permissions: []string{"roles:permissions"}},
+
+	// Usuarios
+	{Method: "POST", Path: "/api/v1/users", Permissions: []string{"users:create"}},
+	{Method: "GET", Path: "/api/v1/users", Permissions: []string{"users:read"}},
+	{Method: "GET", Path: "/api/v1/users/:id", Permissions: []string{"users:read"}},
+	{Method: "PUT", Path: "/api/v1/users/:id", Permissions: []string{"users:update"}},
+	{Method: "DELETE", Path: "/api/v1/users/:id", Permissions: []string{"users:delete"}},
+	{Method: "POST", Path: "/api/v1/users/:id/unlock", Permissions: []string{"users:unlock"}},
+	{Method: "POST", Path: "/api/v1/users/:id/impersonate", Permissions: []string{"users:impersonate"}, DenyImpersonation: true},
+	{Method: "GET", Path: "/api/v1/users/check-username", Permissions: []string{"users:read"}},
+	{Method: "GET", Path: "/api/v1/users/check-email", Permissions: []string{"users:read"}},
+	{Method: "GET", Path: "/api/v1/users/:id/sessions", Permissions: []string{"users:sessions"}},
+	{Method: "DELETE", Path: "/api/v1/users/:id/sessions", Permissions: []string{"users:sessions"}, DenyImpersonation: true},
+	{Method: "DELETE", Path: "/api/v1/users/:id/sessions/:session_id", Permissions: []string{"users:sessions"}, DenyImpersonation: true},
+	{Method: "GET", Path: "/api/v1/users/:id/login-history", Permissions: []string{"users:sessions"}},
+	{Method: "GET", Path: "/api/v1/users/invitations", Permissions: []string{"users:invite"}},
+	{Method: "POST", Path: "/api/v1/users/invitations", Permissions: []string{"users:invite"}, DenyImpersonation: true},
+	{Method: "POST", Path: "/api/v1/users/invitations/:id/resend", Permissions: []string{"users:invite"}, DenyImpersonation: true},
+	{Method: "DELETE", Path: "/api/v1/users/invitations/:id", Permissions: []string{"users:invite"}, DenyImpersonation: true},
+
+	// Ciudadanos
+	{Method: "GET", Path: "/api/v1/citizens", Permissions: []string{"citizens:read"}},
+	{Method: "POST", Path: "/api/v1/citizens", Permissions: []string{"citizens:create"}},
+	{Method: "GET", Path: "/api/v1/citizens/:id", Permissions: []string{"citizens:read"}},
+	{Method: "PUT", Path: "/api/v1/citizens/:id", Permissions: []string{"citizens:update"}},
+	{Method: "DELETE", Path: "/api/v1/citizens/:id", Permissions: []string{"citizens:delete"}},
+	{Method: "GET", Path: "/api/v1/citizens/search", Permissions: []string{"citizens:read"}},
+	{Method: "GET", Path: "/api/v1/citizens/email/:email", Permissions: []string{"citizens:read"}},
+	{Method: "GET", Path: "/api/v1/citizens/identification/:numero", Permissions: []string{"citizens:read"}},
+	{Method: "GET", Path: "/api/v1/citizens/razon-social/:razon", Permissions: []string{"citizens:read"}},
+	{Method: "GET", Path: "/api/v1/citizens/check/identification/:numero", Permissions: []string{"citizens:read"}},
+	{Method: "GET", Path: "/api/v1/citizens/check/email/:email", Permissions: []string{"citizens:read"}},
+	{Method: "GET", Path: "/api/v1/citizens/check/razon-social/:razon", Permissions: []string{"citizens:read"}},
+
+	// Compañías
+	{Method: "POST", Path: "/api/v1/companies", Permissions: []string{"companies:create"}},
+	{Method: "GET", Path: "/api/v1/companies", Permissions: []string{"companies:read"}},
+	{Method: "GET", Path: "/api/v1/companies/:id", Permissions: []string{"companies:read"}},
+	{Method: "PUT", Path: "/api/v1/companies/:id", Permissions: []string{"companies:update"}},
+	{Method: "DELETE", Path: "/api/v1/companies/:id", Permissions: []string{"companies:delete"}},
+}
+
+// NewPolicyTable construye la tabla de políticas de las rutas
+func NewPolicyTable() (*middleware.PolicyTable, error) {
+	return middleware.NewPolicyTable(routePolicies)
+}
+
+// permissionNames nombres del catálogo de permisos, para validar la tabla
+func permissionNames() []string {
+	names := make([]string, 0, len(seeders.PermissionCatalog))
+	for _, p := range seeders.PermissionCatalog {
+		names = append(names, p.Name)
+	}
+	return names
+}
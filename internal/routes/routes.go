@@ -1,16 +1,24 @@
 package routes
 
 import (
+	"megabaseGo/internal/app/dto"
 	"megabaseGo/internal/app/handlers"
 	"megabaseGo/internal/app/middleware"
+	appconfig "megabaseGo/internal/config"
 	"os"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// Setup configura todas las rutas de la aplicación
-func Setup() *gin.Engine {
+// Setup configura todas las rutas de la aplicación.
+// Falla si alguna ruta registrada no tiene política de autorización en routePolicies.
+func Setup() (*gin.Engine, error) {
+	// Reglas de validación propias usadas en los DTOs (ej. identification)
+	if err := dto.RegisterValidators(); err != nil {
+		return nil, err
+	}
+
 	// Crear router con configuración por defecto
 	router := gin.Default()
 
@@ -23,7 +31,9 @@ func Setup() *gin.Engine {
 	// 2. Permitir que el navegador envíe y reciba cookies
 	config.AllowCredentials = true
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", appconfig.Get().Cookie.CSRFHeader}
+	// 3. Exponer la cabecera Link de los listados paginados al front
+	config.ExposeHeaders = []string{"Link"}
 	router.Use(cors.New(config))
 
 	// ---- FIN DEL AJUSTE ----
@@ -32,6 +42,15 @@ func Setup() *gin.Engine {
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
+	// Autorización declarativa: una sola política por ruta (ver policy.go)
+	policies, err := NewPolicyTable()
+	if err != nil {
+		return nil, err
+	}
+	authMiddleware := middleware.NewAuthMiddleware()
+	// La auditoría de suplantación va antes para registrar también las peticiones rechazadas
+	router.Use(middleware.AuditImpersonation(), authMiddleware.Authorize(policies))
+
 	// Ruta de health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -41,14 +60,23 @@ func Setup() *gin.Engine {
 		})
 	})
 
+	// Claves públicas para que otros servicios verifiquen nuestros tokens
+	jwksHandler := handlers.NewJWKSHandler()
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// Inicializar handlers y middleware
 	userHandler := handlers.NewUserHandler()
 	roleHandler := handlers.NewRoleHandler()
 	authHandler := handlers.NewAuthHandler()
-	authMiddleware := middleware.NewAuthMiddleware()
+	mfaHandler := handlers.NewMFAHandler()
+	apiKeyHandler := handlers.NewAPIKeyHandler()
+	invitationHandler := handlers.NewInvitationHandler()
+	oidcHandler := handlers.NewOIDCHandler()
+	sessionHandler := handlers.NewSessionHandler()
 
-	// Grupo de rutas API v1
+	// Grupo de rutas API v1; CSRF para las peticiones que modifican estado con sesión por cookies
 	v1 := router.Group("/api/v1")
+	v1.Use(middleware.CSRFProtect())
 	{
 		// Rutas públicas de autenticación
 		auth := v1.Group("/auth")
@@ -56,22 +84,66 @@ func Setup() *gin.Engine {
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/token", authHandler.Token)
+			auth.GET("/csrf", authHandler.CSRFToken)
 			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/mfa/verify", authHandler.VerifyMFA)
+			auth.POST("/forgot-password", authHandler.ForgotPassword)
+			auth.POST("/reset-password", authHandler.ResetPassword)
+			auth.GET("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/resend-verification", authHandler.ResendVerification)
+			auth.GET("/invitations", invitationHandler.PreviewInvitation)
+			auth.POST("/invitations/accept", invitationHandler.AcceptInvitation)
+
+			// SSO con proveedores OpenID Connect (OIDC_PROVIDERS)
+			auth.GET("/oidc/providers", oidcHandler.GetProviders)
+			auth.GET("/oidc/:provider/login", oidcHandler.Login)
+			auth.GET("/oidc/:provider/callback", oidcHandler.Callback)
 		}
 
 		consultHandler := handlers.NewConsultHandler()
 		v1.POST("/consult", consultHandler.Consultar)
 
-		// Rutas protegidas (requieren autenticación)
+		// Enrolamiento MFA: accesible aunque el rol exija MFA y el usuario aún no lo tenga
+		mfa := v1.Group("/profile/mfa")
+		{
+			mfa.POST("/totp", mfaHandler.EnrollTOTP)
+			mfa.POST("/totp/confirm", mfaHandler.ConfirmTOTP)
+			mfa.DELETE("/totp", mfaHandler.DisableTOTP)
+			mfa.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+		}
+
+		// Rutas protegidas (requieren autenticación y los permisos de routePolicies)
 		protected := v1.Group("/")
-		protected.Use(authMiddleware.RequireAuth())
 		{
 			// Profile endpoints
 			protected.GET("/profile", authHandler.GetProfile)
 			protected.POST("/change-password", authHandler.ChangePassword)
 			protected.GET("/check-auth", authHandler.CheckAuth)
+			protected.POST("/stop-impersonation", authHandler.StopImpersonation)
+
+			// API keys personales para integraciones
+			protected.GET("/profile/api-keys", apiKeyHandler.GetMyAPIKeys)
+			protected.POST("/profile/api-keys", apiKeyHandler.CreateAPIKey)
+			protected.DELETE("/profile/api-keys/:id", apiKeyHandler.RevokeMyAPIKey)
 
-			// Rutas para roles (requiere autenticación)
+			// Sesiones abiertas e historial de acceso del usuario
+			protected.GET("/profile/sessions", sessionHandler.GetMySessions)
+			protected.DELETE("/profile/sessions", sessionHandler.SignOutEverywhere)
+			protected.DELETE("/profile/sessions/:id", sessionHandler.RevokeMySession)
+			protected.GET("/profile/login-history", sessionHandler.GetMyLoginHistory)
+
+			// Administración de API keys de todos los usuarios
+			apiKeys := protected.Group("/api-keys")
+			{
+				apiKeys.GET("", apiKeyHandler.GetAPIKeys)
+				apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+			}
+
+			// Catálogo de permisos asignables a roles
+			protected.GET("/permissions", roleHandler.GetPermissions)
+
+			// Rutas para roles
 			roles := protected.Group("/roles")
 			{
 				roles.POST("", roleHandler.CreateRole)
@@ -79,9 +151,15 @@ func Setup() *gin.Engine {
 				roles.GET("/:id", roleHandler.GetRole)
 				roles.PUT("/:id", roleHandler.UpdateRole)
 				roles.DELETE("/:id", roleHandler.DeleteRole)
+
+				// Permisos del rol
+				roles.GET("/:id/permissions", roleHandler.GetRolePermissions)
+				roles.PUT("/:id/permissions", roleHandler.SetRolePermissions)
+				roles.POST("/:id/permissions", roleHandler.AddRolePermissions)
+				roles.DELETE("/:id/permissions/:permission", roleHandler.RemoveRolePermission)
 			}
 
-			// Rutas para usuarios (requiere autenticación)
+			// Rutas para usuarios
 			users := protected.Group("/users")
 			{
 				users.POST("", userHandler.CreateUser)
@@ -89,8 +167,22 @@ func Setup() *gin.Engine {
 				users.GET("/:id", userHandler.GetUser)
 				users.PUT("/:id", userHandler.UpdateUser)
 				users.DELETE("/:id", userHandler.DeleteUser)
+				users.POST("/:id/unlock", userHandler.UnlockUser)
+				users.POST("/:id/impersonate", userHandler.Impersonate)
 				users.GET("/check-username", userHandler.CheckUsernameAvailability)
 				users.GET("/check-email", userHandler.CheckEmailAvailability)
+
+				// Sesiones e historial de acceso de cada usuario
+				users.GET("/:id/sessions", sessionHandler.GetUserSessions)
+				users.DELETE("/:id/sessions", sessionHandler.SignOutUserEverywhere)
+				users.DELETE("/:id/sessions/:session_id", sessionHandler.RevokeUserSession)
+				users.GET("/:id/login-history", sessionHandler.GetUserLoginHistory)
+
+				// Invitaciones: el invitado crea su cuenta con el rol elegido por el administrador
+				users.GET("/invitations", invitationHandler.GetInvitations)
+				users.POST("/invitations", invitationHandler.CreateInvitation)
+				users.POST("/invitations/:id/resend", invitationHandler.ResendInvitation)
+				users.DELETE("/invitations/:id", invitationHandler.RevokeInvitation)
 			}
 
 			// Grupo de rutas para ciudadanos
@@ -106,6 +198,7 @@ func Setup() *gin.Engine {
 				citizens.DELETE("/:id", citizenHandler.DeleteCitizen)
 
 				// Búsquedas específicas
+				citizens.GET("/search", citizenHandler.SearchCitizens)
 				citizens.GET("/email/:email", citizenHandler.GetCitizenByEmail)
 				citizens.GET("/identification/:numero", citizenHandler.GetCitizenByIdentification)
 				citizens.GET("/razon-social/:razon", citizenHandler.GetCitizenByRazonSocial)
@@ -139,35 +232,70 @@ func Setup() *gin.Engine {
 				},
 				"endpoints": gin.H{
 					"auth": gin.H{
-						"login":    "POST /api/v1/auth/login",
-						"register": "POST /api/v1/auth/register",
-						"refresh":  "POST /api/v1/auth/refresh",
-						"logout":   "POST /api/v1/auth/logout",
-						"profile":  "GET /api/v1/profile (protected)",
-						"check":    "GET /api/v1/check-auth (protected)",
-						"password": "POST /api/v1/change-password (protected)",
+						"login":              "POST /api/v1/auth/login",
+						"register":           "POST /api/v1/auth/register (if REGISTRATION_ENABLED, default role)",
+						"invitation":         "GET /api/v1/auth/invitations?token=, POST /api/v1/auth/invitations/accept",
+						"sso":                "GET /api/v1/auth/oidc/providers, GET /api/v1/auth/oidc/:provider/login?return_to=",
+						"refresh":            "POST /api/v1/auth/refresh",
+						"token":              "POST /api/v1/auth/token (grant_type: password | refresh_token | mfa)",
+						"csrf":               "GET /api/v1/auth/csrf",
+						"logout":             "POST /api/v1/auth/logout",
+						"mfa":                "POST /api/v1/auth/mfa/verify",
+						"forgot":             "POST /api/v1/auth/forgot-password",
+						"reset":              "POST /api/v1/auth/reset-password",
+						"verify":             "GET /api/v1/auth/verify-email?token=",
+						"resend":             "POST /api/v1/auth/resend-verification",
+						"profile":            "GET /api/v1/profile (protected)",
+						"check":              "GET /api/v1/check-auth (protected)",
+						"password":           "POST /api/v1/change-password (protected)",
+						"stop_impersonation": "POST /api/v1/stop-impersonation (impersonation token)",
+						"sessions":           "GET /api/v1/profile/sessions, DELETE /api/v1/profile/sessions[/:id] (protected)",
+						"login_history":      "GET /api/v1/profile/login-history?limit= (protected)",
 					},
 					"roles": gin.H{
-						"create": "POST /api/v1/roles (protected)",
-						"list":   "GET /api/v1/roles (protected)",
-						"get":    "GET /api/v1/roles/:id (protected)",
-						"update": "PUT /api/v1/roles/:id (protected)",
-						"delete": "DELETE /api/v1/roles/:id (protected)",
+						"create":      "POST /api/v1/roles (protected)",
+						"list":        "GET /api/v1/roles (protected)",
+						"get":         "GET /api/v1/roles/:id (protected)",
+						"update":      "PUT /api/v1/roles/:id (protected)",
+						"delete":      "DELETE /api/v1/roles/:id (protected)",
+						"permissions": "GET|PUT|POST /api/v1/roles/:id/permissions, DELETE /api/v1/roles/:id/permissions/:permission (protected)",
+						"catalog":     "GET /api/v1/permissions (protected)",
 					},
 					"users": gin.H{
-						"create": "POST /api/v1/users (protected)",
-						"list":   "GET /api/v1/users (protected)",
-						"get":    "GET /api/v1/users/:id (protected)",
-						"update": "PUT /api/v1/users/:id (protected)",
-						"delete": "DELETE /api/v1/users/:id (protected)",
+						"create":      "POST /api/v1/users (protected)",
+						"list":        "GET /api/v1/users (protected)",
+						"get":         "GET /api/v1/users/:id (protected)",
+						"update":      "PUT /api/v1/users/:id (protected)",
+						"delete":      "DELETE /api/v1/users/:id (protected)",
+						"impersonate": "POST /api/v1/users/:id/impersonate (users:impersonate)",
+						"invitations": "GET|POST /api/v1/users/invitations, POST /api/v1/users/invitations/:id/resend, DELETE /api/v1/users/invitations/:id (users:invite)",
+						"sessions":    "GET|DELETE /api/v1/users/:id/sessions, DELETE /api/v1/users/:id/sessions/:session_id, GET /api/v1/users/:id/login-history (users:sessions)",
 					},
 				},
 				"authentication": gin.H{
-					"type":   "JWT Bearer Token",
-					"header": "Authorization: Bearer <token>",
-					"note":   "Include access token in Authorization header for protected routes",
+					"type":    "JWT Bearer Token",
+					"header":  "Authorization: Bearer <token>",
+					"note":    "Browsers use the HttpOnly access_token cookie; other clients get tokens from /auth/token and send them in the Authorization header",
+					"api_key": "Authorization: Bearer mbk_... or X-API-Key: mbk_... (scopes: citizens/companies read|write)",
 				},
 				"query_params": gin.H{
+					"pagination": gin.H{
+						"page":               "int - Page number (default 1)",
+						"page_size":          "int - Items per page (default 20, max 100)",
+						"sort":               "string - Comma separated fields, '-' prefix for descending (e.g. sort=name,-created_at)",
+						"response":           "data + pagination {page, page_size, total, total_pages, has_next, has_prev, sort} and Link header (RFC 8288)",
+						"applies":            "GET /api/v1/citizens, /api/v1/citizens/search, /api/v1/users, /api/v1/roles, /api/v1/companies",
+						"search":             "GET /api/v1/citizens/search?q= (same filters and pagination, default sort=-relevance)",
+						"cursor":             "GET /api/v1/citizens?limit=&sort=id|-id|created_at|-created_at, then ?cursor=<next_cursor> (signed, no OFFSET, no total; not combinable with page)",
+						"cursor_consistency": "rows committed before the first page are returned exactly once, even with concurrent inserts; a row committed during the walk is returned only if its key sorts after the last page delivered (a late commit with a lower id or created_at is missed by that walk)",
+					},
+					"filter": gin.H{
+						"syntax":    "filter[field][op]=value (op defaults to eq), e.g. filter[created_at][gte]=2024-01-01, filter[regimen][in]=RIMPE,GENERAL",
+						"operators": "eq, ne, like, in, nin, gt, gte, lt, lte, between (a,b), null (true|false); allowed per field type",
+						"dates":     "YYYY-MM-DD (whole day) or RFC 3339",
+						"json":      "filter[sucursales.<key>][eq|like|in|null]=, filter[representantes_legales.<key>]= (citizens)",
+						"applies":   "GET /api/v1/citizens, /api/v1/citizens/search, /api/v1/users, /api/v1/companies",
+					},
 					"roles": gin.H{
 						"include_inactive": "bool - Include inactive roles",
 					},
@@ -180,5 +308,9 @@ func Setup() *gin.Engine {
 		})
 	}
 
-	return router
+	if err := policies.Validate(router.Routes(), permissionNames()); err != nil {
+		return nil, err
+	}
+
+	return router, nil
 }
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2idPrefix  = "$argon2id$"
+	argon2SaltLen   = 16
+	argon2KeyLen    = 32
+	argon2MinMemory = 19 * 1024 // mínimo recomendado por OWASP (KiB)
+)
+
+// ErrMismatchedPassword la contraseña no corresponde al hash
+var ErrMismatchedPassword = errors.New("password does not match")
+
+// Argon2idHasher implementa PasswordHasher con argon2id en formato PHC:
+// $argon2id$v=19$m=<memoria>,t=<iteraciones>,p=<hilos>$<salt>$<hash>
+type Argon2idHasher struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// NewArgon2idHasher construye un Argon2idHasher; valores fuera de rango se ajustan al mínimo seguro
+func NewArgon2idHasher(memory, time uint32, threads uint8) *Argon2idHasher {
+	if memory < argon2MinMemory {
+		memory = argon2MinMemory
+	}
+	if time < 1 {
+		time = 1
+	}
+	if threads < 1 {
+		threads = 1
+	}
+	return &Argon2idHasher{memory: memory, time: time, threads: threads}
+}
+
+func (a *Argon2idHasher) HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.time, a.memory, a.threads, argon2KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, a.memory, a.time, a.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2idHasher) ComparePassword(hashedPassword, password string) error {
+	return comparePasswordHash(hashedPassword, password)
+}
+
+func (a *Argon2idHasher) NeedsRehash(hashedPassword string) bool {
+	params, _, _, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return true
+	}
+	return params.memory < a.memory || params.time < a.time || params.threads < a.threads
+}
+
+// compareArgon2id recalcula el hash con los parámetros guardados y compara en tiempo constante
+func compareArgon2id(hashedPassword, password string) error {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+// decodeArgon2id separa parámetros, salt y hash de un hash en formato PHC
+func decodeArgon2id(hashedPassword string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errors.New("unsupported argon2id version")
+	}
+
+	params := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, errors.New("invalid argon2id salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	return params, salt, key, nil
+}
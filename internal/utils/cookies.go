@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"net/http"
+
+	"megabaseGo/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetCookie escribe una cookie con los atributos de COOKIE_* (dominio, path, Secure, SameSite).
+// base es el nombre sin el prefijo __Host-.
+func SetCookie(c *gin.Context, base, value string, maxAge int, httpOnly bool) {
+	cfg := config.Get().Cookie
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cfg.Name(base),
+		Value:    value,
+		MaxAge:   maxAge,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.SameSite,
+	})
+}
+
+// ClearCookie borra la cookie usando los mismos atributos con los que se creó
+func ClearCookie(c *gin.Context, base string, httpOnly bool) {
+	SetCookie(c, base, "", -1, httpOnly)
+}
+
+// GetCookie lee la cookie por su nombre base; "" si no existe
+func GetCookie(c *gin.Context, base string) string {
+	value, err := c.Cookie(config.Get().Cookie.Name(base))
+	if err != nil {
+		return ""
+	}
+	return value
+}
@@ -3,13 +3,15 @@ package utils
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // APIError representa un error con código HTTP específico
 type APIError struct {
-	Message    string `json:"message"`
-	StatusCode int    `json:"-"`
-	Details    string `json:"details,omitempty"`
+	Message    string        `json:"message"`
+	StatusCode int           `json:"-"`
+	Details    string        `json:"details,omitempty"`
+	RetryAfter time.Duration `json:"-"`
 }
 
 // Error implementa la interfaz error
@@ -64,6 +66,15 @@ func NewConflictError(message string) *APIError {
 	}
 }
 
+// NewTooManyRequestsError crea un error 429; retryAfter se envía en la cabecera Retry-After
+func NewTooManyRequestsError(message string, retryAfter time.Duration) *APIError {
+	return &APIError{
+		Message:    message,
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: retryAfter,
+	}
+}
+
 // NewInternalServerError crea un error 500
 func NewInternalServerError(message string) *APIError {
 	return &APIError{
@@ -85,4 +96,4 @@ func NewValidationError(details string) *APIError {
 func IsAPIError(err error) (*APIError, bool) {
 	apiErr, ok := err.(*APIError)
 	return apiErr, ok
-}
\ No newline at end of file
+}
@@ -1,133 +1,477 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"megabaseGo/internal/models"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Tipos de token (claim typ). Un token solo es válido para el propósito con el que se emitió.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeReset   = "reset"
+	TokenTypeInvite  = "invite"
+	TokenTypeMFA     = "mfa"
+)
+
+// Errores de validación que el llamador puede distinguir con errors.Is
+var (
+	ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrTokenTypeMismatch   = errors.New("token type not allowed for this use")
+	ErrInvalidIssuer       = errors.New("invalid token issuer")
+	ErrInvalidAudience     = errors.New("invalid token audience")
+)
+
 // JWTClaims estructura para los claims del JWT
 type JWTClaims struct {
+	UserID    uint   `json:"user_id"`
+	UserName  string `json:"user_name"`
+	Email     string `json:"email"`
+	RoleID    uint   `json:"role_id"`
+	RoleName  string `json:"role_name"`
+	TokenType string `json:"typ"`
+	// AMR métodos de autenticación usados (RFC 8176): "pwd", "otp"
+	AMR []string `json:"amr,omitempty"`
+	// MFAEnrollment indica que el rol exige MFA y el usuario aún no lo configuró;
+	// el token solo sirve para completar el enrolamiento
+	MFAEnrollment bool `json:"mfa_enroll,omitempty"`
+	// Act usuario real que actúa en nombre del titular del token (RFC 8693), solo en suplantación
+	Act *ActorClaim `json:"act,omitempty"`
+	// SessionID familia de la sesión de refresh que emitió el token; permite revocarlo junto con ella
+	SessionID string `json:"sid,omitempty"`
+	// IssuedAtMs momento de emisión en milisegundos; iat solo tiene segundos y no basta para
+	// ordenar el token frente a una revocación hecha en el mismo segundo
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ActorClaim identifica a quien suplanta al usuario del token
+type ActorClaim struct {
+	Subject  string `json:"sub"`
 	UserID   uint   `json:"user_id"`
 	UserName string `json:"user_name"`
-	Email    string `json:"email"`
-	RoleID   uint   `json:"role_id"`
-	RoleName string `json:"role_name"`
+}
+
+// IsImpersonated indica si el token fue emitido para suplantar al usuario
+func (c *JWTClaims) IsImpersonated() bool { return c.Act != nil }
+
+// IssuedAtMillis momento de emisión en milisegundos. Los tokens sin iat_ms se ubican al inicio
+// de su segundo de iat, así que ante la duda quedan antes de una revocación y no después.
+// Retorna false si el token no tiene ni iat_ms ni iat.
+func (c *JWTClaims) IssuedAtMillis() (int64, bool) {
+	if c.IssuedAtMs > 0 {
+		return c.IssuedAtMs, true
+	}
+	if c.IssuedAt == nil {
+		return 0, false
+	}
+	return c.IssuedAt.Time.Unix() * 1000, true
+}
+
+// RefreshClaims claims del refresh token. El jti (ID) identifica el token
+// y FamilyID la sesión a la que pertenece.
+type RefreshClaims struct {
+	FamilyID  string `json:"fid"`
+	TokenType string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// PurposeClaims claims de tokens de un solo propósito (reset, invite, mfa).
+// El subject identifica el recurso al que aplica el token.
+type PurposeClaims struct {
+	TokenType string `json:"typ"`
 	jwt.RegisteredClaims
 }
 
-// JWTManager maneja la generación y validación de tokens JWT
+// typedClaims claims que declaran su tipo de token
+type typedClaims interface {
+	jwt.Claims
+	GetTokenType() string
+}
+
+// GetTokenType retorna el claim typ
+func (c *JWTClaims) GetTokenType() string { return c.TokenType }
+
+// GetTokenType retorna el claim typ
+func (c *RefreshClaims) GetTokenType() string { return c.TokenType }
+
+// GetTokenType retorna el claim typ
+func (c *PurposeClaims) GetTokenType() string { return c.TokenType }
+
+// UserID retorna el ID de usuario contenido en el subject
+func (c *RefreshClaims) UserID() (uint, error) {
+	userID, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return 0, err
+	}
+	return uint(userID), nil
+}
+
+// RefreshTokenStore persiste las sesiones de refresh token
+type RefreshTokenStore interface {
+	CreateSession(session *models.Session) error
+	FindSession(familyID string) (*models.Session, error)
+	// RotateSession reemplaza currentJTI por newJTI solo si currentJTI sigue siendo el vigente.
+	// Retorna false si otro request ya rotó el token.
+	RotateSession(familyID, currentJTI, newJTI string, expiresAt time.Time) (bool, error)
+	// RevokeSession revoca la familia y los access tokens emitidos con su sid
+	RevokeSession(familyID, reason string) error
+}
+
+// JWTManager maneja la generación y validación de tokens JWT.
+// Las claves de firma se obtienen de CurrentKeySet (HS256 o RS256/ES256/EdDSA con kid).
 type JWTManager struct {
-	secretKey     string
-	tokenDuration time.Duration
+	tokenDuration   time.Duration
+	refreshDuration time.Duration
+	refreshStore    RefreshTokenStore
+	// issuer con el que se emiten los tokens; acceptedIssuers los que se aceptan al validar
+	issuer          string
+	acceptedIssuers []string
+	audience        []string
+	leeway          time.Duration
 }
 
 // NewJWTManager crea una nueva instancia del manager JWT
-func NewJWTManager() *JWTManager {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "megabase-default-secret-key-change-in-production"
+func NewJWTManager(refreshStore RefreshTokenStore) *JWTManager {
+	refreshDuration := time.Hour * 24 * 7 // 7 días por defecto
+	if durationStr := os.Getenv("JWT_REFRESH_DURATION_HOURS"); durationStr != "" {
+		if hours, err := strconv.Atoi(durationStr); err == nil {
+			refreshDuration = time.Hour * time.Duration(hours)
+		}
+	}
+
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "megabase-go"
+	}
+
+	acceptedIssuers := splitList(os.Getenv("JWT_ACCEPTED_ISSUERS"))
+	if len(acceptedIssuers) == 0 {
+		acceptedIssuers = []string{issuer}
+	}
+
+	audience := splitList(os.Getenv("JWT_AUDIENCE"))
+	if len(audience) == 0 {
+		audience = []string{"megabase-go"}
+	}
+
+	leeway := 30 * time.Second // tolerancia de reloj entre servidores
+	if leewayStr := os.Getenv("JWT_LEEWAY_SECONDS"); leewayStr != "" {
+		if seconds, err := strconv.Atoi(leewayStr); err == nil && seconds >= 0 {
+			leeway = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return &JWTManager{
+		tokenDuration:   AccessTokenDuration(),
+		refreshDuration: refreshDuration,
+		refreshStore:    refreshStore,
+		issuer:          issuer,
+		acceptedIssuers: acceptedIssuers,
+		audience:        audience,
+		leeway:          leeway,
 	}
+}
 
+// AccessTokenDuration retorna la vigencia configurada de los access tokens
+func AccessTokenDuration() time.Duration {
 	duration := time.Hour * 24 // 24 horas por defecto
 	if durationStr := os.Getenv("JWT_DURATION_HOURS"); durationStr != "" {
 		if hours, err := strconv.Atoi(durationStr); err == nil {
 			duration = time.Hour * time.Duration(hours)
 		}
 	}
+	return duration
+}
 
-	return &JWTManager{
-		secretKey:     secret,
-		tokenDuration: duration,
-	}
+// GenerateToken genera un nuevo access token. El llamador completa los datos del
+// usuario; el manager asigna tipo, jti, vigencia, issuer y audience.
+func (manager *JWTManager) GenerateToken(claims JWTClaims) (string, error) {
+	return manager.GenerateTokenWithTTL(claims, manager.tokenDuration)
 }
 
-// GenerateToken genera un nuevo token JWT
-func (manager *JWTManager) GenerateToken(userID uint, userName, email string, roleID uint, roleName string) (string, error) {
-	claims := JWTClaims{
-		UserID:   userID,
-		UserName: userName,
-		Email:    email,
-		RoleID:   roleID,
-		RoleName: roleName,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(manager.tokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "megabase-go",
-			Subject:   strconv.Itoa(int(userID)),
-		},
+// GenerateTokenWithTTL igual que GenerateToken con una vigencia propia (ej. suplantación).
+// Nunca supera la vigencia configurada para que la revocación por usuario siga cubriéndolo.
+func (manager *JWTManager) GenerateTokenWithTTL(claims JWTClaims, ttl time.Duration) (string, error) {
+	jti, err := generateTokenID()
+	if err != nil {
+		return "", err
 	}
+	if ttl <= 0 || ttl > manager.tokenDuration {
+		ttl = manager.tokenDuration
+	}
+
+	claims.TokenType = TokenTypeAccess
+	// Se toma antes que iat para que nunca quede después del momento real de emisión
+	claims.IssuedAtMs = time.Now().UnixMilli()
+	claims.RegisteredClaims = manager.registeredClaims(jti, strconv.Itoa(int(claims.UserID)), time.Now().Add(ttl))
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(manager.secretKey))
+	return manager.sign(claims)
 }
 
-// GenerateRefreshToken genera un refresh token (válido por más tiempo)
-func (manager *JWTManager) GenerateRefreshToken(userID uint) (string, error) {
-	claims := jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)), // 7 días
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		NotBefore: jwt.NewNumericDate(time.Now()),
-		Issuer:    "megabase-go-refresh",
-		Subject:   strconv.Itoa(int(userID)),
+// GenerateRefreshToken abre una nueva sesión (familia) para el dispositivo y
+// retorna su primer refresh token. El llamador completa usuario, IP, user agent
+// y métodos de autenticación de la sesión.
+func (manager *JWTManager) GenerateRefreshToken(session *models.Session) (string, error) {
+	familyID, err := generateTokenID()
+	if err != nil {
+		return "", err
+	}
+	jti, err := generateTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	session.FamilyID = familyID
+	session.CurrentJTI = jti
+	session.ExpiresAt = now.Add(manager.refreshDuration)
+	session.LastUsedAt = now
+	if err := manager.refreshStore.CreateSession(session); err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(manager.secretKey))
+	return manager.signRefreshToken(session.UserID, familyID, jti, session.ExpiresAt)
 }
 
-// ValidateToken valida un token JWT y retorna los claims
-func (manager *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(manager.secretKey), nil
-	})
+// ValidateRefreshToken valida firma y estado del refresh token en el store.
+// Si el token ya fue rotado se considera reutilizado y se revoca toda la familia.
+func (manager *JWTManager) ValidateRefreshToken(tokenString string) (*RefreshClaims, error) {
+	claims, err := manager.parseRefreshToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
 
+	session, err := manager.refreshStore.FindSession(claims.FamilyID)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	if session.RevokedAt != nil {
+		return nil, ErrRefreshTokenRevoked
+	}
+	if !session.IsActive() {
+		return nil, errors.New("refresh token expired")
+	}
+	if session.CurrentJTI != claims.ID {
+		if err := manager.refreshStore.RevokeSession(claims.FamilyID, "reuse"); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
 	}
 
-	return nil, errors.New("invalid token")
+	return claims, nil
 }
 
-// ValidateRefreshToken valida un refresh token
-func (manager *JWTManager) ValidateRefreshToken(tokenString string) (uint, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(manager.secretKey), nil
-	})
+// RotateRefreshToken invalida el refresh token validado y emite el siguiente de la misma familia
+func (manager *JWTManager) RotateRefreshToken(claims *RefreshClaims) (string, error) {
+	userID, err := claims.UserID()
+	if err != nil {
+		return "", err
+	}
 
+	newJTI, err := generateTokenID()
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
-		if claims.Issuer != "megabase-go-refresh" {
-			return 0, errors.New("invalid refresh token issuer")
-		}
-		userID, err := strconv.Atoi(claims.Subject)
-		if err != nil {
-			return 0, err
+	expiresAt := time.Now().Add(manager.refreshDuration)
+	rotated, err := manager.refreshStore.RotateSession(claims.FamilyID, claims.ID, newJTI, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	if !rotated {
+		// Otro request usó el mismo token antes que nosotros
+		if err := manager.refreshStore.RevokeSession(claims.FamilyID, "reuse"); err != nil {
+			return "", err
 		}
-		return uint(userID), nil
+		return "", ErrRefreshTokenReused
+	}
+
+	return manager.signRefreshToken(userID, claims.FamilyID, newJTI, expiresAt)
+}
+
+// RevokeRefreshToken revoca la sesión a la que pertenece el refresh token
+func (manager *JWTManager) RevokeRefreshToken(tokenString, reason string) error {
+	claims, err := manager.parseRefreshToken(tokenString)
+	if err != nil {
+		return err
+	}
+	return manager.refreshStore.RevokeSession(claims.FamilyID, reason)
+}
+
+// ValidateToken valida un access token y retorna los claims.
+// Rechaza tokens de otro tipo (refresh, reset, invite) aunque la firma sea válida.
+func (manager *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+	if err := manager.parse(tokenString, claims, TokenTypeAccess); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// GeneratePurposeToken genera un token de un solo propósito (reset, invite, mfa) para el subject dado
+func (manager *JWTManager) GeneratePurposeToken(tokenType, subject string, ttl time.Duration) (string, error) {
+	if tokenType == TokenTypeAccess || tokenType == TokenTypeRefresh {
+		return "", ErrTokenTypeMismatch
 	}
 
-	return 0, errors.New("invalid refresh token")
+	jti, err := generateTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	claims := PurposeClaims{
+		TokenType:        tokenType,
+		RegisteredClaims: manager.registeredClaims(jti, subject, time.Now().Add(ttl)),
+	}
+	return manager.sign(claims)
+}
+
+// ValidatePurposeToken valida un token de un solo propósito del tipo indicado
+func (manager *JWTManager) ValidatePurposeToken(tokenString, tokenType string) (*PurposeClaims, error) {
+	claims := &PurposeClaims{}
+	if err := manager.parse(tokenString, claims, tokenType); err != nil {
+		return nil, err
+	}
+	return claims, nil
 }
 
 // GetTokenDuration retorna la duración del token en segundos
 func (manager *JWTManager) GetTokenDuration() int64 {
 	return int64(manager.tokenDuration.Seconds())
-}
\ No newline at end of file
+}
+
+// GetRefreshTokenDuration retorna la duración del refresh token en segundos
+func (manager *JWTManager) GetRefreshTokenDuration() int64 {
+	return int64(manager.refreshDuration.Seconds())
+}
+
+// signRefreshToken firma un refresh token para la familia y jti indicados
+func (manager *JWTManager) signRefreshToken(userID uint, familyID, jti string, expiresAt time.Time) (string, error) {
+	claims := RefreshClaims{
+		FamilyID:         familyID,
+		TokenType:        TokenTypeRefresh,
+		RegisteredClaims: manager.registeredClaims(jti, strconv.Itoa(int(userID)), expiresAt),
+	}
+
+	return manager.sign(claims)
+}
+
+// registeredClaims arma los claims estándar comunes a todos los tipos de token
+func (manager *JWTManager) registeredClaims(jti, subject string, expiresAt time.Time) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		ID:        jti,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    manager.issuer,
+		Subject:   subject,
+		Audience:  jwt.ClaimStrings(manager.audience),
+	}
+}
+
+// sign firma los claims con la clave activa
+func (manager *JWTManager) sign(claims jwt.Claims) (string, error) {
+	keys, err := CurrentKeySet()
+	if err != nil {
+		return "", err
+	}
+	return keys.Sign(claims)
+}
+
+// parse valida firma, vigencia (con tolerancia de reloj), tipo, issuer y audience
+func (manager *JWTManager) parse(tokenString string, claims typedClaims, expectedType string) error {
+	keys, err := CurrentKeySet()
+	if err != nil {
+		return err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc,
+		jwt.WithLeeway(manager.leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+
+	if claims.GetTokenType() != expectedType {
+		return ErrTokenTypeMismatch
+	}
+
+	issuer, _ := claims.GetIssuer()
+	if !containsString(manager.acceptedIssuers, issuer) {
+		return ErrInvalidIssuer
+	}
+
+	audience, _ := claims.GetAudience()
+	accepted := false
+	for _, aud := range audience {
+		if containsString(manager.audience, aud) {
+			accepted = true
+			break
+		}
+	}
+	if !accepted {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+// parseRefreshToken valida un refresh token
+func (manager *JWTManager) parseRefreshToken(tokenString string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	if err := manager.parse(tokenString, claims, TokenTypeRefresh); err != nil {
+		return nil, err
+	}
+	if claims.FamilyID == "" || claims.ID == "" {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	return claims, nil
+}
+
+// generateTokenID genera un identificador aleatorio para jti y familias de sesión
+func generateTokenID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// splitList separa una lista de valores separados por coma, ignorando vacíos
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
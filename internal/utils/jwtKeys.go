@@ -0,0 +1,505 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algoritmos de firma soportados
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// activeKIDFile archivo dentro de JWT_KEYS_DIR que indica el kid con el que se firma
+const activeKIDFile = "active_kid"
+
+// pendingKIDFile archivo dentro de JWT_KEYS_DIR con "<kid> <fecha RFC3339>": la clave se
+// publica en el JWKS de inmediato pero solo pasa a firmar a partir de esa fecha
+const pendingKIDFile = "pending_kid"
+
+// JWKSMaxAge tiempo que otros servicios pueden cachear el JWKS publicado
+const JWKSMaxAge = 5 * time.Minute
+
+// keySetReloadInterval cada cuánto se vuelve a leer el material de claves desde disco,
+// para que una rotación hecha desde la consola llegue al servidor sin reiniciarlo
+const keySetReloadInterval = time.Minute
+
+// SigningKey clave de firma/verificación identificada por su kid
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Method    jwt.SigningMethod
+	// Private es nil en claves que solo se usan para verificar (claves retiradas de la firma)
+	Private crypto.Signer
+	Public  crypto.PublicKey
+	secret  []byte
+}
+
+// CanSign indica si la clave tiene material privado
+func (k *SigningKey) CanSign() bool {
+	return k.secret != nil || k.Private != nil
+}
+
+func (k *SigningKey) signingKey() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.Private
+}
+
+func (k *SigningKey) verificationKey() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.Public
+}
+
+// KeySet conjunto de claves: una activa para firmar y varias para verificar
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// Active retorna la clave con la que se firman los tokens nuevos
+func (ks *KeySet) Active() *SigningKey {
+	return ks.active
+}
+
+// Keys retorna todas las claves ordenadas por kid
+func (ks *KeySet) Keys() []*SigningKey {
+	keys := make([]*SigningKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Sign firma los claims con la clave activa, incluyendo el header kid
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	if ks.active.ID != "" {
+		token.Header["kid"] = ks.active.ID
+	}
+	return token.SignedString(ks.active.signingKey())
+}
+
+// Keyfunc resuelve la clave de verificación según el kid del token y exige que
+// el algoritmo del token coincida con el de la clave
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verificationKey(), nil
+}
+
+var (
+	keySetMu       sync.Mutex
+	cachedKeySet   *KeySet
+	keySetLoadedAt time.Time
+)
+
+// CurrentKeySet retorna el conjunto de claves configurado, recargándolo periódicamente
+func CurrentKeySet() (*KeySet, error) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+
+	if cachedKeySet != nil && time.Since(keySetLoadedAt) < keySetReloadInterval {
+		return cachedKeySet, nil
+	}
+
+	ks, err := LoadKeySet()
+	if err != nil {
+		if cachedKeySet != nil {
+			// Mantener las claves anteriores si la recarga falla (p. ej. archivo a medio escribir)
+			return cachedKeySet, nil
+		}
+		return nil, err
+	}
+
+	cachedKeySet = ks
+	keySetLoadedAt = time.Now()
+	return ks, nil
+}
+
+// LoadKeySet construye el conjunto de claves desde las variables de entorno:
+//   - JWT_KEYS_DIR: directorio con <kid>.pem (privadas) o <kid>.pub.pem (solo verificación)
+//   - JWT_PRIVATE_KEY_FILE / JWT_PUBLIC_KEY_FILES: alternativa con archivos sueltos
+//   - JWT_SECRET: clave HS256; se usa para firmar si no hay claves asimétricas
+//   - JWT_LEGACY_HS256=true: con claves asimétricas, sigue aceptando tokens HS256 anteriores
+func LoadKeySet() (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		if err := ks.loadDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if file := os.Getenv("JWT_PRIVATE_KEY_FILE"); file != "" {
+		key, err := loadKeyFile(file, kidFromFile(file))
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[key.ID] = key
+		ks.active = key
+	}
+
+	for _, file := range splitList(os.Getenv("JWT_PUBLIC_KEY_FILES")) {
+		key, err := loadKeyFile(file, kidFromFile(file))
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := ks.keys[key.ID]; !exists {
+			ks.keys[key.ID] = key
+		}
+	}
+
+	asymmetric := len(ks.keys) > 0
+	if !asymmetric || os.Getenv("JWT_LEGACY_HS256") == "true" {
+		hmacKey := newHMACKey()
+		ks.keys[hmacKey.ID] = hmacKey
+		if !asymmetric {
+			ks.active = hmacKey
+		}
+	}
+
+	if ks.active == nil {
+		return nil, errors.New("no active JWT signing key configured")
+	}
+	if !ks.active.CanSign() {
+		return nil, fmt.Errorf("active JWT key %q has no private key", ks.active.ID)
+	}
+
+	return ks, nil
+}
+
+// newHMACKey clave HS256 heredada. No lleva kid para que los tokens emitidos
+// antes de la rotación sigan verificándose igual.
+func newHMACKey() *SigningKey {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "megabase-default-secret-key-change-in-production"
+	}
+	return &SigningKey{
+		ID:        "",
+		Algorithm: AlgHS256,
+		Method:    jwt.SigningMethodHS256,
+		secret:    []byte(secret),
+	}
+}
+
+// loadDir carga todas las claves del directorio y selecciona la activa
+func (ks *KeySet) loadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	pendingKID, activateAt, err := PendingKey(dir)
+	if err != nil {
+		return err
+	}
+	pendingDue := pendingKID != "" && !time.Now().Before(activateAt)
+
+	var newestPrivate string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		kid := kidFromFile(entry.Name())
+		key, err := loadKeyFile(filepath.Join(dir, entry.Name()), kid)
+		if err != nil {
+			return err
+		}
+		// Si existe la privada y la pública del mismo kid, prevalece la privada
+		if existing, ok := ks.keys[kid]; ok && existing.CanSign() {
+			continue
+		}
+		ks.keys[kid] = key
+		// Una clave programada no firma por defecto hasta que llegue su fecha de activación
+		if kid == pendingKID && !pendingDue {
+			continue
+		}
+		if key.CanSign() && kid > newestPrivate {
+			newestPrivate = kid
+		}
+	}
+
+	activeKID := newestPrivate
+	if data, err := os.ReadFile(filepath.Join(dir, activeKIDFile)); err == nil {
+		activeKID = strings.TrimSpace(string(data))
+	}
+	if pendingDue {
+		activeKID = pendingKID
+	}
+	if activeKID != "" {
+		key, ok := ks.keys[activeKID]
+		if !ok {
+			return fmt.Errorf("active JWT key %q not found in %s", activeKID, dir)
+		}
+		ks.active = key
+	}
+
+	return nil
+}
+
+// kidFromFile deriva el kid del nombre de archivo: keys/20250101-rs256.pem -> 20250101-rs256
+func kidFromFile(file string) string {
+	name := filepath.Base(file)
+	name = strings.TrimSuffix(name, ".pem")
+	return strings.TrimSuffix(name, ".pub")
+}
+
+// loadKeyFile lee una clave PEM privada (PKCS#8, PKCS#1 o SEC1) o pública (PKIX)
+func loadKeyFile(file, kid string) (*SigningKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: invalid PEM data", file)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", file, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	key, err := newSigningKey(kid, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return key, nil
+}
+
+// newSigningKey deduce el algoritmo a partir del tipo de clave
+func newSigningKey(kid string, parsed interface{}) (*SigningKey, error) {
+	key := &SigningKey{ID: kid}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Private, key.Public = k, k.Public()
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		key.Public = k
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm, key.Method = AlgRS256, jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires a P-256 key")
+		}
+		key.Algorithm, key.Method = AlgES256, jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.Algorithm, key.Method = AlgEdDSA, jwt.SigningMethodEdDSA
+	}
+
+	return key, nil
+}
+
+// GenerateKeyFile genera una nueva clave privada para el algoritmo indicado y la
+// guarda como <kid>.pem en el directorio. El kid se basa en la fecha para que la
+// clave más reciente sea también la mayor lexicográficamente.
+func GenerateKeyFile(dir, alg string) (string, error) {
+	var private interface{}
+	var err error
+
+	switch alg {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("unsupported algorithm %q (use RS256, ES256 or EdDSA)", alg)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	kid := fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405"), strings.ToLower(alg))
+	file := filepath.Join(dir, kid+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return "", err
+	}
+
+	return kid, nil
+}
+
+// SetActiveKey marca el kid como clave de firma del directorio y descarta cualquier activación programada
+func SetActiveKey(dir, kid string) error {
+	if _, err := loadKeyFile(filepath.Join(dir, kid+".pem"), kid); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, activeKIDFile), []byte(kid+"\n"), 0600); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, pendingKIDFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PinActiveKey fija en active_kid la clave que firma ahora si el directorio todavía no la fija,
+// para que una clave agregada después no pase a firmar solo por ser la más nueva. No toca la
+// activación programada. Retorna el kid fijado; vacío si ninguna clave del directorio firma.
+func PinActiveKey(dir string) (string, error) {
+	if data, err := os.ReadFile(filepath.Join(dir, activeKIDFile)); err == nil {
+		return strings.TrimSpace(string(data)), nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	if err := ks.loadDir(dir); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if ks.active == nil {
+		return "", nil
+	}
+	if err := os.WriteFile(filepath.Join(dir, activeKIDFile), []byte(ks.active.ID+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return ks.active.ID, nil
+}
+
+// ScheduleActiveKey programa que el kid pase a firmar en la fecha indicada. Hasta entonces
+// la clave solo se publica para verificación, dando tiempo a que expire el JWKS cacheado.
+func ScheduleActiveKey(dir, kid string, at time.Time) error {
+	if _, err := loadKeyFile(filepath.Join(dir, kid+".pem"), kid); err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s %s\n", kid, at.UTC().Format(time.RFC3339))
+	return os.WriteFile(filepath.Join(dir, pendingKIDFile), []byte(line), 0600)
+}
+
+// PendingKey retorna la activación programada del directorio, si existe
+func PendingKey(dir string) (string, time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(dir, pendingKIDFile))
+	if os.IsNotExist(err) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return "", time.Time{}, fmt.Errorf("%s: expected \"<kid> <RFC3339 time>\"", pendingKIDFile)
+	}
+	at, err := time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %w", pendingKIDFile, err)
+	}
+	return fields[0], at, nil
+}
+
+// JWK clave pública en formato JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet documento publicado en /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS retorna las claves públicas del conjunto. Las claves HS256 nunca se publican.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	b64 := base64.RawURLEncoding
+
+	for _, key := range ks.Keys() {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64.EncodeToString(pub.N.Bytes())
+			jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk.Kty = "EC"
+			jwk.Crv = "P-256"
+			jwk.X = b64.EncodeToString(pub.X.FillBytes(make([]byte, 32)))
+			jwk.Y = b64.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
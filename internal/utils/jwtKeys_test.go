@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestKey genera una clave EdDSA en dir con el kid indicado
+func writeTestKey(t *testing.T, dir, kid string) {
+	t.Helper()
+	generated, err := GenerateKeyFile(dir, AlgEdDSA)
+	if err != nil {
+		t.Fatalf("GenerateKeyFile: %v", err)
+	}
+	if err := os.Rename(filepath.Join(dir, generated+".pem"), filepath.Join(dir, kid+".pem")); err != nil {
+		t.Fatalf("renaming key: %v", err)
+	}
+}
+
+func TestPinActiveKeyKeepsNewKeysFromSigning(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, dir string)
+		wantPinned string
+		wantActive string // clave que firma tras agregar 20990101T000000-new
+	}{
+		{
+			name:       "single key without active_kid",
+			setup:      func(t *testing.T, dir string) { writeTestKey(t, dir, "20240101T000000-old") },
+			wantPinned: "20240101T000000-old",
+			wantActive: "20240101T000000-old",
+		},
+		{
+			name: "active_kid already written",
+			setup: func(t *testing.T, dir string) {
+				writeTestKey(t, dir, "20240101T000000-old")
+				writeTestKey(t, dir, "20240201T000000-mid")
+				if err := SetActiveKey(dir, "20240101T000000-old"); err != nil {
+					t.Fatalf("SetActiveKey: %v", err)
+				}
+			},
+			wantPinned: "20240101T000000-old",
+			wantActive: "20240101T000000-old",
+		},
+		{
+			// La clave programada sigue su curso; hasta su fecha firma la anterior
+			name: "scheduled activation is preserved",
+			setup: func(t *testing.T, dir string) {
+				writeTestKey(t, dir, "20240101T000000-old")
+				writeTestKey(t, dir, "20240201T000000-mid")
+				if err := ScheduleActiveKey(dir, "20240201T000000-mid", time.Now().Add(time.Hour)); err != nil {
+					t.Fatalf("ScheduleActiveKey: %v", err)
+				}
+			},
+			wantPinned: "20240101T000000-old",
+			wantActive: "20240101T000000-old",
+		},
+		{
+			name:       "empty directory",
+			setup:      func(t *testing.T, dir string) {},
+			wantPinned: "",
+			wantActive: "20990101T000000-new",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+			pendingBefore, _, err := PendingKey(dir)
+			if err != nil {
+				t.Fatalf("PendingKey: %v", err)
+			}
+
+			pinned, err := PinActiveKey(dir)
+			if err != nil {
+				t.Fatalf("PinActiveKey: %v", err)
+			}
+			if pinned != tt.wantPinned {
+				t.Fatalf("pinned %q, want %q", pinned, tt.wantPinned)
+			}
+			writeTestKey(t, dir, "20990101T000000-new")
+
+			ks := &KeySet{keys: make(map[string]*SigningKey)}
+			if err := ks.loadDir(dir); err != nil {
+				t.Fatalf("loadDir: %v", err)
+			}
+			if ks.active == nil || ks.active.ID != tt.wantActive {
+				t.Fatalf("active key %v, want %q", ks.active, tt.wantActive)
+			}
+			if _, ok := ks.keys["20990101T000000-new"]; !ok {
+				t.Fatal("new key is not published for verification")
+			}
+			if pendingAfter, _, _ := PendingKey(dir); pendingAfter != pendingBefore {
+				t.Fatalf("scheduled activation changed from %q to %q", pendingBefore, pendingAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMain(m *testing.M) {
+	// Las pruebas firman con la clave HS256 para no depender de archivos de claves
+	os.Setenv("JWT_SECRET", "test-secret")
+	os.Unsetenv("JWT_KEYS_DIR")
+	os.Unsetenv("JWT_PRIVATE_KEY_FILE")
+	os.Unsetenv("JWT_PUBLIC_KEY_FILES")
+	os.Exit(m.Run())
+}
+
+func newTestJWTManager() *JWTManager {
+	return &JWTManager{
+		tokenDuration:   time.Hour,
+		refreshDuration: 24 * time.Hour,
+		issuer:          "megabase-go",
+		acceptedIssuers: []string{"megabase-go"},
+		audience:        []string{"megabase-go"},
+		leeway:          30 * time.Second,
+	}
+}
+
+// issueTestToken emite un token del tipo indicado con el manager dado
+func issueTestToken(t *testing.T, manager *JWTManager, tokenType string) string {
+	t.Helper()
+
+	var token string
+	var err error
+	switch tokenType {
+	case TokenTypeAccess:
+		token, err = manager.GenerateToken(JWTClaims{UserID: 1, UserName: "admin"})
+	case TokenTypeRefresh:
+		token, err = manager.signRefreshToken(1, "family", "jti", time.Now().Add(time.Hour))
+	default:
+		token, err = manager.GeneratePurposeToken(tokenType, "1", time.Hour)
+	}
+	if err != nil {
+		t.Fatalf("issuing %s token: %v", tokenType, err)
+	}
+	return token
+}
+
+// validateTestToken valida el token para el uso indicado
+func validateTestToken(manager *JWTManager, token, tokenType string) error {
+	switch tokenType {
+	case TokenTypeAccess:
+		_, err := manager.ValidateToken(token)
+		return err
+	case TokenTypeRefresh:
+		_, err := manager.parseRefreshToken(token)
+		return err
+	default:
+		_, err := manager.ValidatePurposeToken(token, tokenType)
+		return err
+	}
+}
+
+func TestTokenTypeCrossUse(t *testing.T) {
+	manager := newTestJWTManager()
+	types := []string{TokenTypeAccess, TokenTypeRefresh, TokenTypeReset, TokenTypeInvite, TokenTypeMFA}
+
+	for _, issued := range types {
+		token := issueTestToken(t, manager, issued)
+		for _, usedAs := range types {
+			t.Run(issued+" as "+usedAs, func(t *testing.T) {
+				err := validateTestToken(manager, token, usedAs)
+				if issued == usedAs {
+					if err != nil {
+						t.Fatalf("expected %s token to be accepted, got %v", issued, err)
+					}
+					return
+				}
+				if !errors.Is(err, ErrTokenTypeMismatch) {
+					t.Fatalf("expected ErrTokenTypeMismatch, got %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestValidateTokenClaims(t *testing.T) {
+	manager := newTestJWTManager()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		claims  func(*JWTClaims)
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			claims: func(c *JWTClaims) {},
+		},
+		{
+			name:    "wrong issuer",
+			claims:  func(c *JWTClaims) { c.Issuer = "other-service" },
+			wantErr: ErrInvalidIssuer,
+		},
+		{
+			name:    "missing issuer",
+			claims:  func(c *JWTClaims) { c.Issuer = "" },
+			wantErr: ErrInvalidIssuer,
+		},
+		{
+			name:    "wrong audience",
+			claims:  func(c *JWTClaims) { c.Audience = jwt.ClaimStrings{"other-api"} },
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name: "one accepted audience among several",
+			claims: func(c *JWTClaims) {
+				c.Audience = jwt.ClaimStrings{"other-api", "megabase-go"}
+			},
+		},
+		{
+			name:   "expired within leeway",
+			claims: func(c *JWTClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) },
+		},
+		{
+			name:    "expired beyond leeway",
+			claims:  func(c *JWTClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) },
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name:    "missing expiry",
+			claims:  func(c *JWTClaims) { c.ExpiresAt = nil },
+			wantErr: jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name: "not yet valid within leeway",
+			claims: func(c *JWTClaims) {
+				c.IssuedAt = jwt.NewNumericDate(now.Add(10 * time.Second))
+				c.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Second))
+			},
+		},
+		{
+			name:    "not yet valid beyond leeway",
+			claims:  func(c *JWTClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute)) },
+			wantErr: jwt.ErrTokenNotValidYet,
+		},
+		{
+			name:    "issued in the future beyond leeway",
+			claims:  func(c *JWTClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) },
+			wantErr: jwt.ErrTokenUsedBeforeIssued,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := JWTClaims{
+				UserID:           1,
+				TokenType:        TokenTypeAccess,
+				RegisteredClaims: manager.registeredClaims("jti", "1", now.Add(time.Hour)),
+			}
+			tt.claims(&claims)
+
+			token, err := manager.sign(claims)
+			if err != nil {
+				t.Fatalf("signing token: %v", err)
+			}
+
+			_, err = manager.ValidateToken(token)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("expected token to be accepted, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateTokenIssuerFromOtherManager(t *testing.T) {
+	issuer := newTestJWTManager()
+	issuer.issuer = "other-service"
+	token := issueTestToken(t, issuer, TokenTypeAccess)
+
+	if _, err := newTestJWTManager().ValidateToken(token); !errors.Is(err, ErrInvalidIssuer) {
+		t.Fatalf("expected ErrInvalidIssuer, got %v", err)
+	}
+
+	// Un issuer anterior sigue siendo válido mientras esté en la lista de aceptados
+	verifier := newTestJWTManager()
+	verifier.acceptedIssuers = []string{"megabase-go", "other-service"}
+	if _, err := verifier.ValidateToken(token); err != nil {
+		t.Fatalf("expected token from accepted issuer, got %v", err)
+	}
+}
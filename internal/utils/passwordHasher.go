@@ -1,27 +1,68 @@
 // internal/utils/password_hasher.go
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+    "strings"
+
+    "megabaseGo/internal/config"
+
+    "golang.org/x/crypto/bcrypt"
+)
 
 // PasswordHasher abstrae el hashing de contraseñas
 type PasswordHasher interface {
     HashPassword(password string) (string, error)
     ComparePassword(hashedPassword, password string) error
+    // NeedsRehash indica si el hash usa otro algoritmo o parámetros más débiles que los configurados
+    NeedsRehash(hashedPassword string) bool
+}
+
+// NewPasswordHasher construye el hasher configurado en PASSWORD_HASHER (argon2id por defecto)
+func NewPasswordHasher() PasswordHasher {
+    cfg := config.Get().Password
+    if strings.EqualFold(cfg.Hasher, "bcrypt") {
+        return NewBcryptHasherWithCost(cfg.BcryptCost)
+    }
+    return NewArgon2idHasher(cfg.Argon2Memory, cfg.Argon2Time, cfg.Argon2Threads)
 }
 
 // BcryptHasher implementa PasswordHasher usando bcrypt
-type BcryptHasher struct{}
+type BcryptHasher struct {
+    cost int
+}
 
 // NewBcryptHasher construye un BcryptHasher
 func NewBcryptHasher() *BcryptHasher {
-    return &BcryptHasher{}
+    return &BcryptHasher{cost: bcrypt.DefaultCost}
+}
+
+// NewBcryptHasherWithCost construye un BcryptHasher con el costo indicado
+func NewBcryptHasherWithCost(cost int) *BcryptHasher {
+    if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+        cost = bcrypt.DefaultCost
+    }
+    return &BcryptHasher{cost: cost}
 }
 
 func (b *BcryptHasher) HashPassword(password string) (string, error) {
-    bs, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    bs, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
     return string(bs), err
 }
 
 func (b *BcryptHasher) ComparePassword(hashedPassword, password string) error {
+    return comparePasswordHash(hashedPassword, password)
+}
+
+func (b *BcryptHasher) NeedsRehash(hashedPassword string) bool {
+    cost, err := bcrypt.Cost([]byte(hashedPassword))
+    return err != nil || cost < b.cost
+}
+
+// comparePasswordHash verifica la contraseña según el algoritmo del hash guardado,
+// así los hashes bcrypt existentes siguen siendo válidos al cambiar a argon2id y viceversa
+func comparePasswordHash(hashedPassword, password string) error {
+    if strings.HasPrefix(hashedPassword, argon2idPrefix) {
+        return compareArgon2id(hashedPassword, password)
+    }
     return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
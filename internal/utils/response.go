@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+
+	"megabaseGo/internal/pagination"
 
 	"github.com/gin-gonic/gin"
 )
@@ -40,6 +44,25 @@ func SendData(c *gin.Context, statusCode int, data interface{}) {
 	c.JSON(statusCode, response)
 }
 
+// JsonPageResponse define la estructura para listados paginados (data + metadatos de paginación)
+type JsonPageResponse struct {
+	Status     string            `json:"status"`
+	Data       interface{}       `json:"data"`
+	Pagination pagination.Linker `json:"pagination"`
+}
+
+// SendPage es el helper para listados paginados: agrega la cabecera Link (RFC 8288)
+// con las páginas vecinas y envía los datos junto a los metadatos de paginación
+// (total, total_pages y has_next por página; next_cursor y has_next por cursor).
+func SendPage(c *gin.Context, data interface{}, meta pagination.Linker) {
+	c.Header("Link", meta.LinkHeader(c.Request.URL))
+	c.JSON(http.StatusOK, JsonPageResponse{
+		Status:     "success",
+		Data:       data,
+		Pagination: meta,
+	})
+}
+
 // SendError es el helper para enviar respuestas de error consistentes.
 func SendError(c *gin.Context, statusCode int, message string) {
 	response := JsonResponse{
@@ -54,6 +77,9 @@ func SendError(c *gin.Context, statusCode int, message string) {
 func HandleGinError(c *gin.Context, err error) {
 	// Intentamos ver si es un error de nuestra API que ya hemos definido
 	if apiErr, ok := IsAPIError(err); ok {
+		if apiErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+		}
 		SendError(c, apiErr.GetStatusCode(), apiErr.Error())
 		return
 	}